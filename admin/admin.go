@@ -0,0 +1,262 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package admin provides http.Handler for browsing versions of a running service without shell access.
+//
+// Handler serves following paths, relative to the path where it is registered (use http.StripPrefix):
+//
+//	GET    /versions         list versions encoded to JSON, oldest first
+//	GET    /versions/latest  data of the latest version
+//	GET    /versions/<time>  data of the version with given time in RFC 3339 format, as listed by /versions
+//	DELETE /versions/<time>  delete the version, only when ProtectedActions option was used
+//	POST   /compact          run compacter once, only when ProtectedActions option was used
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/store"
+)
+
+// VersionTimeHeader contains time of the version which data is sent in the response body
+const VersionTimeHeader = "X-Deebee-Version-Time"
+
+type Action string
+
+const (
+	DeleteVersion Action = "delete"
+	Compact       Action = "compact"
+)
+
+// Authorizer returns true when request is allowed to perform the action. Authorizer can check for example
+// credentials sent in request headers.
+type Authorizer func(req *http.Request, action Action) bool
+
+type Option func(*Options) error
+
+type Options struct {
+	authorizer Authorizer
+}
+
+// ProtectedActions exposes deleting versions and running compacter. Each request is allowed only when authorizer
+// returns true, otherwise 403 Forbidden is returned. Store must implement compacter.Store.
+func ProtectedActions(authorizer Authorizer) Option {
+	return func(o *Options) error {
+		if authorizer == nil {
+			return errors.New("nil authorizer")
+		}
+		o.authorizer = authorizer
+		return nil
+	}
+}
+
+// Handler is a read-only http.Handler, unless ProtectedActions option was used. Handler is safe for concurrent use.
+type Handler struct {
+	store      codec.ReadOnlyStore
+	authorizer Authorizer
+}
+
+func NewHandler(s codec.ReadOnlyStore, options ...Option) (*Handler, error) {
+	if s == nil {
+		return nil, errors.New("nil store")
+	}
+	opts := &Options{}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	if _, ok := s.(compacter.Store); opts.authorizer != nil && !ok {
+		return nil, errors.New("store does not support protected actions")
+	}
+	return &Handler{store: s, authorizer: opts.authorizer}, nil
+}
+
+const versionsPath = "/versions"
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	switch {
+	case path == versionsPath:
+		if !allowMethods(w, req, http.MethodGet) {
+			return
+		}
+		h.serveVersions(w, req)
+	case strings.HasPrefix(path, versionsPath+"/"):
+		version := strings.TrimPrefix(path, versionsPath+"/")
+		if h.authorizer == nil {
+			if !allowMethods(w, req, http.MethodGet) {
+				return
+			}
+		} else if !allowMethods(w, req, http.MethodGet, http.MethodDelete) {
+			return
+		}
+		if req.Method == http.MethodDelete {
+			h.deleteVersion(w, req, version)
+			return
+		}
+		h.serveVersion(w, req, version)
+	case path == "/compact" && h.authorizer != nil:
+		if !allowMethods(w, req, http.MethodPost) {
+			return
+		}
+		h.compact(w, req)
+	default:
+		writeError(w, req, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func allowMethods(w http.ResponseWriter, req *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if req.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, req, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+	return false
+}
+
+type versionJSON struct {
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+func (h *Handler) serveVersions(w http.ResponseWriter, req *http.Request) {
+	versions, err := h.store.Versions()
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, err)
+		return
+	}
+	list := make([]versionJSON, len(versions))
+	for i, version := range versions {
+		list[i] = versionJSON{Time: version.Time.UTC(), Size: version.Size}
+	}
+	writeJSON(w, req, http.StatusOK, list)
+}
+
+// serveVersion streams version data. Integrity is verified while streaming, therefore checksum error is usually
+// found when the response is already being sent. The response is aborted then using http.ErrAbortHandler, so
+// the client gets an error instead of corrupted data.
+func (h *Handler) serveVersion(w http.ResponseWriter, req *http.Request, version string) {
+	var options []store.ReaderOption
+	if version != "latest" {
+		t, err := time.Parse(time.RFC3339Nano, version)
+		if err != nil {
+			writeError(w, req, http.StatusBadRequest, fmt.Errorf("invalid version time: %w", err))
+			return
+		}
+		options = append(options, store.Time(t))
+	}
+	reader, err := codec.OpenReader(req.Context(), h.store, options...)
+	if err != nil {
+		writeError(w, req, statusOf(err), err)
+		return
+	}
+
+	body := &countingWriter{writer: w}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(reader.Version().Size, 10))
+	w.Header().Set(VersionTimeHeader, reader.Version().Time.UTC().Format(time.RFC3339Nano))
+	_, err = io.Copy(body, reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		return
+	}
+	if body.written == 0 {
+		w.Header().Del("Content-Length")
+		w.Header().Del(VersionTimeHeader)
+		writeError(w, req, statusOf(err), err)
+		return
+	}
+	log.With("version", reader.Version().Time).WithError(err).Warn(req.Context(), "aborting response with version data")
+	panic(http.ErrAbortHandler)
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+func (h *Handler) deleteVersion(w http.ResponseWriter, req *http.Request, version string) {
+	if !h.authorize(w, req, DeleteVersion) {
+		return
+	}
+	t, err := time.Parse(time.RFC3339Nano, version)
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, fmt.Errorf("invalid version time: %w", err))
+		return
+	}
+	if err = h.store.(compacter.Store).DeleteVersion(t); err != nil {
+		writeError(w, req, statusOf(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) compact(w http.ResponseWriter, req *http.Request) {
+	if !h.authorize(w, req, Compact) {
+		return
+	}
+	collector := &compacter.Collector{}
+	err := compacter.RunOnceContext(req.Context(), h.store.(compacter.Store), compacter.Collect(collector))
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, req, http.StatusOK, struct {
+		Deleted int `json:"deleted"`
+	}{collector.Metrics().DeletedVersions})
+}
+
+func (h *Handler) authorize(w http.ResponseWriter, req *http.Request, action Action) bool {
+	if h.authorizer(req, action) {
+		return true
+	}
+	writeError(w, req, http.StatusForbidden, fmt.Errorf("%s not allowed", action))
+	return false
+}
+
+func statusOf(err error) int {
+	if store.IsVersionNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, req *http.Request, status int, err error) {
+	writeJSON(w, req, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, req *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn(req.Context(), "writing admin response failed")
+	}
+}
@@ -0,0 +1,214 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package admin_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/admin"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+
+	t.Run("should return error for nil store", func(t *testing.T) {
+		_, err := admin.NewHandler(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for nil authorizer", func(t *testing.T) {
+		_, err := admin.NewHandler(tests.OpenStore(t), admin.ProtectedActions(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when store does not support protected actions", func(t *testing.T) {
+		_, err := admin.NewHandler(&tests.StoreMock{}, admin.ProtectedActions(allowAll))
+		assert.Error(t, err)
+	})
+}
+
+func TestHandler_Versions(t *testing.T) {
+
+	t.Run("should list versions", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		first := tests.WriteData(t, s, []byte("first"))
+		second := tests.WriteData(t, s, []byte("second!"))
+		// when
+		recorder := serve(t, s, http.MethodGet, "/versions")
+		// then
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		var versions []struct {
+			Time time.Time `json:"time"`
+			Size int64     `json:"size"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &versions))
+		require.Len(t, versions, 2)
+		assert.True(t, first.Time.Equal(versions[0].Time))
+		assert.Equal(t, int64(5), versions[0].Size)
+		assert.True(t, second.Time.Equal(versions[1].Time))
+		assert.Equal(t, int64(7), versions[1].Size)
+	})
+
+	t.Run("should return empty list for empty store", func(t *testing.T) {
+		recorder := serve(t, tests.OpenStore(t), http.MethodGet, "/versions")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, "[]", recorder.Body.String())
+	})
+
+	t.Run("should return 404 for unknown path", func(t *testing.T) {
+		recorder := serve(t, tests.OpenStore(t), http.MethodGet, "/unknown")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("should return 405 for not allowed method", func(t *testing.T) {
+		recorder := serve(t, tests.OpenStore(t), http.MethodPost, "/versions")
+		assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+		assert.Equal(t, http.MethodGet, recorder.Header().Get("Allow"))
+	})
+}
+
+func TestHandler_Version(t *testing.T) {
+
+	t.Run("should stream latest version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("old"))
+		latest := tests.WriteData(t, s, []byte("latest"))
+		// when
+		recorder := serve(t, s, http.MethodGet, "/versions/latest")
+		// then
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "latest", recorder.Body.String())
+		assert.Equal(t, "6", recorder.Header().Get("Content-Length"))
+		assert.Equal(t, formatTime(latest.Time), recorder.Header().Get(admin.VersionTimeHeader))
+	})
+
+	t.Run("should stream version with given time", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		old := tests.WriteData(t, s, []byte("old"))
+		tests.WriteData(t, s, []byte("latest"))
+		// when
+		recorder := serve(t, s, http.MethodGet, "/versions/"+formatTime(old.Time))
+		// then
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "old", recorder.Body.String())
+	})
+
+	t.Run("should return 404 when version does not exist", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		// when
+		recorder := serve(t, s, http.MethodGet, "/versions/"+formatTime(time.Now()))
+		// then
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("should return 400 for invalid time", func(t *testing.T) {
+		recorder := serve(t, tests.OpenStore(t), http.MethodGet, "/versions/yesterday")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should abort response when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		handler, err := admin.NewHandler(s)
+		require.NoError(t, err)
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		// when
+		response, err := http.Get(server.URL + "/versions/" + formatTime(version.Time))
+		// then
+		if err == nil { // response is aborted before or after headers were sent
+			defer response.Body.Close()
+			_, err = io.ReadAll(response.Body)
+		}
+		assert.Error(t, err)
+	})
+}
+
+func TestHandler_ProtectedActions(t *testing.T) {
+
+	t.Run("should not expose actions by default", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		deleteRecorder := serve(t, s, http.MethodDelete, "/versions/"+formatTime(version.Time))
+		compactRecorder := serve(t, s, http.MethodPost, "/compact")
+		// then
+		assert.Equal(t, http.StatusMethodNotAllowed, deleteRecorder.Code)
+		assert.Equal(t, http.StatusNotFound, compactRecorder.Code)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should delete version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		recorder := serve(t, s, http.MethodDelete, "/versions/"+formatTime(version.Time), admin.ProtectedActions(allowAll))
+		// then
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should compact", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("old"))
+		tests.WriteData(t, s, []byte("latest"))
+		// when
+		recorder := serve(t, s, http.MethodPost, "/compact", admin.ProtectedActions(allowAll))
+		// then
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"deleted":1}`, recorder.Body.String())
+	})
+
+	t.Run("should pass request and action to authorizer", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		var actions []admin.Action
+		authorizer := func(req *http.Request, action admin.Action) bool {
+			actions = append(actions, action)
+			return req.Header.Get("Authorization") == "secret"
+		}
+		// when
+		deleteRecorder := serve(t, s, http.MethodDelete, "/versions/"+formatTime(version.Time), admin.ProtectedActions(authorizer))
+		compactRecorder := serve(t, s, http.MethodPost, "/compact", admin.ProtectedActions(authorizer))
+		// then
+		assert.Equal(t, http.StatusForbidden, deleteRecorder.Code)
+		assert.Equal(t, http.StatusForbidden, compactRecorder.Code)
+		assert.Equal(t, []admin.Action{admin.DeleteVersion, admin.Compact}, actions)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+}
+
+func allowAll(*http.Request, admin.Action) bool {
+	return true
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func serve(t *testing.T, s *store.Store, method, path string, options ...admin.Option) *httptest.ResponseRecorder {
+	handler, err := admin.NewHandler(s, options...)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
@@ -0,0 +1,414 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+)
+
+const (
+	integrityOK        = "ok"
+	integrityCorrupted = "corrupted"
+	integrityError     = "error"
+)
+
+type versionStatus struct {
+	Time      string `json:"time"`
+	Size      int64  `json:"size"`
+	Integrity string `json:"integrity"`
+	Error     string `json:"error,omitempty"`
+}
+
+func runLs(inv *invocation) error {
+	inv.jsonFlag()
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	versions, err := s.Versions()
+	if err != nil {
+		return err
+	}
+	statuses := verifyVersions(inv, s, versions)
+	return inv.printStatuses(statuses)
+}
+
+func runVerify(inv *invocation) error {
+	inv.jsonFlag()
+	t := inv.timeFlag("time", "verify only the version with given time")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	versions, err := s.Versions()
+	if err != nil {
+		return err
+	}
+	if t.isSet {
+		versions = versionsWithTime(versions, t.time)
+		if len(versions) == 0 {
+			return fmt.Errorf("version %s not found", formatTime(t.time))
+		}
+	}
+	statuses := verifyVersions(inv, s, versions)
+	if err = inv.printStatuses(statuses); err != nil {
+		return err
+	}
+	failed := 0
+	for _, status := range statuses {
+		if status.Integrity != integrityOK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d versions failed verification", failed, len(statuses))
+	}
+	return nil
+}
+
+func versionsWithTime(versions []store.Version, t time.Time) []store.Version {
+	for _, version := range versions {
+		if version.Time.Equal(t) {
+			return []store.Version{version}
+		}
+	}
+	return nil
+}
+
+func verifyVersions(inv *invocation, s *store.Store, versions []store.Version) []versionStatus {
+	statuses := make([]versionStatus, len(versions))
+	for i, version := range versions {
+		statuses[i] = versionStatus{
+			Time:      formatTime(version.Time),
+			Size:      version.Size,
+			Integrity: integrityOK,
+		}
+		if err := s.VerifyContext(inv.ctx, version.Time); err != nil {
+			statuses[i].Integrity = integrityError
+			if store.IsCorrupted(err) {
+				statuses[i].Integrity = integrityCorrupted
+			}
+			statuses[i].Error = err.Error()
+		}
+	}
+	return statuses
+}
+
+func (inv *invocation) printStatuses(statuses []versionStatus) error {
+	if inv.json {
+		return printJSON(inv.stdout, statuses)
+	}
+	tw := tabwriter.NewWriter(inv.stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TIME\tSIZE\tINTEGRITY")
+	for _, status := range statuses {
+		integrity := status.Integrity
+		if status.Error != "" {
+			integrity += ": " + status.Error
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\n", status.Time, status.Size, integrity)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func runCat(inv *invocation) error {
+	t := inv.timeFlag("time", "time of version, latest version by default")
+	entry := inv.flags.String("entry", "", "name of entry to write, for versions written with EntriesWriter")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var options []store.ReaderOption
+	if t.isSet {
+		options = append(options, store.Time(t.time))
+	}
+	var r store.Reader
+	if *entry != "" {
+		r, err = s.EntryReaderContext(inv.ctx, *entry, options...)
+	} else {
+		r, err = s.ReaderContext(inv.ctx, options...)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(inv.stdout, r); err != nil {
+		_ = r.Close()
+		return err
+	}
+	return r.Close()
+}
+
+func runRm(inv *invocation) error {
+	inv.jsonFlag()
+	t := inv.timeFlag("time", "time of version to delete (required)")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	if !t.isSet {
+		return usageError{msg: "-time is required"}
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err = s.DeleteVersionContext(inv.ctx, t.time); err != nil {
+		return err
+	}
+	if inv.json {
+		return printJSON(inv.stdout, struct {
+			Deleted string `json:"deleted"`
+		}{formatTime(t.time)})
+	}
+	_, _ = fmt.Fprintf(inv.stdout, "deleted %s\n", formatTime(t.time))
+	return nil
+}
+
+func runCompact(inv *invocation) error {
+	inv.jsonFlag()
+	allNamespaces := inv.flags.Bool("all-namespaces", false, "compact namespaces too")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	collector := &compacter.Collector{}
+	options := []compacter.Option{compacter.Collect(collector)}
+	if *allNamespaces {
+		options = append(options, compacter.AllNamespaces)
+	}
+	err = compacter.RunOnceContext(inv.ctx, s, options...)
+	deleted := collector.Metrics().DeletedVersions
+	if inv.json {
+		if printErr := printJSON(inv.stdout, struct {
+			Deleted int `json:"deleted"`
+		}{deleted}); printErr != nil && err == nil {
+			err = printErr
+		}
+	} else {
+		_, _ = fmt.Fprintf(inv.stdout, "deleted %d versions\n", deleted)
+	}
+	return err
+}
+
+func runReplicate(inv *invocation) error {
+	inv.jsonFlag()
+	if err := inv.parse(2); err != nil {
+		return err
+	}
+	from, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	to, err := store.Open(inv.flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	err = replicator.CopyFromToContext(inv.ctx, from, to)
+	alreadyReplicated := store.IsVersionAlreadyExists(err)
+	if err != nil && !alreadyReplicated {
+		return err
+	}
+	versions, err := to.Versions()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return errors.New("no version replicated")
+	}
+	latest := versions[len(versions)-1]
+	if inv.json {
+		return printJSON(inv.stdout, struct {
+			Time              string `json:"time"`
+			Size              int64  `json:"size"`
+			AlreadyReplicated bool   `json:"alreadyReplicated"`
+		}{formatTime(latest.Time), latest.Size, alreadyReplicated})
+	}
+	if alreadyReplicated {
+		_, _ = fmt.Fprintf(inv.stdout, "version %s already replicated\n", formatTime(latest.Time))
+	} else {
+		_, _ = fmt.Fprintf(inv.stdout, "replicated %s (%d bytes)\n", formatTime(latest.Time), latest.Size)
+	}
+	return nil
+}
+
+type versionSummary struct {
+	Time string `json:"time"`
+	Size int64  `json:"size"`
+}
+
+func summarize(versions []store.Version) []versionSummary {
+	summaries := make([]versionSummary, len(versions))
+	for i, version := range versions {
+		summaries[i] = versionSummary{Time: formatTime(version.Time), Size: version.Size}
+	}
+	return summaries
+}
+
+// printSummary prints versions to w, one per line, or as JSON
+func (inv *invocation) printSummary(w io.Writer, verb string, versions []store.Version) error {
+	if inv.json {
+		return printJSON(w, summarize(versions))
+	}
+	for _, version := range versions {
+		_, _ = fmt.Fprintf(w, "%s %s (%d bytes)\n", verb, formatTime(version.Time), version.Size)
+	}
+	return nil
+}
+
+func runExport(inv *invocation) error {
+	inv.jsonFlag()
+	output := inv.flags.String("o", "", "write archive to file instead of stdout")
+	after := inv.timeFlag("after", "export only versions newer than or equal to given time")
+	before := inv.timeFlag("before", "export only versions older than given time")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	filter := func(v store.Version) bool {
+		return (!after.isSet || !v.Time.Before(after.time)) && (!before.isSet || v.Time.Before(before.time))
+	}
+	// archive goes to stdout by default, so summary must not be mixed with it
+	archive, summary := inv.stdout, inv.stderr
+	var file *os.File
+	if *output != "" {
+		file, err = os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0664)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		archive, summary = file, inv.stdout
+	}
+	exported, err := s.ExportContext(inv.ctx, archive, filter)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		if err = file.Sync(); err != nil {
+			return err
+		}
+	}
+	return inv.printSummary(summary, "exported", exported)
+}
+
+func runImport(inv *invocation) error {
+	inv.jsonFlag()
+	input := inv.flags.String("i", "", "read archive from file instead of stdin")
+	skipExisting := inv.flags.Bool("skip-existing", false, "skip versions which already exist in the store")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := store.Open(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	archive := inv.stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		archive = file
+	}
+	var options []store.ImportOption
+	if *skipExisting {
+		options = append(options, store.SkipExisting)
+	}
+	imported, err := s.ImportContext(inv.ctx, archive, options...)
+	if err != nil {
+		return err
+	}
+	return inv.printSummary(inv.stdout, "imported", imported)
+}
+
+// runFixChecksum rewrites the version with its current content, so the checksum matches data edited by hand
+func runFixChecksum(inv *invocation) error {
+	inv.jsonFlag()
+	t := inv.timeFlag("time", "time of version to fix (required)")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	if !t.isSet {
+		return usageError{msg: "-time is required"}
+	}
+	s, err := openStore(inv.flags.Arg(0), store.NoIntegrityCheck)
+	if err != nil {
+		return err
+	}
+	if _, err = s.Entries(t.time); err == nil {
+		return errors.New("fixing checksums of versions with entries is not supported")
+	} else if !store.IsVersionNotFound(err) {
+		return err
+	}
+	segmentSize, err := s.VersionSegmentSize(t.time)
+	if err != nil {
+		return err
+	}
+	if segmentSize > 0 {
+		return errors.New("fixing checksums of segmented versions is not supported")
+	}
+
+	r, err := s.ReaderContext(inv.ctx, store.Time(t.time))
+	if err != nil {
+		return err
+	}
+	w, err := s.WriterContext(inv.ctx, store.WriteTime(t.time), store.Replace)
+	if err != nil {
+		_ = r.Close()
+		return err
+	}
+	_, err = io.Copy(w, r)
+	// reader is closed before writer replaces the data file
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		w.AbortAndClose()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if inv.json {
+		return printJSON(inv.stdout, struct {
+			Fixed versionSummary `json:"fixed"`
+		}{summarize([]store.Version{w.Version()})[0]})
+	}
+	_, _ = fmt.Fprintf(inv.stdout, "fixed checksum of %s\n", formatTime(t.time))
+	return nil
+}
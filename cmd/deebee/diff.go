@@ -0,0 +1,124 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elgopher/deebee/diff"
+	"github.com/elgopher/deebee/store"
+)
+
+func runDiff(inv *invocation) error {
+	inv.jsonFlag()
+	from := inv.timeFlag("from", "time of older version, version preceding -to by default")
+	to := inv.timeFlag("to", "time of newer version, latest version by default")
+	bytes := inv.flags.Bool("bytes", false, "compare byte by byte, even when both versions contain JSON")
+	if err := inv.parse(1); err != nil {
+		return err
+	}
+	s, err := openStore(inv.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err = chooseVersionsToDiff(s, from, to); err != nil {
+		return err
+	}
+
+	var result diff.Result
+	if *bytes {
+		result, err = diff.BytesContext(inv.ctx, s, from.time, to.time)
+	} else {
+		result, err = diff.VersionsContext(inv.ctx, s, from.time, to.time)
+	}
+	if err != nil {
+		return err
+	}
+	if inv.json {
+		return printJSON(inv.stdout, diffOutput{
+			From:    versionSummary{Time: formatTime(result.From.Time), Size: result.From.Size},
+			To:      versionSummary{Time: formatTime(result.To.Time), Size: result.To.Size},
+			JSON:    result.JSON,
+			Equal:   result.Equal(),
+			Changes: result.Changes,
+			Ranges:  result.Ranges,
+		})
+	}
+	printDiff(inv.stdout, result)
+	return nil
+}
+
+// chooseVersionsToDiff sets missing times to the latest version and the version preceding it
+func chooseVersionsToDiff(s *store.Store, from, to *timeValue) error {
+	if from.isSet && to.isSet {
+		return nil
+	}
+	versions, err := s.Versions()
+	if err != nil {
+		return err
+	}
+	if !to.isSet {
+		if len(versions) == 0 {
+			return errors.New("store has no versions")
+		}
+		to.time, to.isSet = versions[len(versions)-1].Time, true
+	}
+	if !from.isSet {
+		for _, version := range versions {
+			if version.Time.Before(to.time) {
+				from.time, from.isSet = version.Time, true
+			}
+		}
+		if !from.isSet {
+			return fmt.Errorf("no version older than %s", formatTime(to.time))
+		}
+	}
+	return nil
+}
+
+type diffOutput struct {
+	From    versionSummary `json:"from"`
+	To      versionSummary `json:"to"`
+	JSON    bool           `json:"json"`
+	Equal   bool           `json:"equal"`
+	Changes []diff.Change  `json:"changes,omitempty"`
+	Ranges  []diff.Range   `json:"ranges,omitempty"`
+}
+
+func printDiff(w io.Writer, result diff.Result) {
+	_, _ = fmt.Fprintf(w, "--- %s (%d bytes)\n", formatTime(result.From.Time), result.From.Size)
+	_, _ = fmt.Fprintf(w, "+++ %s (%d bytes)\n", formatTime(result.To.Time), result.To.Size)
+	if result.Equal() {
+		_, _ = fmt.Fprintln(w, "versions are equal")
+		return
+	}
+	for _, change := range result.Changes {
+		path := change.Path
+		if path == "" {
+			path = "(root)"
+		}
+		switch change.Kind {
+		case diff.Added:
+			_, _ = fmt.Fprintf(w, "+ %s: %s\n", path, formatValue(change.New))
+		case diff.Removed:
+			_, _ = fmt.Fprintf(w, "- %s: %s\n", path, formatValue(change.Old))
+		default:
+			_, _ = fmt.Fprintf(w, "~ %s: %s -> %s\n", path, formatValue(change.Old), formatValue(change.New))
+		}
+	}
+	for _, r := range result.Ranges {
+		_, _ = fmt.Fprintf(w, "bytes %d-%d differ\n", r.Offset, r.Offset+r.Length-1)
+	}
+}
+
+func formatValue(v interface{}) string {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bytes)
+}
@@ -0,0 +1,226 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Command deebee inspects and manages stores from the command line.
+//
+// Usage:
+//
+//	deebee [-v] <command> [flags] <args>
+//
+// Run "deebee help" to list commands, and "deebee <command> -h" to list flags of a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/yala/adapter/console"
+	"github.com/elgopher/yala/adapter/printer"
+	"github.com/elgopher/yala/logger"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(*invocation) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"ls", "<dir>", "list versions with size and integrity", runLs},
+		{"cat", "<dir>", "write verified version to stdout, non-zero exit code means output must not be trusted", runCat},
+		{"verify", "<dir>", "verify integrity of versions", runVerify},
+		{"diff", "<dir>", "compare two versions, structurally when both contain JSON", runDiff},
+		{"rm", "<dir>", "delete version", runRm},
+		{"compact", "<dir>", "delete all versions older than the latest integral one", runCompact},
+		{"replicate", "<from-dir> <to-dir>", "copy latest version to another store", runReplicate},
+		{"export", "<dir>", "write tar archive with versions", runExport},
+		{"import", "<dir>", "add versions from tar archive written by export", runImport},
+		{"fix-checksum", "<dir>", "update checksum of a version after data file was edited by hand", runFixChecksum},
+	}
+}
+
+// invocation is a single run of a command
+type invocation struct {
+	args   []string // arguments after command name
+	ctx    context.Context
+	flags  *flag.FlagSet
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run executes the tool and returns exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("deebee", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	verbose := global.Bool("v", false, "print logs to stderr")
+	global.Usage = func() { printUsage(stderr) }
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+	setLoggerAdapter(*verbose, stderr)
+
+	if global.NArg() == 0 || global.Arg(0) == "help" {
+		printUsage(stdout)
+		if global.NArg() == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	name := global.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		inv := &invocation{
+			args:   global.Args()[1:],
+			ctx:    context.Background(),
+			flags:  flag.NewFlagSet("deebee "+cmd.name, flag.ContinueOnError),
+			stdin:  stdin,
+			stdout: stdout,
+			stderr: stderr,
+		}
+		inv.flags.SetOutput(stderr)
+		inv.flags.Usage = func() {
+			_, _ = fmt.Fprintf(stderr, "Usage: deebee %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.description)
+			inv.flags.PrintDefaults()
+		}
+		err := cmd.run(inv)
+		var usageErr usageError
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usageErr):
+			if usageErr.msg != "" {
+				_, _ = fmt.Fprintf(stderr, "deebee %s: %s\n", cmd.name, usageErr.msg)
+			}
+			inv.flags.Usage()
+			return exitUsage
+		case err != nil:
+			_, _ = fmt.Fprintf(stderr, "deebee %s: %s\n", cmd.name, err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	_, _ = fmt.Fprintf(stderr, "deebee: unknown command %q\n", name)
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: deebee [-v] <command> [flags] <args>")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.description)
+	}
+	_ = tw.Flush()
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, `Run "deebee <command> -h" to list flags of a command.`)
+}
+
+func setLoggerAdapter(verbose bool, stderr io.Writer) {
+	var adapter logger.Adapter = discardAdapter{}
+	if verbose {
+		adapter = printer.Adapter{Printer: console.WriterPrinter{Writer: stderr}}
+	}
+	store.SetLoggerAdapter(adapter)
+	compacter.SetLoggerAdapter(adapter)
+	replicator.SetLoggerAdapter(adapter)
+}
+
+type discardAdapter struct{}
+
+func (discardAdapter) Log(context.Context, logger.Entry) {}
+
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// jsonFlag adds -json flag, which must be checked after parse
+func (inv *invocation) jsonFlag() {
+	inv.flags.BoolVar(&inv.json, "json", false, "print output in JSON format")
+}
+
+// timeFlag adds flag accepting version time in RFC 3339 format, as printed by ls
+func (inv *invocation) timeFlag(name, usage string) *timeValue {
+	v := &timeValue{}
+	inv.flags.Var(v, name, usage+" (RFC 3339 format, as printed by ls)")
+	return v
+}
+
+// parse parses flags and checks the number of positional arguments
+func (inv *invocation) parse(expectedArgs int) error {
+	if err := inv.flags.Parse(inv.args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{}
+	}
+	if inv.flags.NArg() != expectedArgs {
+		return usageError{msg: fmt.Sprintf("expected %d arguments, got %d", expectedArgs, inv.flags.NArg())}
+	}
+	return nil
+}
+
+type timeValue struct {
+	time  time.Time
+	isSet bool
+}
+
+func (t *timeValue) String() string {
+	if t == nil || !t.isSet {
+		return ""
+	}
+	return formatTime(t.time)
+}
+
+func (t *timeValue) Set(s string) error {
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+	t.time = parsed
+	t.isSet = true
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// openStore opens existing store directory
+func openStore(dir string, options ...store.Option) (*store.Store, error) {
+	return store.Open(dir, append([]store.Option{store.FailWhenMissingDir}, options...)...)
+}
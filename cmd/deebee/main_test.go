@@ -0,0 +1,421 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/elgopher/deebee/diff"
+	"github.com/elgopher/deebee/internal/tests"
+	deebeejson "github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func runTool(stdin io.Reader, args ...string) result {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, stdin, stdout, stderr)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func openTestStore(t *testing.T, dir string) *store.Store {
+	s, err := store.Open(dir)
+	require.NoError(t, err)
+	return s
+}
+
+func TestRun(t *testing.T) {
+
+	t.Run("should print usage when command is missing", func(t *testing.T) {
+		// when
+		r := runTool(nil)
+		// then
+		assert.Equal(t, exitUsage, r.code)
+		assert.Contains(t, r.stdout, "Commands:")
+	})
+
+	t.Run("should return usage exit code for unknown command", func(t *testing.T) {
+		// when
+		r := runTool(nil, "unknown")
+		// then
+		assert.Equal(t, exitUsage, r.code)
+		assert.Contains(t, r.stderr, `unknown command "unknown"`)
+	})
+
+	t.Run("should return usage exit code when arguments are missing", func(t *testing.T) {
+		// when
+		r := runTool(nil, "ls")
+		// then
+		assert.Equal(t, exitUsage, r.code)
+		assert.Contains(t, r.stderr, "expected 1 arguments, got 0")
+	})
+
+	t.Run("should fail when store directory does not exist", func(t *testing.T) {
+		dir := path.Join(tests.TempDir(t), "missing")
+		// when
+		r := runTool(nil, "ls", dir)
+		// then
+		assert.Equal(t, exitFailure, r.code)
+		assert.NotEmpty(t, r.stderr)
+	})
+}
+
+func TestLs(t *testing.T) {
+
+	t.Run("should list versions with integrity", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		r := runTool(nil, "ls", dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		assert.Contains(t, r.stdout, "TIME")
+		assert.Contains(t, r.stdout, formatTime(version.Time))
+		assert.Contains(t, r.stdout, integrityOK)
+	})
+
+	t.Run("should report corrupted version in JSON", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		// when
+		r := runTool(nil, "ls", "-json", dir)
+		// then
+		require.Equal(t, exitOK, r.code)
+		var statuses []versionStatus
+		require.NoError(t, json.Unmarshal([]byte(r.stdout), &statuses))
+		require.Len(t, statuses, 1)
+		assert.Equal(t, formatTime(version.Time), statuses[0].Time)
+		assert.Equal(t, int64(4), statuses[0].Size)
+		assert.Equal(t, integrityCorrupted, statuses[0].Integrity)
+		assert.NotEmpty(t, statuses[0].Error)
+	})
+}
+
+func TestCat(t *testing.T) {
+
+	t.Run("should write latest version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		tests.WriteData(t, s, []byte("old"))
+		tests.WriteData(t, s, []byte("new"))
+		// when
+		r := runTool(nil, "cat", dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		assert.Equal(t, "new", r.stdout)
+	})
+
+	t.Run("should write version with given time", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		old := tests.WriteData(t, s, []byte("old"))
+		tests.WriteData(t, s, []byte("new"))
+		// when
+		r := runTool(nil, "cat", "-time", formatTime(old.Time), dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		assert.Equal(t, "old", r.stdout)
+	})
+
+	t.Run("should write entry", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		w, err := s.EntriesWriter()
+		require.NoError(t, err)
+		entry, err := w.Entry("users")
+		require.NoError(t, err)
+		_, err = entry.Write([]byte("alice"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		// when
+		r := runTool(nil, "cat", "-entry", "users", dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		assert.Equal(t, "alice", r.stdout)
+	})
+
+	t.Run("should fail when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		// when
+		r := runTool(nil, "cat", "-time", formatTime(version.Time), dir)
+		// then
+		assert.Equal(t, exitFailure, r.code)
+	})
+}
+
+func TestVerify(t *testing.T) {
+
+	t.Run("should succeed when all versions are intact", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		tests.WriteData(t, s, []byte("data"))
+		tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+		// when
+		r := runTool(nil, "verify", dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+	})
+
+	t.Run("should fail when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		// when
+		r := runTool(nil, "verify", dir)
+		// then
+		assert.Equal(t, exitFailure, r.code)
+		assert.Contains(t, r.stdout, integrityCorrupted)
+		assert.Contains(t, r.stderr, "1 of 1 versions failed verification")
+	})
+
+	t.Run("should verify only version with given time", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		intact := tests.WriteData(t, s, []byte("intact"))
+		// when
+		r := runTool(nil, "verify", "-time", formatTime(intact.Time), dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		assert.NotContains(t, r.stdout, formatTime(version.Time))
+	})
+
+	t.Run("should fail when version with given time does not exist", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		require.NoError(t, s.DeleteVersion(version.Time))
+		// when
+		r := runTool(nil, "verify", "-time", formatTime(version.Time), dir)
+		// then
+		assert.Equal(t, exitFailure, r.code)
+	})
+}
+
+func TestRm(t *testing.T) {
+
+	t.Run("should delete version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		r := runTool(nil, "rm", "-time", formatTime(version.Time), dir)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should print deleted version in JSON", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		r := runTool(nil, "rm", "-json", "-time", formatTime(version.Time), dir)
+		// then
+		require.Equal(t, exitOK, r.code, r.stderr)
+		assert.JSONEq(t, `{"deleted":"`+formatTime(version.Time)+`"}`, r.stdout)
+	})
+
+	t.Run("should require time", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		// when
+		r := runTool(nil, "rm", dir)
+		// then
+		assert.Equal(t, exitUsage, r.code)
+	})
+}
+
+func TestCompact(t *testing.T) {
+
+	t.Run("should delete old versions", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		tests.WriteData(t, s, []byte("old"))
+		latest := tests.WriteData(t, s, []byte("latest"))
+		// when
+		r := runTool(nil, "compact", "-json", dir)
+		// then
+		require.Equal(t, exitOK, r.code)
+		assert.JSONEq(t, `{"deleted":1}`, r.stdout)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.True(t, latest.Time.Equal(versions[0].Time))
+	})
+}
+
+func TestReplicate(t *testing.T) {
+
+	t.Run("should copy latest version", func(t *testing.T) {
+		from, to := tests.TempDir(t), tests.TempDir(t)
+		version := tests.WriteData(t, openTestStore(t, from), []byte("data"))
+		// when
+		r := runTool(nil, "replicate", from, to)
+		// then
+		assert.Equal(t, exitOK, r.code)
+		assert.Contains(t, r.stdout, "replicated "+formatTime(version.Time))
+		assert.Equal(t, []byte("data"), tests.ReadData(t, openTestStore(t, to)))
+	})
+
+	t.Run("should succeed when version was already replicated", func(t *testing.T) {
+		from, to := tests.TempDir(t), tests.TempDir(t)
+		tests.WriteData(t, openTestStore(t, from), []byte("data"))
+		require.Equal(t, exitOK, runTool(nil, "replicate", from, to).code)
+		// when
+		r := runTool(nil, "replicate", "-json", from, to)
+		// then
+		require.Equal(t, exitOK, r.code)
+		assert.Contains(t, r.stdout, `"alreadyReplicated": true`)
+	})
+}
+
+func TestExportImport(t *testing.T) {
+
+	t.Run("should export to stdout and import from stdin", func(t *testing.T) {
+		from, to := tests.TempDir(t), tests.TempDir(t)
+		version := tests.WriteData(t, openTestStore(t, from), []byte("data"))
+		// when
+		exported := runTool(nil, "export", from)
+		imported := runTool(strings.NewReader(exported.stdout), "import", to)
+		// then
+		require.Equal(t, exitOK, exported.code)
+		assert.Contains(t, exported.stderr, "exported "+formatTime(version.Time))
+		require.Equal(t, exitOK, imported.code)
+		assert.Contains(t, imported.stdout, "imported "+formatTime(version.Time))
+		assert.Equal(t, []byte("data"), tests.ReadData(t, openTestStore(t, to)))
+	})
+
+	t.Run("should export selected versions to file", func(t *testing.T) {
+		from, to := tests.TempDir(t), tests.TempDir(t)
+		s := openTestStore(t, from)
+		old := tests.WriteData(t, s, []byte("old"))
+		latest := tests.WriteData(t, s, []byte("latest"))
+		archive := path.Join(tests.TempDir(t), "archive.tar")
+		// when
+		exported := runTool(nil, "export", "-o", archive, "-after", formatTime(latest.Time), from)
+		imported := runTool(nil, "import", "-i", archive, "-json", to)
+		// then
+		require.Equal(t, exitOK, exported.code)
+		assert.NotContains(t, exported.stdout, formatTime(old.Time))
+		require.Equal(t, exitOK, imported.code)
+		var summaries []versionSummary
+		require.NoError(t, json.Unmarshal([]byte(imported.stdout), &summaries))
+		assert.Equal(t, []versionSummary{{Time: formatTime(latest.Time), Size: latest.Size}}, summaries)
+	})
+
+	t.Run("should skip existing versions", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		tests.WriteData(t, openTestStore(t, dir), []byte("data"))
+		archive := path.Join(tests.TempDir(t), "archive.tar")
+		require.Equal(t, exitOK, runTool(nil, "export", "-o", archive, dir).code)
+		// when
+		failed := runTool(nil, "import", "-i", archive, dir)
+		skipped := runTool(nil, "import", "-i", archive, "-skip-existing", dir)
+		// then
+		assert.Equal(t, exitFailure, failed.code)
+		assert.Equal(t, exitOK, skipped.code)
+	})
+}
+
+func TestFixChecksum(t *testing.T) {
+
+	t.Run("should update checksum of edited version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.UpdateFiles(t, dir, ".data", "edited")
+		// when
+		r := runTool(nil, "fix-checksum", "-time", formatTime(version.Time), dir)
+		// then
+		require.Equal(t, exitOK, r.code, r.stderr)
+		assert.NoError(t, s.Verify(version.Time))
+		assert.Equal(t, []byte("edited"), tests.ReadData(t, s))
+	})
+
+	t.Run("should print fixed version in JSON", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.UpdateFiles(t, dir, ".data", "edited")
+		// when
+		r := runTool(nil, "fix-checksum", "-json", "-time", formatTime(version.Time), dir)
+		// then
+		require.Equal(t, exitOK, r.code, r.stderr)
+		assert.JSONEq(t, `{"fixed":{"time":"`+formatTime(version.Time)+`","size":6}}`, r.stdout)
+	})
+
+	t.Run("should reject segmented version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		version := tests.WriteData(t, s, []byte("data"), store.SegmentSize(2))
+		// when
+		r := runTool(nil, "fix-checksum", "-time", formatTime(version.Time), dir)
+		// then
+		assert.Equal(t, exitFailure, r.code)
+		assert.Contains(t, r.stderr, "segmented")
+	})
+}
+
+func TestDiff(t *testing.T) {
+
+	t.Run("should compare latest version with previous one", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		require.NoError(t, deebeejson.Write(s, map[string]string{"name": "alice"}))
+		require.NoError(t, deebeejson.Write(s, map[string]string{"name": "bob"}))
+		// when
+		r := runTool(nil, "diff", dir)
+		// then
+		require.Equal(t, exitOK, r.code, r.stderr)
+		assert.Contains(t, r.stdout, `~ /name: "alice" -> "bob"`)
+	})
+
+	t.Run("should compare selected versions byte by byte", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s := openTestStore(t, dir)
+		from := tests.WriteData(t, s, []byte("abc"))
+		to := tests.WriteData(t, s, []byte("axc"))
+		tests.WriteData(t, s, []byte("latest"))
+		// when
+		r := runTool(nil, "diff", "-json", "-from", formatTime(from.Time), "-to", formatTime(to.Time), dir)
+		// then
+		require.Equal(t, exitOK, r.code, r.stderr)
+		var output diffOutput
+		require.NoError(t, json.Unmarshal([]byte(r.stdout), &output))
+		assert.False(t, output.JSON)
+		assert.False(t, output.Equal)
+		assert.Equal(t, []diff.Range{{Offset: 1, Length: 1}}, output.Ranges)
+	})
+
+	t.Run("should fail when there is no previous version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		tests.WriteData(t, openTestStore(t, dir), []byte("data"))
+		// when
+		r := runTool(nil, "diff", dir)
+		// then
+		assert.Equal(t, exitFailure, r.code)
+	})
+}
@@ -4,6 +4,7 @@
 package codec
 
 import (
+	"context"
 	"errors"
 	"io"
 
@@ -11,14 +12,24 @@ import (
 )
 
 func Read(s ReadOnlyStore, decoder Decoder, options ...store.ReaderOption) (store.Version, error) {
+	return ReadContext(context.Background(), s, decoder, options...)
+}
+
+// ReadContext is like Read but aborts reading once ctx is done. Blocked reads are aborted only when store
+// implements ReaderContext method (such as *store.Store does).
+func ReadContext(ctx context.Context, s ReadOnlyStore, decoder Decoder, options ...store.ReaderOption) (store.Version, error) {
 	if decoder == nil {
 		return store.Version{}, errors.New("nil decoder")
 	}
-	reader, err := s.Reader(options...)
+	reader, err := OpenReader(ctx, s, options...)
 	if err != nil {
 		return store.Version{}, err
 	}
-	err = decoder(reader)
+	return decodeAndClose(reader, decoder)
+}
+
+func decodeAndClose(reader store.Reader, decoder Decoder) (store.Version, error) {
+	err := decoder(reader)
 	if err != nil {
 		_ = reader.Close()
 		return store.Version{}, err
@@ -33,10 +44,16 @@ func Read(s ReadOnlyStore, decoder Decoder, options ...store.ReaderOption) (stor
 type Decoder func(reader io.Reader) error
 
 func Write(s WriteOnlyStore, encoder Encoder, options ...store.WriterOption) error {
+	return WriteContext(context.Background(), s, encoder, options...)
+}
+
+// WriteContext is like Write but aborts writing once ctx is done. Blocked writes are aborted only when store
+// implements WriterContext method (such as *store.Store does).
+func WriteContext(ctx context.Context, s WriteOnlyStore, encoder Encoder, options ...store.WriterOption) error {
 	if encoder == nil {
 		return errors.New("nil encoder")
 	}
-	writer, err := s.Writer(options...)
+	writer, err := OpenWriter(ctx, s, options...)
 	if err != nil {
 		return err
 	}
@@ -50,8 +67,14 @@ func Write(s WriteOnlyStore, encoder Encoder, options ...store.WriterOption) err
 
 type Encoder func(writer io.Writer) error
 
-// ReadLatest reads latest version or fallback to previous one when decoder returned error
+// ReadLatest reads latest version or fallback to previous one when decoder returned error. When store has Entries and
+// VerifyContext methods (such as *store.Store does), entries of the version must pass verification too.
 func ReadLatest(s ReadOnlyStore, decoder Decoder) (store.Version, error) {
+	return ReadLatestContext(context.Background(), s, decoder)
+}
+
+// ReadLatestContext is like ReadLatest but stops trying previous versions once ctx is done.
+func ReadLatestContext(ctx context.Context, s ReadOnlyStore, decoder Decoder) (store.Version, error) {
 	emptyVersion := store.Version{}
 	if decoder == nil {
 		return emptyVersion, errors.New("nil decoder")
@@ -59,8 +82,11 @@ func ReadLatest(s ReadOnlyStore, decoder Decoder) (store.Version, error) {
 	if s == nil {
 		return emptyVersion, errors.New("nil store")
 	}
-	versions, err := s.Versions()
+	versions, err := ListVersions(ctx, s)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return emptyVersion, ctxErr
+		}
 		return emptyVersion, store.NewVersionNotFoundErrorWithCause("listing versions failed", err)
 	}
 	if len(versions) == 0 {
@@ -68,12 +94,18 @@ func ReadLatest(s ReadOnlyStore, decoder Decoder) (store.Version, error) {
 	}
 	for i := len(versions) - 1; i >= 0; i-- {
 		version := versions[i]
-		_, err = Read(s, decoder, store.Time(version.Time))
+		_, err = ReadContext(ctx, s, decoder, store.Time(version.Time))
+		if err == nil {
+			err = verifyEntries(ctx, s, version.Time)
+		}
 		if err == nil {
 			return version, nil
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return emptyVersion, ctxErr
+		}
 	}
-	return emptyVersion, store.NewVersionNotFoundError("no version can be decoded")
+	return emptyVersion, store.NewVersionNotFoundErrorWithCause("no version can be decoded", err)
 }
 
 type ReadOnlyStore interface {
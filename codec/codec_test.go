@@ -4,9 +4,13 @@
 package codec_test
 
 import (
+	"context"
 	"errors"
 	"io"
+	"path"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/elgopher/deebee/codec"
 	"github.com/elgopher/deebee/internal/tests"
@@ -82,6 +86,103 @@ func TestRead(t *testing.T) {
 	})
 }
 
+func TestWriteContext(t *testing.T) {
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := codec.WriteContext(ctx, s, func(w io.Writer) error { return nil })
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should abort writing when context is cancelled during encoding", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		encoder := func(w io.Writer) error {
+			cancel()
+			_, e := w.Write([]byte("data"))
+			return e
+		}
+		// when
+		err := codec.WriteContext(ctx, s, encoder)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.Reader()
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should use Writer when store does not support context", func(t *testing.T) {
+		writer := &tests.WriterMock{}
+		s := &tests.StoreMock{ReturnWriter: writer}
+		// when
+		err := codec.WriteContext(context.Background(), s, func(w io.Writer) error { return nil })
+		// then
+		assert.NoError(t, err)
+	})
+}
+
+func TestReadContext(t *testing.T) {
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		decoder := &tests.FakeDecoder{}
+		// when
+		_, err := codec.ReadContext(ctx, s, decoder.Decode)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should read using decoder", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		input := []byte("input")
+		tests.WriteData(t, s, input)
+		decoder := &tests.FakeDecoder{}
+		// when
+		_, err := codec.ReadContext(context.Background(), s, decoder.Decode)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, input, decoder.DataRead())
+	})
+}
+
+func TestReadLatestContext(t *testing.T) {
+	t.Run("should return context error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("1"))
+		tests.WriteData(t, s, []byte("2"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		decoder := &tests.FakeDecoder{}
+		// when
+		_, err := codec.ReadLatestContext(ctx, s, decoder.Decode)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should pass context to store", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		tests.WriteData(t, s, []byte("data"))
+		ctx := tests.WithTraceID(context.Background(), "trace")
+		// when
+		_, err := codec.ReadLatestContext(ctx, s, (&tests.FakeDecoder{}).Decode)
+		// then
+		require.NoError(t, err)
+		ended := hooks.Ended()[2:] // skip writing
+		assert.Equal(t,
+			[]store.Operation{store.List, store.OpenReader, store.CloseReader},
+			[]store.Operation{ended[0].Operation, ended[1].Operation, ended[2].Operation})
+		for _, e := range ended {
+			assert.Equal(t, "trace", e.TraceID)
+		}
+	})
+}
+
 func TestReadLatest(t *testing.T) {
 	t.Run("should return error", func(t *testing.T) {
 		t.Run("when no decoder is given", func(t *testing.T) {
@@ -135,6 +236,20 @@ func TestReadLatest(t *testing.T) {
 			assert.True(t, store.IsVersionNotFound(err))
 		})
 
+		t.Run("with cause when all versions are corrupted", func(t *testing.T) {
+			dir := tests.TempDir(t)
+			s, err := store.Open(dir)
+			require.NoError(t, err)
+			tests.WriteData(t, s, []byte("data"))
+			tests.CorruptDataFiles(t, dir)
+			f := &tests.FakeDecoder{}
+			// when
+			_, err = codec.ReadLatest(s, f.Decode)
+			// then
+			assert.True(t, store.IsVersionNotFound(err))
+			assert.True(t, store.IsChecksumMismatch(err))
+		})
+
 		t.Run("when decoder returned error for two versions", func(t *testing.T) {
 			s := &tests.StoreMock{
 				ReturnVersions: []store.Version{{}, {}},
@@ -195,6 +310,61 @@ func TestReadLatest(t *testing.T) {
 		assert.True(t, firstVersion.Time.Equal(actualVersion.Time))
 		assert.Equal(t, firstData, f.DataRead())
 	})
+
+	t.Run("should read previous version when entry of last one is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		firstVersion := tests.WriteData(t, s, []byte("data"))
+		err = codec.WriteEntries(s, map[string]codec.Encoder{"entry": func(writer io.Writer) error {
+			_, err := writer.Write([]byte("entry"))
+			return err
+		}})
+		require.NoError(t, err)
+		files, err := filepath.Glob(path.Join(dir, "*.entry"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		tests.CorruptFile(t, files[0]) // manifest in data file is still intact
+		f := &tests.FakeDecoder{}
+		// when
+		actualVersion, err := codec.ReadLatest(s, f.Decode)
+		// then
+		assert.NoError(t, err)
+		assert.True(t, firstVersion.Time.Equal(actualVersion.Time))
+		assert.Equal(t, []byte("data"), f.DataRead())
+	})
+
+	t.Run("should not verify version without entries again", func(t *testing.T) {
+		options := map[string][]store.WriterOption{
+			"plain":     nil,
+			"segmented": {store.SegmentSize(2)},
+		}
+		for name, writerOptions := range options {
+			writerOptions := writerOptions
+			t.Run(name, func(t *testing.T) {
+				s := &verifyRecordingStore{Store: tests.OpenStore(t)}
+				tests.WriteData(t, s.Store, []byte("data"), writerOptions...)
+				f := &tests.FakeDecoder{}
+				// when
+				_, err := codec.ReadLatest(s, f.Decode)
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []byte("data"), f.DataRead())
+				assert.Zero(t, s.verified)
+			})
+		}
+	})
+}
+
+// verifyRecordingStore records how many times versions were verified
+type verifyRecordingStore struct {
+	*store.Store
+	verified int
+}
+
+func (s *verifyRecordingStore) VerifyContext(ctx context.Context, t time.Time) error {
+	s.verified++
+	return s.Store.VerifyContext(ctx, t)
 }
 
 func failingDecoder(io.Reader) error {
@@ -0,0 +1,135 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package codec
+
+import (
+	"context"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+type readerContextStore interface {
+	ReaderContext(context.Context, ...store.ReaderOption) (store.Reader, error)
+}
+
+type versionsContextStore interface {
+	VersionsContext(context.Context) ([]store.Version, error)
+}
+
+type deleteVersionContextStore interface {
+	DeleteVersionContext(context.Context, time.Time) error
+}
+
+// verifierContextStore checks the whole version, including entries and segments not read by Reader
+type verifierContextStore interface {
+	VerifyContext(context.Context, time.Time) error
+}
+
+// entriesStore lists entries of versions written using EntriesWriter
+type entriesStore interface {
+	Entries(time.Time) ([]store.Entry, error)
+}
+
+type entryReaderContextStore interface {
+	EntryReaderContext(context.Context, string, ...store.ReaderOption) (store.Reader, error)
+}
+
+type entriesWriterContextStore interface {
+	EntriesWriterContext(context.Context, ...store.WriterOption) (store.EntriesWriter, error)
+}
+
+type writerContextStore interface {
+	WriterContext(context.Context, ...store.WriterOption) (store.Writer, error)
+}
+
+// VersionLister is implemented by *store.Store and every ReadOnlyStore
+type VersionLister interface {
+	Versions() ([]store.Version, error)
+}
+
+// VersionDeleter is implemented by *store.Store
+type VersionDeleter interface {
+	DeleteVersion(time.Time) error
+}
+
+// OpenReader opens Reader using ReaderContext method when store has one (such as *store.Store does). Otherwise,
+// Reader is opened without context, once ctx was checked.
+func OpenReader(ctx context.Context, s ReadOnlyStore, options ...store.ReaderOption) (store.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c, ok := s.(readerContextStore); ok {
+		return c.ReaderContext(ctx, options...)
+	}
+	return s.Reader(options...)
+}
+
+// OpenWriter opens Writer using WriterContext method when store has one (such as *store.Store does). Otherwise,
+// Writer is opened without context, once ctx was checked.
+func OpenWriter(ctx context.Context, s WriteOnlyStore, options ...store.WriterOption) (store.Writer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c, ok := s.(writerContextStore); ok {
+		return c.WriterContext(ctx, options...)
+	}
+	return s.Writer(options...)
+}
+
+// ListVersions lists versions using VersionsContext method when store has one (such as *store.Store does).
+func ListVersions(ctx context.Context, s VersionLister) ([]store.Version, error) {
+	if c, ok := s.(versionsContextStore); ok {
+		return c.VersionsContext(ctx)
+	}
+	return s.Versions()
+}
+
+// DeleteVersion deletes version using DeleteVersionContext method when store has one (such as *store.Store does).
+func DeleteVersion(ctx context.Context, s VersionDeleter, t time.Time) error {
+	if c, ok := s.(deleteVersionContextStore); ok {
+		return c.DeleteVersionContext(ctx, t)
+	}
+	return s.DeleteVersion(t)
+}
+
+func openEntryReader(ctx context.Context, s EntryReadOnlyStore, name string, options []store.ReaderOption) (store.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c, ok := s.(entryReaderContextStore); ok {
+		return c.EntryReaderContext(ctx, name, options...)
+	}
+	return s.EntryReader(name, options...)
+}
+
+func openEntriesWriter(ctx context.Context, s EntriesWriteOnlyStore, options []store.WriterOption) (store.EntriesWriter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c, ok := s.(entriesWriterContextStore); ok {
+		return c.EntriesWriterContext(ctx, options...)
+	}
+	return s.EntriesWriter(options...)
+}
+
+// verifyEntries verifies the whole version when it has entries, because Reader reads only its manifest. Plain and
+// segmented versions are not verified again, because their checksums were already checked when Reader was closed.
+// Nil is returned when store cannot list entries or verify versions.
+func verifyEntries(ctx context.Context, s ReadOnlyStore, t time.Time) error {
+	v, ok := s.(verifierContextStore)
+	if !ok {
+		return nil
+	}
+	e, ok := s.(entriesStore)
+	if !ok {
+		return nil
+	}
+	if _, err := e.Entries(t); store.IsVersionNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return v.VerifyContext(ctx, t)
+}
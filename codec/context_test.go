@@ -0,0 +1,74 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package codec_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenReader(t *testing.T) {
+	t.Run("should return context error when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		_, err := codec.OpenReader(ctx, &tests.StoreMock{ReturnReader: &tests.ReaderMock{}})
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should open reader of store without context methods", func(t *testing.T) {
+		reader := &tests.ReaderMock{}
+		// when
+		actual, err := codec.OpenReader(context.Background(), &tests.StoreMock{ReturnReader: reader})
+		// then
+		require.NoError(t, err)
+		assert.Same(t, reader, actual)
+	})
+
+	t.Run("should pass context to store", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		reader, err := codec.OpenReader(ctx, s)
+		require.NoError(t, err)
+		defer reader.Close()
+		// when
+		cancel()
+		_, err = reader.Read(make([]byte, 1))
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestListVersions(t *testing.T) {
+	t.Run("should list versions of store without context methods", func(t *testing.T) {
+		versions := []store.Version{{Size: 1}}
+		// when
+		actual, err := codec.ListVersions(context.Background(), &tests.StoreMock{ReturnVersions: versions})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, versions, actual)
+	})
+}
+
+func TestDeleteVersion(t *testing.T) {
+	t.Run("should delete version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		err := codec.DeleteVersion(context.Background(), s, version.Time)
+		// then
+		require.NoError(t, err)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+}
@@ -0,0 +1,76 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package codec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/elgopher/deebee/store"
+)
+
+// WriteEntries writes a multi-entry version with one entry for each encoder. Entries are written in alphabetical
+// order of names. Version is aborted when any of encoders returned error.
+func WriteEntries(s EntriesWriteOnlyStore, encoders map[string]Encoder, options ...store.WriterOption) error {
+	return WriteEntriesContext(context.Background(), s, encoders, options...)
+}
+
+// WriteEntriesContext is like WriteEntries but aborts writing once ctx is done.
+func WriteEntriesContext(ctx context.Context, s EntriesWriteOnlyStore, encoders map[string]Encoder, options ...store.WriterOption) error {
+	if len(encoders) == 0 {
+		return errors.New("no encoders")
+	}
+	names := make([]string, 0, len(encoders))
+	for name, encoder := range encoders {
+		if encoder == nil {
+			return fmt.Errorf("nil encoder for entry %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writer, err := openEntriesWriter(ctx, s, options)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		entry, err := writer.Entry(name)
+		if err != nil {
+			writer.AbortAndClose()
+			return err
+		}
+		if err = encoders[name](entry); err != nil {
+			writer.AbortAndClose()
+			return fmt.Errorf("error encoding entry %s: %w", name, err)
+		}
+	}
+	return writer.Close()
+}
+
+// ReadEntry reads a single entry of a multi-entry version. By default, entry of the latest version is read.
+func ReadEntry(s EntryReadOnlyStore, name string, decoder Decoder, options ...store.ReaderOption) (store.Version, error) {
+	return ReadEntryContext(context.Background(), s, name, decoder, options...)
+}
+
+// ReadEntryContext is like ReadEntry but aborts reading once ctx is done.
+func ReadEntryContext(ctx context.Context, s EntryReadOnlyStore, name string, decoder Decoder, options ...store.ReaderOption) (store.Version, error) {
+	if decoder == nil {
+		return store.Version{}, errors.New("nil decoder")
+	}
+	reader, err := openEntryReader(ctx, s, name, options)
+	if err != nil {
+		return store.Version{}, err
+	}
+	return decodeAndClose(reader, decoder)
+}
+
+type EntriesWriteOnlyStore interface {
+	EntriesWriter(...store.WriterOption) (store.EntriesWriter, error)
+}
+
+type EntryReadOnlyStore interface {
+	EntryReader(name string, options ...store.ReaderOption) (store.Reader, error)
+}
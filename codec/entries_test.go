@@ -0,0 +1,123 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package codec_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEntries(t *testing.T) {
+	t.Run("should return error when no encoders are given", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		err := codec.WriteEntries(s, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when nil encoder is given", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		err := codec.WriteEntries(s, map[string]codec.Encoder{"users": nil})
+		assert.Error(t, err)
+	})
+
+	t.Run("should write each entry using its encoder", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		encoders := map[string]codec.Encoder{
+			"users":  bytesEncoder("alice"),
+			"orders": bytesEncoder("1,2,3"),
+		}
+		// when
+		err := codec.WriteEntries(s, encoders)
+		// then
+		require.NoError(t, err)
+		users := &tests.FakeDecoder{}
+		_, err = codec.ReadEntry(s, "users", users.Decode)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("alice"), users.DataRead())
+		orders := &tests.FakeDecoder{}
+		_, err = codec.ReadEntry(s, "orders", orders.Decode)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1,2,3"), orders.DataRead())
+	})
+
+	t.Run("should abort writing all entries on encoding error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		encoders := map[string]codec.Encoder{
+			"a": bytesEncoder("data"),
+			"b": func(io.Writer) error { return errors.New("failed") },
+		}
+		// when
+		err := codec.WriteEntries(s, encoders)
+		// then
+		assert.Error(t, err)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := codec.WriteEntriesContext(ctx, s, map[string]codec.Encoder{"a": bytesEncoder("data")})
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestReadEntry(t *testing.T) {
+	t.Run("should return error when no decoder is given", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		_, err := codec.ReadEntry(s, "users", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error on decoding error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		require.NoError(t, codec.WriteEntries(s, map[string]codec.Encoder{"users": bytesEncoder("alice")}))
+		// when
+		_, err := codec.ReadEntry(s, "users", failingDecoder)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		require.NoError(t, codec.WriteEntries(s, map[string]codec.Encoder{"users": bytesEncoder("alice")}))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		_, err := codec.ReadEntryContext(ctx, s, "users", (&tests.FakeDecoder{}).Decode)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should return version of entry", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		require.NoError(t, codec.WriteEntries(s, map[string]codec.Encoder{"users": bytesEncoder("alice")}))
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		// when
+		version, err := codec.ReadEntry(s, "users", (&tests.FakeDecoder{}).Decode)
+		// then
+		require.NoError(t, err)
+		assert.True(t, versions[0].Time.Equal(version.Time))
+		assert.Equal(t, int64(5), version.Size)
+	})
+}
+
+func bytesEncoder(data string) codec.Encoder {
+	return func(w io.Writer) error {
+		_, err := w.Write([]byte(data))
+		return err
+	}
+}
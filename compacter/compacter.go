@@ -12,39 +12,92 @@ import (
 
 	"github.com/elgopher/deebee/codec"
 	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/deebee/throttle"
 )
 
 func RunOnce(s Store, options ...Option) error {
+	return RunOnceContext(context.Background(), s, options...)
+}
+
+// RunOnceContext is like RunOnce but stops compacting once ctx is done.
+func RunOnceContext(ctx context.Context, s Store, options ...Option) error {
 	if s == nil {
 		return errors.New("nil store")
 	}
 
-	_, err := applyOptions(options)
+	opts, err := applyOptions(options)
 	if err != nil {
 		return err
 	}
+	if _, ok := s.(NamespacedStore); opts.allNamespaces && !ok {
+		return errors.New("store does not support namespaces")
+	}
+
+	start := time.Now()
+	deleted, err := runOnce(ctx, s, opts)
+	if opts.collector != nil {
+		opts.collector.observeRun(time.Since(start), deleted, err)
+	}
+	return err
+}
+
+// runOnce returns the number of deleted versions, also when error was returned
+func runOnce(ctx context.Context, s Store, opts *Options) (int, error) {
+	deleted, err := compact(ctx, s, opts)
+	if !opts.allNamespaces || ctx.Err() != nil {
+		return deleted, err
+	}
 
-	versions, err := s.Versions()
+	namespaced := s.(NamespacedStore)
+	names, nsErr := namespaced.Namespaces()
+	if nsErr != nil {
+		return deleted, fmt.Errorf("error listing namespaces: %w", nsErr)
+	}
+	for _, name := range names {
+		var n int
+		ns, nsErr := namespaced.Namespace(name)
+		if nsErr == nil {
+			n, nsErr = runOnce(ctx, ns, opts)
+			deleted += n
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return deleted, ctxErr
+		}
+		if nsErr != nil && err == nil {
+			err = fmt.Errorf("error compacting namespace %s: %w", name, nsErr)
+		}
+	}
+	return deleted, err
+}
+
+// compact deletes versions of a single store, without namespaces
+func compact(ctx context.Context, s Store, opts *Options) (int, error) {
+	versions, err := codec.ListVersions(ctx, s)
 	if err != nil {
-		return fmt.Errorf("error getting versions: %w", err)
+		return 0, fmt.Errorf("error getting versions: %w", err)
 	}
 
+	deleted := 0
 	if len(versions) > 1 {
-		latestVersion, err := codec.ReadLatest(s, readAllDiscarding)
+		latestVersion, err := codec.ReadLatestContext(ctx, s, opts.decoder(ctx))
 		if err != nil {
-			return fmt.Errorf("error getting latest integral version: %w", err)
+			return 0, fmt.Errorf("error getting latest integral version: %w", err)
 		}
 		for _, v := range versions {
 			if v == latestVersion {
-				return nil
+				return deleted, nil
 			}
-			if err := s.DeleteVersion(v.Time); err != nil {
-				return fmt.Errorf("error when deleting version: %w", err)
+			if err := ctx.Err(); err != nil {
+				return deleted, err
 			}
+			if err := codec.DeleteVersion(ctx, s, v.Time); err != nil {
+				return deleted, fmt.Errorf("error when deleting version: %w", err)
+			}
+			deleted++
 		}
 	}
 
-	return nil
+	return deleted, nil
 }
 
 func Start(ctx context.Context, s Store, options ...Option) error {
@@ -56,11 +109,14 @@ func Start(ctx context.Context, s Store, options ...Option) error {
 	if err != nil {
 		return err
 	}
+	if _, ok := s.(NamespacedStore); opts.allNamespaces && !ok {
+		return errors.New("store does not support namespaces")
+	}
 
 	for {
 		select {
 		case <-time.After(opts.interval):
-			if err := RunOnce(s, options...); err != nil {
+			if err := RunOnceContext(ctx, s, options...); err != nil && ctx.Err() == nil {
 				log.WithError(err).Error(ctx, "compacter.RunOnce failed")
 			}
 		case <-ctx.Done():
@@ -69,16 +125,58 @@ func Start(ctx context.Context, s Store, options ...Option) error {
 	}
 }
 
+// Reclaim returns store.ReclaimPolicy deleting versions the same way as RunOnce does. It can be used with
+// store.ReclaimSpace option to free space when the disk is full.
+func Reclaim(options ...Option) store.ReclaimPolicy {
+	return func(s *store.Store) error {
+		return RunOnce(s, options...)
+	}
+}
+
 type Store interface {
 	Reader(...store.ReaderOption) (store.Reader, error)
 	Versions() ([]store.Version, error)
 	DeleteVersion(time.Time) error
 }
 
+// NamespacedStore is implemented by *store.Store
+type NamespacedStore interface {
+	Namespaces() ([]string, error)
+	Namespace(name string) (*store.Store, error)
+}
+
 type Option func(options *Options) error
 
 type Options struct {
-	interval time.Duration
+	interval      time.Duration
+	limiter       throttle.Limiter
+	collector     *Collector
+	allNamespaces bool
+}
+
+// AllNamespaces compacts all namespaces of the store, including nested ones, in addition to the store itself.
+// Compacting continues when one of namespaces failed, and the first error is returned. The store must implement
+// NamespacedStore.
+var AllNamespaces Option = func(options *Options) error {
+	options.allNamespaces = true
+	return nil
+}
+
+// Throttle limits the number of bytes per second read when looking for the latest integral version.
+func Throttle(limiter throttle.Limiter) Option {
+	return func(options *Options) error {
+		options.limiter = limiter
+		return nil
+	}
+}
+
+func (o *Options) decoder(ctx context.Context) codec.Decoder {
+	if o.limiter == nil {
+		return readAllDiscarding
+	}
+	return func(reader io.Reader) error {
+		return readAllDiscarding(throttle.Reader(ctx, reader, o.limiter))
+	}
 }
 
 func Interval(d time.Duration) Option {
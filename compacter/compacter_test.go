@@ -6,9 +6,13 @@ package compacter_test
 import (
 	"context"
 	"errors"
+	"io"
+	"path"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/elgopher/deebee/codec"
 	"github.com/elgopher/deebee/compacter"
 	"github.com/elgopher/deebee/internal/tests"
 	"github.com/elgopher/deebee/store"
@@ -46,6 +50,27 @@ func TestRunOnce(t *testing.T) {
 		assert.Len(t, versions, 1)
 	})
 
+	t.Run("should return checksum mismatch error when all versions are corrupted", func(t *testing.T) {
+		s := storeWithAllVersionsCorrupted(t)
+		// when
+		err := compacter.RunOnce(s)
+		// then
+		var mismatch store.ChecksumMismatchError
+		assert.True(t, errors.As(err, &mismatch))
+	})
+
+	t.Run("should throttle reading", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("v1"))
+		tests.WriteData(t, s, []byte("v2"))
+		limiter := &tests.LimiterMock{}
+		// when
+		err := compacter.RunOnce(s, compacter.Throttle(limiter))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2, limiter.Bytes())
+	})
+
 	t.Run("should remove all versions up to latest integral one", func(t *testing.T) {
 		s := storeWithLastVersionCorrupted(t)
 		// when
@@ -56,6 +81,75 @@ func TestRunOnce(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, versions, 2) // one integral and one corrupted
 	})
+
+	t.Run("should not remove versions older than latest one with corrupted entry", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("intact"))
+		err = codec.WriteEntries(s, map[string]codec.Encoder{"entry": func(writer io.Writer) error {
+			_, err := writer.Write([]byte("corrupted"))
+			return err
+		}})
+		require.NoError(t, err)
+		files, err := filepath.Glob(path.Join(dir, "*.entry"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		tests.CorruptFile(t, files[0]) // manifest in data file is still intact
+		// when
+		err = compacter.RunOnce(s)
+		// then
+		require.NoError(t, err)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+}
+
+func TestRunOnceContext(t *testing.T) {
+
+	t.Run("should not delete versions when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("v1"))
+		tests.WriteData(t, s, []byte("v2"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := compacter.RunOnceContext(ctx, s)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, numberOfVersions(s, 2)())
+	})
+
+	t.Run("should pass context to store", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		tests.WriteData(t, s, []byte("v1"))
+		tests.WriteData(t, s, []byte("v2"))
+		ctx := tests.WithTraceID(context.Background(), "trace")
+		// when
+		err := compacter.RunOnceContext(ctx, s)
+		// then
+		require.NoError(t, err)
+		ended := hooks.Ended()[4:] // skip writing
+		assert.Contains(t, hooks.Operations(), store.Delete)
+		for _, e := range ended {
+			assert.Equal(t, "trace", e.TraceID, "operation %s", e.Operation)
+		}
+	})
+}
+
+func TestReclaim(t *testing.T) {
+
+	t.Run("should delete old versions when there is not enough space in store", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxStoreSize(50), store.ReclaimSpace(compacter.Reclaim()))
+		tests.WriteData(t, s, make([]byte, 20))
+		tests.WriteData(t, s, make([]byte, 20))
+		// when
+		tests.WriteData(t, s, make([]byte, 20))
+		// then
+		assert.True(t, numberOfVersions(s, 2)())
+	})
 }
 
 func TestStart(t *testing.T) {
@@ -141,6 +235,16 @@ func storeWithLastVersionCorrupted(t *testing.T) *store.Store {
 	return s
 }
 
+func storeWithAllVersionsCorrupted(t *testing.T) *store.Store {
+	dir := tests.TempDir(t)
+	s, err := store.Open(dir)
+	require.NoError(t, err)
+	tests.WriteData(t, s, []byte("v1"))
+	tests.WriteData(t, s, []byte("v2"))
+	tests.CorruptDataFiles(t, dir)
+	return s
+}
+
 func numberOfVersions(s *store.Store, l int) func() bool {
 	return func() bool {
 		versions, err := s.Versions()
@@ -150,3 +254,64 @@ func numberOfVersions(s *store.Store, l int) func() bool {
 		return len(versions) == l
 	}
 }
+
+func TestAllNamespaces(t *testing.T) {
+
+	t.Run("should return error when store does not support namespaces", func(t *testing.T) {
+		s := &compacterStoreMock{}
+		// when
+		err := compacter.RunOnce(s, compacter.AllNamespaces)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should compact store and all its namespaces", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		users, err := s.Namespace("users")
+		require.NoError(t, err)
+		nested, err := users.Namespace("nested")
+		require.NoError(t, err)
+		for _, st := range []*store.Store{s, users, nested} {
+			tests.WriteData(t, st, []byte("v1"))
+			tests.WriteData(t, st, []byte("v2"))
+		}
+		collector := &compacter.Collector{}
+		// when
+		err = compacter.RunOnce(s, compacter.AllNamespaces, compacter.Collect(collector))
+		// then
+		require.NoError(t, err)
+		assert.True(t, numberOfVersions(s, 1)())
+		assert.True(t, numberOfVersions(users, 1)())
+		assert.True(t, numberOfVersions(nested, 1)())
+		assert.Equal(t, 3, collector.Metrics().DeletedVersions)
+	})
+
+	t.Run("should compact remaining namespaces when one failed", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		a, err := s.Namespace("a")
+		require.NoError(t, err)
+		b, err := s.Namespace("b")
+		require.NoError(t, err)
+		tests.WriteData(t, a, []byte("v1"))
+		tests.WriteData(t, a, []byte("v2"))
+		tests.CorruptDataFiles(t, path.Join(dir, "a"))
+		tests.WriteData(t, b, []byte("v1"))
+		tests.WriteData(t, b, []byte("v2"))
+		// when
+		err = compacter.RunOnce(s, compacter.AllNamespaces)
+		// then
+		assert.ErrorContains(t, err, "namespace a")
+		assert.True(t, numberOfVersions(a, 2)())
+		assert.True(t, numberOfVersions(b, 1)())
+	})
+}
+
+type compacterStoreMock struct {
+	tests.StoreMock
+}
+
+func (s *compacterStoreMock) DeleteVersion(time.Time) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package compacter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+type Metrics struct {
+	Runs            int       // Number of compacter runs
+	Failed          int       // Number of runs which returned error (including runs stopped because context was done)
+	DeletedVersions int       // Number of versions deleted by all runs
+	LastSuccess     time.Time // Time when the last successful run finished. Zero if there was no successful run
+	TotalTime       time.Duration
+	Latency         store.Histogram
+}
+
+// Collector collects Metrics of compacter runs. Zero value is ready to use. Collector is safe for concurrent use,
+// so metrics can be read while compacter is running in the background.
+type Collector struct {
+	mutex   sync.Mutex
+	metrics Metrics
+}
+
+// Collect updates metrics of collector after each run.
+func Collect(collector *Collector) Option {
+	return func(options *Options) error {
+		options.collector = collector
+		return nil
+	}
+}
+
+// Metrics returns a copy of metrics
+func (c *Collector) Metrics() Metrics {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.metrics
+}
+
+func (c *Collector) observeRun(elapsed time.Duration, deleted int, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.metrics.Runs++
+	c.metrics.DeletedVersions += deleted
+	c.metrics.TotalTime += elapsed
+	c.metrics.Latency.Observe(elapsed)
+	if err != nil {
+		c.metrics.Failed++
+	} else {
+		c.metrics.LastSuccess = time.Now()
+	}
+}
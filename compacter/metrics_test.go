@@ -0,0 +1,67 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package compacter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+
+	t.Run("should collect metrics of successful run", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("v1"))
+		tests.WriteData(t, s, []byte("v2"))
+		tests.WriteData(t, s, []byte("v3"))
+		collector := &compacter.Collector{}
+		before := time.Now()
+		// when
+		err := compacter.RunOnce(s, compacter.Collect(collector))
+		// then
+		require.NoError(t, err)
+		metrics := collector.Metrics()
+		assert.Equal(t, 1, metrics.Runs)
+		assert.Equal(t, 0, metrics.Failed)
+		assert.Equal(t, 2, metrics.DeletedVersions)
+		assert.False(t, metrics.LastSuccess.Before(before))
+		assert.Equal(t, 1, metrics.Latency.Count)
+		assert.Equal(t, metrics.TotalTime, metrics.Latency.Sum)
+	})
+
+	t.Run("should collect metrics of failed run", func(t *testing.T) {
+		s := storeWithAllVersionsCorrupted(t)
+		collector := &compacter.Collector{}
+		// when
+		err := compacter.RunOnce(s, compacter.Collect(collector))
+		// then
+		require.Error(t, err)
+		metrics := collector.Metrics()
+		assert.Equal(t, 1, metrics.Runs)
+		assert.Equal(t, 1, metrics.Failed)
+		assert.Equal(t, 0, metrics.DeletedVersions)
+		assert.True(t, metrics.LastSuccess.IsZero())
+	})
+
+	t.Run("should accumulate metrics of many runs", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		collector := &compacter.Collector{}
+		for i := 0; i < 2; i++ {
+			tests.WriteData(t, s, []byte("v1"))
+			tests.WriteData(t, s, []byte("v2"))
+			// when
+			err := compacter.RunOnce(s, compacter.Collect(collector))
+			require.NoError(t, err)
+		}
+		// then
+		metrics := collector.Metrics()
+		assert.Equal(t, 2, metrics.Runs)
+		assert.Equal(t, 3, metrics.DeletedVersions)
+	})
+}
@@ -0,0 +1,125 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package diff
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+const chunkSize = 32 * 1024
+
+// Range is a range of bytes which differ between versions. When one version is longer, its remaining bytes are
+// reported as the last range.
+type Range struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// Bytes compares versions byte by byte at the same offsets, so inserting a byte makes all following bytes differ.
+// Versions are streamed, without loading them into memory. Adjacent differing bytes are merged into one Range.
+func Bytes(s codec.ReadOnlyStore, from, to time.Time) (Result, error) {
+	return BytesContext(context.Background(), s, from, to)
+}
+
+// BytesContext is like Bytes but aborts reading once ctx is done.
+func BytesContext(ctx context.Context, s codec.ReadOnlyStore, from, to time.Time) (Result, error) {
+	oldReader, err := codec.OpenReader(ctx, s, store.Time(from))
+	if err != nil {
+		return Result{}, err
+	}
+	newReader, err := codec.OpenReader(ctx, s, store.Time(to))
+	if err != nil {
+		_ = oldReader.Close()
+		return Result{}, err
+	}
+	ranges, err := compareBytes(ctx, oldReader, newReader)
+	// closing reader validates the checksum once again
+	if closeErr := oldReader.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := newReader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{From: oldReader.Version(), To: newReader.Version(), Ranges: ranges}, nil
+}
+
+func compareBytes(ctx context.Context, oldReader, newReader io.Reader) ([]Range, error) {
+	var (
+		ranges  rangeList
+		offset  int64
+		oldData = make([]byte, chunkSize)
+		newData = make([]byte, chunkSize)
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		oldN, oldErr := readChunk(oldReader, oldData)
+		if oldErr != nil {
+			return nil, oldErr
+		}
+		newN, newErr := readChunk(newReader, newData)
+		if newErr != nil {
+			return nil, newErr
+		}
+
+		n := oldN
+		if newN < n {
+			n = newN
+		}
+		for i := 0; i < n; i++ {
+			if oldData[i] != newData[i] {
+				ranges.add(offset+int64(i), 1)
+			}
+		}
+		offset += int64(n)
+
+		if oldN != newN {
+			// one version has ended, the rest of the longer one differs
+			longer := oldReader
+			if newN > oldN {
+				longer = newReader
+			}
+			rest, err := io.Copy(ioutil.Discard, longer)
+			if err != nil {
+				return nil, err
+			}
+			length := int64(oldN+newN-2*n) + rest
+			ranges.add(offset, length)
+			return ranges, nil
+		}
+		if n < chunkSize {
+			return ranges, nil
+		}
+	}
+}
+
+// readChunk reads until chunk is full or reader ends. Only errors other than io.EOF are returned.
+func readChunk(reader io.Reader, chunk []byte) (int, error) {
+	n, err := io.ReadFull(reader, chunk)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, nil
+	}
+	return n, err
+}
+
+type rangeList []Range
+
+func (l *rangeList) add(offset, length int64) {
+	last := len(*l) - 1
+	if last >= 0 && (*l)[last].Offset+(*l)[last].Length == offset {
+		(*l)[last].Length += length
+		return
+	}
+	*l = append(*l, Range{Offset: offset, Length: length})
+}
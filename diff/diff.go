@@ -0,0 +1,59 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package diff compares two versions of a store. Versions written using json package are compared structurally,
+// other versions are compared byte by byte.
+package diff
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+// Result is a difference between two versions. Changes are set when both versions contain JSON, Ranges otherwise.
+type Result struct {
+	From    store.Version
+	To      store.Version
+	JSON    bool
+	Changes []Change
+	Ranges  []Range
+}
+
+// Equal returns true when versions have the same content
+func (r Result) Equal() bool {
+	return len(r.Changes) == 0 && len(r.Ranges) == 0
+}
+
+// Versions compares version from with version to. When both versions contain a single JSON value, they are compared
+// structurally like in JSON. Otherwise, they are compared byte by byte like in Bytes. Integrity of both versions is
+// verified while reading.
+func Versions(s codec.ReadOnlyStore, from, to time.Time) (Result, error) {
+	return VersionsContext(context.Background(), s, from, to)
+}
+
+// VersionsContext is like Versions but aborts reading once ctx is done.
+func VersionsContext(ctx context.Context, s codec.ReadOnlyStore, from, to time.Time) (Result, error) {
+	result, err := JSONContext(ctx, s, from, to)
+	if !IsNotJSON(err) {
+		return result, err
+	}
+	return BytesContext(ctx, s, from, to)
+}
+
+type notJSONError struct {
+	msg string
+}
+
+func (e notJSONError) Error() string {
+	return e.msg
+}
+
+// IsNotJSON returns true when JSON was used to compare a version which does not contain a single JSON value
+func IsNotJSON(err error) bool {
+	var notJSON notJSONError
+	return errors.As(err, &notJSON)
+}
@@ -0,0 +1,254 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package diff_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/elgopher/deebee/diff"
+	"github.com/elgopher/deebee/internal/tests"
+	deebeejson "github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeJSON(t *testing.T, s *store.Store, in interface{}) store.Version {
+	require.NoError(t, deebeejson.Write(s, in))
+	versions, err := s.Versions()
+	require.NoError(t, err)
+	return versions[len(versions)-1]
+}
+
+func TestJSON(t *testing.T) {
+
+	t.Run("should return no changes for equal documents", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, map[string]interface{}{"name": "alice", "tags": []string{"a"}})
+		to := writeJSON(t, s, map[string]interface{}{"name": "alice", "tags": []string{"a"}})
+		// when
+		result, err := diff.JSON(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.True(t, result.JSON)
+		assert.True(t, result.Equal())
+		assert.True(t, from.Time.Equal(result.From.Time))
+		assert.True(t, to.Time.Equal(result.To.Time))
+	})
+
+	t.Run("should return added, removed and modified values", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, map[string]interface{}{
+			"name":  "alice",
+			"age":   30,
+			"roles": []string{"admin", "user"},
+		})
+		to := writeJSON(t, s, map[string]interface{}{
+			"name":  "bob",
+			"email": "bob@example.com",
+			"roles": []string{"admin"},
+		})
+		// when
+		result, err := diff.JSON(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		expected := []diff.Change{
+			{Path: "/age", Kind: diff.Removed, Old: json.Number("30")},
+			{Path: "/email", Kind: diff.Added, New: "bob@example.com"},
+			{Path: "/name", Kind: diff.Modified, Old: "alice", New: "bob"},
+			{Path: "/roles/1", Kind: diff.Removed, Old: "user"},
+		}
+		assert.Equal(t, expected, result.Changes)
+	})
+
+	t.Run("should compare nested objects", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, map[string]interface{}{"users": []interface{}{map[string]string{"name": "alice"}}})
+		to := writeJSON(t, s, map[string]interface{}{"users": []interface{}{map[string]string{"name": "ally"}}})
+		// when
+		result, err := diff.JSON(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []diff.Change{{Path: "/users/0/name", Kind: diff.Modified, Old: "alice", New: "ally"}}, result.Changes)
+	})
+
+	t.Run("should escape keys in path", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, map[string]int{"a/b~c": 1})
+		to := writeJSON(t, s, map[string]int{"a/b~c": 2})
+		// when
+		result, err := diff.JSON(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result.Changes, 1)
+		assert.Equal(t, "/a~1b~0c", result.Changes[0].Path)
+	})
+
+	t.Run("should report value of different type as modified", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, []int{1})
+		to := writeJSON(t, s, map[string]int{"a": 1})
+		// when
+		result, err := diff.JSON(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result.Changes, 1)
+		assert.Equal(t, "", result.Changes[0].Path)
+		assert.Equal(t, diff.Modified, result.Changes[0].Kind)
+	})
+
+	t.Run("should return NotJSON error when version is not JSON", func(t *testing.T) {
+		notJSON := []string{"", "not json", `{"a":1} {"b":2}`, `{"a":`}
+		for _, data := range notJSON {
+			t.Run(data, func(t *testing.T) {
+				s := tests.OpenStore(t)
+				from := writeJSON(t, s, 1)
+				to := tests.WriteData(t, s, []byte(data))
+				// when
+				_, err := diff.JSON(s, from.Time, to.Time)
+				// then
+				assert.True(t, diff.IsNotJSON(err))
+			})
+		}
+	})
+
+	t.Run("should return error when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		from := writeJSON(t, s, "aaaaaaaa")
+		to := writeJSON(t, s, "aaaaaaaa")
+		tests.CorruptDataFiles(t, dir)
+		// when
+		_, err = diff.JSON(s, from.Time, to.Time)
+		// then
+		assert.True(t, store.IsCorrupted(err))
+	})
+}
+
+func TestBytes(t *testing.T) {
+
+	t.Run("should return no ranges for equal data", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := tests.WriteData(t, s, []byte("data"))
+		to := tests.WriteData(t, s, []byte("data"))
+		// when
+		result, err := diff.Bytes(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.JSON)
+		assert.True(t, result.Equal())
+		assert.True(t, from.Time.Equal(result.From.Time))
+		assert.True(t, to.Time.Equal(result.To.Time))
+	})
+
+	t.Run("should merge adjacent differing bytes", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := tests.WriteData(t, s, []byte("0123456789"))
+		to := tests.WriteData(t, s, []byte("0xx34567y9"))
+		// when
+		result, err := diff.Bytes(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []diff.Range{{Offset: 1, Length: 2}, {Offset: 8, Length: 1}}, result.Ranges)
+	})
+
+	t.Run("should report remaining bytes of longer version", func(t *testing.T) {
+		tables := map[string]struct{ from, to string }{
+			"from is longer": {from: "abcdef", to: "abc"},
+			"to is longer":   {from: "abc", to: "abcdef"},
+		}
+		for name, table := range tables {
+			t.Run(name, func(t *testing.T) {
+				s := tests.OpenStore(t)
+				from := tests.WriteData(t, s, []byte(table.from))
+				to := tests.WriteData(t, s, []byte(table.to))
+				// when
+				result, err := diff.Bytes(s, from.Time, to.Time)
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []diff.Range{{Offset: 3, Length: 3}}, result.Ranges)
+			})
+		}
+	})
+
+	t.Run("should compare data larger than internal buffer", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		data := []byte(strings.Repeat("a", 100000))
+		from := tests.WriteData(t, s, data)
+		data[70000] = 'b'
+		to := tests.WriteData(t, s, append(data, 'c', 'c'))
+		// when
+		result, err := diff.Bytes(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []diff.Range{{Offset: 70000, Length: 1}, {Offset: 100000, Length: 2}}, result.Ranges)
+	})
+
+	t.Run("should return error when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		from := tests.WriteData(t, s, []byte("data"))
+		to := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		// when
+		_, err = diff.Bytes(s, from.Time, to.Time)
+		// then
+		assert.True(t, store.IsCorrupted(err))
+	})
+
+	t.Run("should return error when context is canceled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := tests.WriteData(t, s, []byte("data"))
+		to := tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		_, err := diff.BytesContext(ctx, s, from.Time, to.Time)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestVersions(t *testing.T) {
+
+	t.Run("should compare JSON versions structurally", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, map[string]int{"a": 1})
+		to := writeJSON(t, s, map[string]int{"a": 2})
+		// when
+		result, err := diff.Versions(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.True(t, result.JSON)
+		assert.Len(t, result.Changes, 1)
+	})
+
+	t.Run("should compare opaque versions byte by byte", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := writeJSON(t, s, map[string]int{"a": 1})
+		to := tests.WriteData(t, s, []byte{0, 1, 2})
+		// when
+		result, err := diff.Versions(s, from.Time, to.Time)
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.JSON)
+		assert.NotEmpty(t, result.Ranges)
+	})
+
+	t.Run("should return VersionNotFound error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		from := tests.WriteData(t, s, []byte("data"))
+		to := tests.WriteData(t, s, []byte("data"))
+		require.NoError(t, s.DeleteVersion(to.Time))
+		// when
+		_, err := diff.Versions(s, from.Time, to.Time)
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+}
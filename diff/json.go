@@ -0,0 +1,168 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+type Kind string
+
+const (
+	Added    Kind = "added"
+	Removed  Kind = "removed"
+	Modified Kind = "modified"
+)
+
+// Change is a difference of a single JSON value. Objects and arrays are compared recursively, therefore only
+// changed leaves, added and removed values are reported.
+type Change struct {
+	// Path is a JSON Pointer (RFC 6901) of the value, for example /users/0/name. Empty path means the whole document.
+	Path string      `json:"path"`
+	Kind Kind        `json:"kind"`
+	Old  interface{} `json:"old,omitempty"` // nil when value was added
+	New  interface{} `json:"new,omitempty"` // nil when value was removed
+}
+
+// JSON compares versions written using json package structurally. Changes are in document order, keys of objects
+// in alphabetical order. Numbers are compared as they were written, so 1.0 and 1 are different. When version does
+// not contain a single JSON value, error is returned which can be checked using IsNotJSON.
+func JSON(s codec.ReadOnlyStore, from, to time.Time) (Result, error) {
+	return JSONContext(context.Background(), s, from, to)
+}
+
+// JSONContext is like JSON but aborts reading once ctx is done.
+func JSONContext(ctx context.Context, s codec.ReadOnlyStore, from, to time.Time) (Result, error) {
+	result := Result{JSON: true}
+	var (
+		oldValue, newValue interface{}
+		err                error
+	)
+	if result.From, err = codec.ReadContext(ctx, s, jsonDecoder(&oldValue), store.Time(from)); err != nil {
+		return Result{}, err
+	}
+	if result.To, err = codec.ReadContext(ctx, s, jsonDecoder(&newValue), store.Time(to)); err != nil {
+		return Result{}, err
+	}
+	result.Changes = compare("", oldValue, newValue, nil)
+	return result, nil
+}
+
+var errMoreThanOneValue = errors.New("more than one JSON value")
+
+// jsonDecoder decodes a single JSON value and reads the rest of data, so integrity of the whole version is verified
+func jsonDecoder(out *interface{}) codec.Decoder {
+	return func(reader io.Reader) error {
+		decoder := json.NewDecoder(reader)
+		decoder.UseNumber()
+		err := decoder.Decode(out)
+		if err == nil {
+			var trailing interface{}
+			err = decoder.Decode(&trailing)
+			if err == io.EOF {
+				return nil
+			}
+			if err == nil {
+				err = errMoreThanOneValue
+			}
+		}
+		return notJSONErrorFor(err, reader)
+	}
+}
+
+// notJSONErrorFor converts decoding errors to notJSONError, while errors of reading data are returned as is. Data
+// which is not JSON is read till the end, so corrupted version is not reported as not JSON.
+func notJSONErrorFor(err error, reader io.Reader) error {
+	if _, readErr := io.Copy(ioutil.Discard, reader); readErr != nil {
+		return readErr
+	}
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) ||
+		err == errMoreThanOneValue {
+		return notJSONError{msg: fmt.Sprintf("version does not contain a single JSON value: %s", err)}
+	}
+	return err
+}
+
+func compare(path string, oldValue, newValue interface{}, changes []Change) []Change {
+	switch o := oldValue.(type) {
+	case map[string]interface{}:
+		if n, ok := newValue.(map[string]interface{}); ok {
+			return compareObjects(path, o, n, changes)
+		}
+	case []interface{}:
+		if n, ok := newValue.([]interface{}); ok {
+			return compareArrays(path, o, n, changes)
+		}
+	}
+	if !reflect.DeepEqual(oldValue, newValue) {
+		changes = append(changes, Change{Path: path, Kind: Modified, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+func compareObjects(path string, oldObject, newObject map[string]interface{}, changes []Change) []Change {
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldValue, inOld := oldObject[key]
+		newValue, inNew := newObject[key]
+		keyPath := path + "/" + escapePointerToken(key)
+		switch {
+		case !inNew:
+			changes = append(changes, Change{Path: keyPath, Kind: Removed, Old: oldValue})
+		case !inOld:
+			changes = append(changes, Change{Path: keyPath, Kind: Added, New: newValue})
+		default:
+			changes = compare(keyPath, oldValue, newValue, changes)
+		}
+	}
+	return changes
+}
+
+func compareArrays(path string, oldArray, newArray []interface{}, changes []Change) []Change {
+	for i := 0; i < len(oldArray) || i < len(newArray); i++ {
+		indexPath := path + "/" + strconv.Itoa(i)
+		switch {
+		case i >= len(newArray):
+			changes = append(changes, Change{Path: indexPath, Kind: Removed, Old: oldArray[i]})
+		case i >= len(oldArray):
+			changes = append(changes, Change{Path: indexPath, Kind: Added, New: newArray[i]})
+		default:
+			changes = compare(indexPath, oldArray[i], newArray[i], changes)
+		}
+	}
+	return changes
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func escapePointerToken(token string) string {
+	return pointerEscaper.Replace(token)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/elgopher/deebee/admin"
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to browse versions over HTTP. Run it and open http://localhost:8080/admin/versions
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	// deleting versions and compacting is allowed only with a password
+	authorizer := func(req *http.Request, action admin.Action) bool {
+		_, password, ok := req.BasicAuth()
+		return ok && subtle.ConstantTimeCompare([]byte(password), []byte("secret")) == 1
+	}
+	handler, err := admin.NewHandler(s, admin.ProtectedActions(authorizer))
+	if err != nil {
+		panic(err)
+	}
+	http.Handle("/admin/", http.StripPrefix("/admin", handler))
+
+	if err = http.ListenAndServe("localhost:8080", nil); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to move versions between stores using a tar archive
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	archive, err := os.Create("/tmp/deebee.tar")
+	if err != nil {
+		panic(err)
+	}
+	// export versions from the last 24 hours
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	exported, err := s.Export(archive, func(v store.Version) bool {
+		return v.Time.After(dayAgo)
+	})
+	if err != nil {
+		panic(err)
+	}
+	if err = archive.Close(); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Exported %d versions\n", len(exported))
+
+	other, err := store.Open("/tmp/deebee-other")
+	if err != nil {
+		panic(err)
+	}
+	archive, err = os.Open("/tmp/deebee.tar")
+	if err != nil {
+		panic(err)
+	}
+	defer archive.Close()
+	// versions already existing in other store are skipped
+	imported, err := other.Import(archive, store.SkipExisting)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Imported %d versions\n", len(imported))
+}
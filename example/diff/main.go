@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/elgopher/deebee/diff"
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+)
+
+type State struct {
+	Name  string
+	Roles []string
+}
+
+// This example shows how to find out what changed between two versions
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	if err = json.Write(s, State{Name: "alice", Roles: []string{"admin", "user"}}); err != nil {
+		panic(err)
+	}
+	if err = json.Write(s, State{Name: "alice", Roles: []string{"user"}}); err != nil {
+		panic(err)
+	}
+
+	versions, err := s.Versions()
+	if err != nil {
+		panic(err)
+	}
+	previous, latest := versions[len(versions)-2], versions[len(versions)-1]
+	// JSON versions are compared structurally, other versions byte by byte
+	result, err := diff.Versions(s, previous.Time, latest.Time)
+	if err != nil {
+		panic(err)
+	}
+	for _, change := range result.Changes {
+		fmt.Printf("%s %s: %v -> %v\n", change.Kind, change.Path, change.Old, change.New)
+	}
+	for _, r := range result.Ranges {
+		fmt.Printf("%d bytes differ at offset %d\n", r.Length, r.Offset)
+	}
+}
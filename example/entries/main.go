@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to write several named entries as one version and read them individually
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	// either both entries are written or none of them
+	err = codec.WriteEntries(s, map[string]codec.Encoder{
+		"users":  json.Encoder([]string{"alice", "bob"}),
+		"orders": json.Encoder(map[string]int{"alice": 3}),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// only "users" entry is read
+	var users []string
+	version, err := codec.ReadEntry(s, "users", json.Decoder(&users))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Users:", users)
+
+	entries, err := s.Entries(version.Time)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Entries of version %s: %+v\n", version.Time, entries)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/exporter"
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to export metrics to Prometheus and expvar (http://localhost:8080/debug/vars)
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	collector := &compacter.Collector{}
+	go func() {
+		_ = compacter.Start(context.Background(), s, compacter.Collect(collector))
+	}()
+
+	registry := &exporter.Registry{}
+	if err = registry.AddStore("state", s); err != nil {
+		panic(err)
+	}
+	if err = registry.AddCompacter("state", collector); err != nil {
+		panic(err)
+	}
+	if err = registry.PublishExpvar("deebee"); err != nil {
+		panic(err)
+	}
+
+	http.Handle("/metrics", registry)
+	if err = http.ListenAndServe("localhost:8080", nil); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/yala/adapter/console"
+)
+
+// This example shows how to replicate to many stores reading each version only once
+func main() {
+	replicator.SetLoggerAdapter(console.StdoutAdapter()) // enable logging in replicator go-routine
+
+	s, err := store.Open("/tmp/deebee/local")
+	if err != nil {
+		panic(err)
+	}
+	err = json.Write(s, map[string]string{"key": "value"})
+	if err != nil {
+		panic(err)
+	}
+
+	first, err := store.Open("/tmp/deebee/nfs1")
+	if err != nil {
+		panic(err)
+	}
+	second, err := store.Open("/tmp/deebee/nfs2")
+	if err != nil {
+		panic(err)
+	}
+	destinations := []replicator.Destination{
+		{Name: "nfs1", Store: first},
+		{Name: "nfs2", Store: second},
+	}
+
+	status := &replicator.Status{}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go func() {
+		if err2 := replicator.StartFanOut(ctx, s, destinations, replicator.Interval(time.Second), replicator.TrackStatus(status)); err2 != nil {
+			panic(err2)
+		}
+	}()
+
+	<-ctx.Done()
+	for _, d := range status.Destinations() {
+		fmt.Printf("%s: last version %s, lag %s, error %v\n", d.Name, d.LastVersion.Time, d.Lag, d.LastError)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/follower"
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+)
+
+type State struct {
+	Name string
+}
+
+// This example shows how to keep state loaded from a store written by another process (eg. primary instance)
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var staging, current State
+
+	err = follower.Start(ctx, s, json.Decoder(&staging), func(version store.Version) {
+		current = staging
+		fmt.Printf("Loaded version %s: %+v\n", version.Time, current)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/health"
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to expose health checks for readiness probes (http://localhost:8080/ready)
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	collector := &compacter.Collector{}
+	go func() {
+		_ = compacter.Start(context.Background(), s, compacter.Collect(collector))
+	}()
+
+	checks := &health.Checks{}
+	mustAdd(checks.Add("writable", health.Writable(s)))
+	mustAdd(checks.Add("latest", health.LatestIntegral(s, time.Hour)))
+	mustAdd(checks.Add("compacter", health.LastCompaction(collector, 10*time.Minute)))
+
+	http.Handle("/ready", checks)
+	if err = http.ListenAndServe("localhost:8080", nil); err != nil {
+		panic(err)
+	}
+}
+
+func mustAdd(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
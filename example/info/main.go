@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to get disk usage and version statistics
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	info, err := s.Info()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Total bytes used: %d\n", info.TotalBytes)
+	fmt.Printf("Versions: %d (%d bytes)\n", info.Versions, info.VersionBytes)
+	fmt.Printf("Oldest: %s, newest: %s\n", info.Oldest.Time, info.Newest.Time)
+	fmt.Printf("Largest: %+v\n", info.Largest)
+	fmt.Printf("Incomplete files: %d\n", info.IncompleteFiles)
+}
@@ -2,17 +2,34 @@ package main
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/elgopher/deebee/compacter"
 	"github.com/elgopher/deebee/store"
 )
 
-// This example shows how to get Store metrics
+// This example shows how to get Store and compacter metrics
 func main() {
 	s, err := store.Open("/tmp/deebee")
 	if err != nil {
 		panic(err)
 	}
 
+	collector := &compacter.Collector{}
+	if err = compacter.RunOnce(s, compacter.Collect(collector)); err != nil {
+		fmt.Println("compacter failed:", err)
+	}
+
 	metrics := s.Metrics()
-	fmt.Printf("%+v", metrics)
+	fmt.Printf("%+v\n", metrics)
+	fmt.Printf("Average write latency: %s\n", average(metrics.Write.Latency))
+	fmt.Printf("Integrity failures: %d\n", metrics.Read.ChecksumMismatches+metrics.Read.MissingChecksums)
+	fmt.Printf("Compacter: %+v\n", collector.Metrics())
+}
+
+func average(h store.Histogram) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to keep separate version histories in one store directory
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	// each namespace is a sub-store with its own version history, kept in a subdirectory
+	users, err := s.Namespace("users")
+	if err != nil {
+		panic(err)
+	}
+	orders, err := s.Namespace("orders")
+	if err != nil {
+		panic(err)
+	}
+
+	if err = json.Write(users, []string{"alice", "bob"}); err != nil {
+		panic(err)
+	}
+	if err = json.Write(orders, []int{1, 2, 3}); err != nil {
+		panic(err)
+	}
+
+	names, err := s.Namespaces()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Namespaces:", names)
+
+	// compact the root store and all namespaces in one run
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err = compacter.RunOnceContext(ctx, s, compacter.AllNamespaces); err != nil {
+		panic(err)
+	}
+}
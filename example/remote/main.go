@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/remote"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to replicate versions to another host over HTTP, without a shared file-system
+func main() {
+	// on the backup host, expose the store over HTTP:
+	backupStore, err := store.Open("/tmp/deebee/backup")
+	if err != nil {
+		panic(err)
+	}
+	server, err := remote.NewServer(backupStore)
+	if err != nil {
+		panic(err)
+	}
+	http.Handle("/store/", http.StripPrefix("/store", server))
+	go func() {
+		if err2 := http.ListenAndServe("localhost:8080", nil); err2 != nil {
+			panic(err2)
+		}
+	}()
+
+	// on the main host, use the client like any other store:
+	mainStore, err := store.Open("/tmp/deebee/main")
+	if err != nil {
+		panic(err)
+	}
+	err = json.Write(mainStore, map[string]string{"key": "value"})
+	if err != nil {
+		panic(err)
+	}
+
+	client, err := remote.NewClient("http://localhost:8080/store", remote.Retries(5, time.Second))
+	if err != nil {
+		panic(err)
+	}
+	// data is uploaded in chunks, resumed after network failures and verified before the version becomes visible
+	err = replicator.CopyFromTo(mainStore, client)
+	if err != nil {
+		panic(err)
+	}
+
+	versions, err := client.Versions()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Versions on backup host: %+v\n", versions)
+}
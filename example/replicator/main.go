@@ -35,11 +35,20 @@ func main() {
 		panic(err)
 	}
 
-	// copy recent versions continuously in the background
+	// copy all versions missing in shared store, skipping corrupted ones
+	result, err := replicator.Sync(cheapStore, sharedStore)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Synchronized versions: %+v, skipped corrupted: %+v\n", result.Copied, result.Skipped)
+
+	// copy recent versions continuously in the background, as soon as they are created
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go func() {
-		if err2 := replicator.StartFromTo(ctx, cheapStore, sharedStore, replicator.Interval(10*time.Second)); err2 != nil {
+		err2 := replicator.StartFromTo(ctx, cheapStore, sharedStore,
+			replicator.OnWrite(100*time.Millisecond), replicator.Interval(10*time.Second))
+		if err2 != nil {
 			panic(err2)
 		}
 	}()
@@ -50,7 +59,14 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	fmt.Printf("Version read from two stores: %+v", version)
+	fmt.Printf("Version read from two stores: %+v\n", version)
+
+	// restore corrupted versions in cheap store using intact copies from shared store
+	repaired, err := replicator.Repair(cheapStore, sharedStore)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Repaired versions: %+v\n", repaired)
 
 	time.Sleep(20 * time.Second)
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to split a huge version into segment files
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	// each segment file has at most 64 MB. Full segments are synced in background while next ones are written
+	writer, err := s.Writer(store.SegmentSize(64 * 1024 * 1024))
+	if err != nil {
+		panic(err)
+	}
+	if _, err = writer.Write([]byte("huge state")); err != nil {
+		writer.AbortAndClose()
+		panic(err)
+	}
+	// all segments become visible at once
+	if err = writer.Close(); err != nil {
+		panic(err)
+	}
+
+	// segments are read as one stream, up to 4 segments are read at once
+	reader, err := s.Reader(store.ParallelSegmentReads(4))
+	if err != nil {
+		panic(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		_ = reader.Close()
+		panic(err)
+	}
+	if err = reader.Close(); err != nil {
+		panic(err)
+	}
+	fmt.Println("Data read:", string(data))
+}
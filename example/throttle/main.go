@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/json"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/deebee/throttle"
+)
+
+// This example shows how to limit disk bandwidth used by store and compacter sharing the same disk
+func main() {
+	limiter, err := throttle.NewTokenBucket(10 * 1024 * 1024) // 10 MB/s shared by all components
+	if err != nil {
+		panic(err)
+	}
+
+	s, err := store.Open("/tmp/deebee", store.ThrottleWrites(limiter), store.ThrottleReads(limiter))
+	if err != nil {
+		panic(err)
+	}
+
+	err = json.Write(s, map[string]string{})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = compacter.Start(ctx, s, compacter.Throttle(limiter)); err != nil {
+		panic(err)
+	}
+}
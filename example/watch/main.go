@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+// This example shows how to react on versions created or deleted by this or another process
+func main() {
+	s, err := store.Open("/tmp/deebee")
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	events, err := s.Watch(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	for event := range events {
+		fmt.Printf("%s: %+v\n", event.Type, event.Version)
+	}
+}
@@ -0,0 +1,23 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package exporter
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// PublishExpvar publishes Snapshot of the registry as expvar variable with a given name. Snapshot is taken each time
+// the variable is read, for example when /debug/vars is requested. Durations are exported in nanoseconds.
+//
+// Error is returned when variable with the same name was already published.
+func (r *Registry) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %s already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return r.Snapshot()
+	}))
+	return nil
+}
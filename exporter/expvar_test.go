@@ -0,0 +1,51 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package exporter_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/exporter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_PublishExpvar(t *testing.T) {
+
+	t.Run("should publish snapshot", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		s := &storeMock{}
+		s.metrics.Read.ReaderCalls = 3
+		require.NoError(t, registry.AddStore("s", s))
+		name := uniqueName(t)
+		// when
+		err := registry.PublishExpvar(name)
+		// then
+		require.NoError(t, err)
+		var snapshot exporter.Snapshot
+		err = json.Unmarshal([]byte(expvar.Get(name).String()), &snapshot)
+		require.NoError(t, err)
+		assert.Equal(t, 3, snapshot.Stores["s"].Read.ReaderCalls)
+	})
+
+	t.Run("should return error when variable was already published", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		name := uniqueName(t)
+		require.NoError(t, registry.PublishExpvar(name))
+		// when
+		err := registry.PublishExpvar(name)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+// uniqueName returns a new expvar name each time, because published variables cannot be removed and tests may run
+// many times within the same process
+func uniqueName(t *testing.T) string {
+	return fmt.Sprintf("%s_%d", t.Name(), time.Now().UnixNano())
+}
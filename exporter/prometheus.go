@@ -0,0 +1,190 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP writes metrics of all sources in Prometheus text exposition format. It can be registered for example
+// under /metrics path.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", prometheusContentType)
+	if err := r.WritePrometheus(w); err != nil {
+		log.WithError(err).Warn(req.Context(), "writing Prometheus metrics failed")
+	}
+}
+
+// WritePrometheus writes metrics of all sources in Prometheus text exposition format. Durations are exported
+// in seconds.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	snapshot := r.Snapshot()
+
+	f := &families{byName: map[string]*family{}}
+	for name, metrics := range snapshot.Stores {
+		f.addStore(name, metrics)
+	}
+	for name, metrics := range snapshot.Compacters {
+		f.addCompacter(name, metrics)
+	}
+	for name, metrics := range snapshot.Replicators {
+		f.addReplicator(name, metrics)
+	}
+	return f.writeTo(w)
+}
+
+func (f *families) addStore(name string, m store.Metrics) {
+	l := label{name: "store", value: name}
+	f.counter("deebee_store_reader_calls_total", "Number of Store.Reader() calls.", l, float64(m.Read.ReaderCalls))
+	f.counter("deebee_store_read_bytes_total", "Number of bytes read.", l, float64(m.Read.TotalBytesRead))
+	f.counter("deebee_store_read_seconds_total", "Time spent reading.", l, m.Read.TotalTime.Seconds())
+	f.histogram("deebee_store_read_latency_seconds", "Time between opening and closing Reader.", l, m.Read.Latency)
+	f.counter("deebee_store_read_checksum_seconds_total", "Time spent calculating and validating checksums.", l, m.Read.ChecksumTime.Seconds())
+	f.counter("deebee_store_checksum_mismatches_total", "Number of readers which found that data does not match the checksum.", l, float64(m.Read.ChecksumMismatches))
+	f.counter("deebee_store_missing_checksums_total", "Number of readers which found that checksum file was missing.", l, float64(m.Read.MissingChecksums))
+
+	f.counter("deebee_store_writer_calls_total", "Number of Store.Writer() calls.", l, float64(m.Write.WriterCalls))
+	f.counter("deebee_store_successful_writes_total", "Number of writers closed without aborting.", l, float64(m.Write.Successful))
+	f.counter("deebee_store_aborted_writes_total", "Number of aborted writers.", l, float64(m.Write.Aborted))
+	f.counter("deebee_store_failed_writes_total", "Number of writers which failed while closing.", l, float64(m.Write.Failed))
+	f.counter("deebee_store_written_bytes_total", "Number of bytes written.", l, float64(m.Write.TotalBytesWritten))
+	f.counter("deebee_store_write_seconds_total", "Time spent writing.", l, m.Write.TotalTime.Seconds())
+	f.histogram("deebee_store_write_latency_seconds", "Time between opening Writer and closing or aborting it.", l, m.Write.Latency)
+	f.counter("deebee_store_write_checksum_seconds_total", "Time spent calculating and writing checksums.", l, m.Write.ChecksumTime.Seconds())
+	f.counter("deebee_store_sync_seconds_total", "Time spent flushing data to disk.", l, m.Write.SyncTime.Seconds())
+	f.histogram("deebee_store_sync_latency_seconds", "Time of flushing a single version to disk.", l, m.Write.SyncLatency)
+
+	f.counter("deebee_store_delete_version_calls_total", "Number of Store.DeleteVersion() calls.", l, float64(m.Delete.DeleteVersionCalls))
+	f.counter("deebee_store_deleted_versions_total", "Number of deleted versions.", l, float64(m.Delete.Deleted))
+	f.counter("deebee_store_delete_seconds_total", "Time spent deleting versions.", l, m.Delete.TotalTime.Seconds())
+	f.histogram("deebee_store_delete_latency_seconds", "Time of Store.DeleteVersion() call.", l, m.Delete.Latency)
+
+	f.counter("deebee_store_scans_total", "Number of store directory scans.", l, float64(m.Versions.Scans))
+	f.counter("deebee_store_scan_seconds_total", "Time spent scanning store directory.", l, m.Versions.TotalTime.Seconds())
+	f.histogram("deebee_store_scan_latency_seconds", "Time of a single store directory scan.", l, m.Versions.Latency)
+}
+
+func (f *families) addCompacter(name string, m compacter.Metrics) {
+	l := label{name: "compacter", value: name}
+	f.counter("deebee_compacter_runs_total", "Number of compacter runs.", l, float64(m.Runs))
+	f.counter("deebee_compacter_failed_runs_total", "Number of failed compacter runs.", l, float64(m.Failed))
+	f.counter("deebee_compacter_deleted_versions_total", "Number of versions deleted by compacter.", l, float64(m.DeletedVersions))
+	f.gauge("deebee_compacter_last_success_timestamp_seconds", "Unix time of the last successful compacter run.", l, timestamp(m.LastSuccess))
+	f.counter("deebee_compacter_run_seconds_total", "Time spent compacting.", l, m.TotalTime.Seconds())
+	f.histogram("deebee_compacter_run_latency_seconds", "Time of a single compacter run.", l, m.Latency)
+}
+
+func (f *families) addReplicator(name string, m replicator.Metrics) {
+	l := label{name: "replicator", value: name}
+	f.counter("deebee_replicator_runs_total", "Number of replicator runs.", l, float64(m.Runs))
+	f.counter("deebee_replicator_failed_runs_total", "Number of failed replicator runs.", l, float64(m.Failed))
+	f.counter("deebee_replicator_copied_versions_total", "Number of versions copied by replicator.", l, float64(m.Copied))
+	f.counter("deebee_replicator_already_existed_runs_total", "Number of replicator runs which found latest version already replicated.", l, float64(m.AlreadyExisted))
+	f.counter("deebee_replicator_copied_bytes_total", "Number of bytes copied by replicator.", l, float64(m.BytesCopied))
+	f.gauge("deebee_replicator_last_success_timestamp_seconds", "Unix time of the last successful replicator run.", l, timestamp(m.LastSuccess))
+	f.counter("deebee_replicator_run_seconds_total", "Time spent replicating.", l, m.TotalTime.Seconds())
+	f.histogram("deebee_replicator_run_latency_seconds", "Time of a single replicator run.", l, m.Latency)
+}
+
+func timestamp(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// families is a list of metric families in order of their first use
+type families struct {
+	list   []*family
+	byName map[string]*family
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	label     label
+	value     float64
+	histogram store.Histogram // used only by histogram family
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+func (f *families) counter(name, help string, l label, value float64) {
+	f.add(name, help, "counter", sample{label: l, value: value})
+}
+
+func (f *families) gauge(name, help string, l label, value float64) {
+	f.add(name, help, "gauge", sample{label: l, value: value})
+}
+
+func (f *families) histogram(name, help string, l label, h store.Histogram) {
+	f.add(name, help, "histogram", sample{label: l, histogram: h})
+}
+
+func (f *families) add(name, help, typ string, s sample) {
+	fam, ok := f.byName[name]
+	if !ok {
+		fam = &family{name: name, help: help, typ: typ}
+		f.byName[name] = fam
+		f.list = append(f.list, fam)
+	}
+	fam.samples = append(fam.samples, s)
+}
+
+func (f *families) writeTo(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, fam := range f.list {
+		samples := fam.samples
+		sort.Slice(samples, func(i, j int) bool {
+			return samples[i].label.value < samples[j].label.value
+		})
+		_, _ = fmt.Fprintf(b, "# HELP %s %s\n", fam.name, helpEscaper.Replace(fam.help))
+		_, _ = fmt.Fprintf(b, "# TYPE %s %s\n", fam.name, fam.typ)
+		for _, s := range samples {
+			labels := fmt.Sprintf(`%s="%s"`, s.label.name, labelValueEscaper.Replace(s.label.value))
+			if fam.typ != "histogram" {
+				_, _ = fmt.Fprintf(b, "%s{%s} %s\n", fam.name, labels, formatFloat(s.value))
+				continue
+			}
+			cumulative := s.histogram.Cumulative()
+			for i, bound := range store.LatencyBuckets {
+				_, _ = fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", fam.name, labels, formatFloat(bound.Seconds()), cumulative[i])
+			}
+			_, _ = fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", fam.name, labels, s.histogram.Count)
+			_, _ = fmt.Fprintf(b, "%s_sum{%s} %s\n", fam.name, labels, formatFloat(s.histogram.Sum.Seconds()))
+			_, _ = fmt.Fprintf(b, "%s_count{%s} %d\n", fam.name, labels, s.histogram.Count)
+		}
+	}
+	return b.Flush()
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
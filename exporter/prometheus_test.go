@@ -0,0 +1,146 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package exporter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/exporter"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+
+	t.Run("should write nothing for empty registry", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		var out strings.Builder
+		// when
+		err := registry.WritePrometheus(&out)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("should write store counters", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		require.NoError(t, registry.AddStore("local", s))
+		// when
+		out := writePrometheus(t, registry)
+		// then
+		assert.Contains(t, out, "# HELP deebee_store_writer_calls_total Number of Store.Writer() calls.\n"+
+			"# TYPE deebee_store_writer_calls_total counter\n"+
+			"deebee_store_writer_calls_total{store=\"local\"} 1\n")
+		assert.Contains(t, out, "deebee_store_written_bytes_total{store=\"local\"} 4\n")
+		assert.Contains(t, out, "deebee_store_checksum_mismatches_total{store=\"local\"} 0\n")
+	})
+
+	t.Run("should write histogram", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		s := &storeMock{}
+		s.metrics.Delete.Latency.Observe(time.Millisecond)
+		s.metrics.Delete.Latency.Observe(time.Hour)
+		require.NoError(t, registry.AddStore("s", s))
+		// when
+		out := writePrometheus(t, registry)
+		// then
+		assert.Contains(t, out, "# TYPE deebee_store_delete_latency_seconds histogram\n"+
+			"deebee_store_delete_latency_seconds_bucket{store=\"s\",le=\"0.0001\"} 0\n"+
+			"deebee_store_delete_latency_seconds_bucket{store=\"s\",le=\"0.0005\"} 0\n"+
+			"deebee_store_delete_latency_seconds_bucket{store=\"s\",le=\"0.001\"} 1\n")
+		assert.Contains(t, out, "deebee_store_delete_latency_seconds_bucket{store=\"s\",le=\"10\"} 1\n"+
+			"deebee_store_delete_latency_seconds_bucket{store=\"s\",le=\"+Inf\"} 2\n"+
+			"deebee_store_delete_latency_seconds_sum{store=\"s\"} 3600.001\n"+
+			"deebee_store_delete_latency_seconds_count{store=\"s\"} 2\n")
+	})
+
+	t.Run("should write samples of many stores in a single family sorted by name", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddStore("b", &storeMock{}))
+		require.NoError(t, registry.AddStore("a", &storeMock{}))
+		// when
+		out := writePrometheus(t, registry)
+		// then
+		assert.Contains(t, out, "# TYPE deebee_store_reader_calls_total counter\n"+
+			"deebee_store_reader_calls_total{store=\"a\"} 0\n"+
+			"deebee_store_reader_calls_total{store=\"b\"} 0\n")
+		assert.Equal(t, 1, strings.Count(out, "# TYPE deebee_store_reader_calls_total"))
+	})
+
+	t.Run("should escape label value", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddStore("a\"b\\c\nd", &storeMock{}))
+		// when
+		out := writePrometheus(t, registry)
+		// then
+		assert.Contains(t, out, `deebee_store_reader_calls_total{store="a\"b\\c\nd"} 0`)
+	})
+
+	t.Run("should write compacter metrics", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("v1"))
+		tests.WriteData(t, s, []byte("v2"))
+		collector := &compacter.Collector{}
+		require.NoError(t, compacter.RunOnce(s, compacter.Collect(collector)))
+		require.NoError(t, registry.AddCompacter("c", collector))
+		// when
+		out := writePrometheus(t, registry)
+		// then
+		assert.Contains(t, out, "deebee_compacter_runs_total{compacter=\"c\"} 1\n")
+		assert.Contains(t, out, "deebee_compacter_deleted_versions_total{compacter=\"c\"} 1\n")
+		assert.Contains(t, out, "# TYPE deebee_compacter_last_success_timestamp_seconds gauge\n")
+		assert.NotContains(t, out, "deebee_compacter_last_success_timestamp_seconds{compacter=\"c\"} 0\n")
+	})
+
+	t.Run("should write replicator metrics", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddReplicator("r", &replicator.Collector{}))
+		// when
+		out := writePrometheus(t, registry)
+		// then
+		assert.Contains(t, out, "deebee_replicator_runs_total{replicator=\"r\"} 0\n")
+		assert.Contains(t, out, "deebee_replicator_last_success_timestamp_seconds{replicator=\"r\"} 0\n")
+	})
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+
+	t.Run("should serve metrics in Prometheus text format", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddStore("s", &storeMock{}))
+		recorder := httptest.NewRecorder()
+		// when
+		registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		// then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Contains(t, recorder.Body.String(), "deebee_store_reader_calls_total{store=\"s\"} 0\n")
+	})
+}
+
+type storeMock struct {
+	metrics store.Metrics
+}
+
+func (s *storeMock) Metrics() store.Metrics {
+	return s.metrics
+}
+
+func writePrometheus(t *testing.T, registry *exporter.Registry) string {
+	var out strings.Builder
+	err := registry.WritePrometheus(&out)
+	require.NoError(t, err)
+	return out.String()
+}
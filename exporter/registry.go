@@ -0,0 +1,117 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package exporter exports metrics of stores, compacters and replicators in Prometheus text format and using
+// the expvar package. Prometheus client library is not needed.
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+)
+
+// Registry is a set of named metric sources. Zero value is ready to use. Registry is safe for concurrent use.
+type Registry struct {
+	mutex       sync.Mutex
+	stores      map[string]Store
+	compacters  map[string]*compacter.Collector
+	replicators map[string]*replicator.Collector
+}
+
+// Store is implemented by *store.Store
+type Store interface {
+	Metrics() store.Metrics
+}
+
+// AddStore adds store metrics, which will be exported with label store=name.
+func (r *Registry) AddStore(name string, s Store) error {
+	if s == nil {
+		return errors.New("nil store")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.stores[name]; exists {
+		return fmt.Errorf("store %s already added", name)
+	}
+	if r.stores == nil {
+		r.stores = map[string]Store{}
+	}
+	r.stores[name] = s
+	return nil
+}
+
+// AddCompacter adds metrics of compacter, which will be exported with label compacter=name. Collector must be passed
+// to compacter using compacter.Collect option.
+func (r *Registry) AddCompacter(name string, c *compacter.Collector) error {
+	if c == nil {
+		return errors.New("nil collector")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.compacters[name]; exists {
+		return fmt.Errorf("compacter %s already added", name)
+	}
+	if r.compacters == nil {
+		r.compacters = map[string]*compacter.Collector{}
+	}
+	r.compacters[name] = c
+	return nil
+}
+
+// AddReplicator adds metrics of replicator, which will be exported with label replicator=name. Collector must be passed
+// to replicator using replicator.Collect option.
+func (r *Registry) AddReplicator(name string, c *replicator.Collector) error {
+	if c == nil {
+		return errors.New("nil collector")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.replicators[name]; exists {
+		return fmt.Errorf("replicator %s already added", name)
+	}
+	if r.replicators == nil {
+		r.replicators = map[string]*replicator.Collector{}
+	}
+	r.replicators[name] = c
+	return nil
+}
+
+// Snapshot contains metrics of all sources, keyed by name
+type Snapshot struct {
+	Stores      map[string]store.Metrics
+	Compacters  map[string]compacter.Metrics
+	Replicators map[string]replicator.Metrics
+}
+
+// Snapshot returns current metrics of all sources
+func (r *Registry) Snapshot() Snapshot {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	snapshot := Snapshot{
+		Stores:      map[string]store.Metrics{},
+		Compacters:  map[string]compacter.Metrics{},
+		Replicators: map[string]replicator.Metrics{},
+	}
+	for name, s := range r.stores {
+		snapshot.Stores[name] = s.Metrics()
+	}
+	for name, c := range r.compacters {
+		snapshot.Compacters[name] = c.Metrics()
+	}
+	for name, c := range r.replicators {
+		snapshot.Replicators[name] = c.Metrics()
+	}
+	return snapshot
+}
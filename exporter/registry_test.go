@@ -0,0 +1,98 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package exporter_test
+
+import (
+	"testing"
+
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/exporter"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_AddStore(t *testing.T) {
+
+	t.Run("should return error for nil store", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		err := registry.AddStore("name", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when store with the same name was added", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddStore("name", tests.OpenStore(t)))
+		// when
+		err := registry.AddStore("name", tests.OpenStore(t))
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistry_AddCompacter(t *testing.T) {
+
+	t.Run("should return error for nil collector", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		err := registry.AddCompacter("name", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when compacter with the same name was added", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddCompacter("name", &compacter.Collector{}))
+		// when
+		err := registry.AddCompacter("name", &compacter.Collector{})
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistry_AddReplicator(t *testing.T) {
+
+	t.Run("should return error for nil collector", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		err := registry.AddReplicator("name", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when replicator with the same name was added", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		require.NoError(t, registry.AddReplicator("name", &replicator.Collector{}))
+		// when
+		err := registry.AddReplicator("name", &replicator.Collector{})
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistry_Snapshot(t *testing.T) {
+
+	t.Run("should return empty snapshot for empty registry", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		snapshot := registry.Snapshot()
+		assert.Empty(t, snapshot.Stores)
+		assert.Empty(t, snapshot.Compacters)
+		assert.Empty(t, snapshot.Replicators)
+	})
+
+	t.Run("should return metrics of all sources", func(t *testing.T) {
+		registry := &exporter.Registry{}
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		compacterCollector := &compacter.Collector{}
+		require.NoError(t, compacter.RunOnce(s, compacter.Collect(compacterCollector)))
+		replicatorCollector := &replicator.Collector{}
+		require.NoError(t, registry.AddStore("s", s))
+		require.NoError(t, registry.AddCompacter("c", compacterCollector))
+		require.NoError(t, registry.AddReplicator("r", replicatorCollector))
+		// when
+		snapshot := registry.Snapshot()
+		// then
+		assert.Equal(t, s.Metrics(), snapshot.Stores["s"])
+		assert.Equal(t, compacterCollector.Metrics(), snapshot.Compacters["c"])
+		assert.Equal(t, replicatorCollector.Metrics(), snapshot.Replicators["r"])
+	})
+}
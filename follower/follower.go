@@ -0,0 +1,121 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package follower keeps in-memory state loaded from a store written by another process, for example from a shared
+// store which is a destination of replicator. It can be used to run hot-standby instances.
+package follower
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+// Start loads the latest version and then each new version appearing in the store, until ctx is done.
+//
+// Every version is decoded using decoder. onLoad is called only when decoding succeeded and data passed the integrity
+// check. Because integrity is verified after decoder returns, decoder should decode into a temporary value and onLoad
+// should hand it over to the application.
+//
+// When the latest version cannot be decoded, previous versions are tried the same way as codec.ReadLatest does, but
+// versions older than the one already loaded are never loaded again.
+func Start(ctx context.Context, s Store, decoder codec.Decoder, onLoad func(store.Version), options ...Option) error {
+	if s == nil {
+		return errors.New("nil store")
+	}
+	if decoder == nil {
+		return errors.New("nil decoder")
+	}
+	if onLoad == nil {
+		return errors.New("nil onLoad")
+	}
+
+	opts := &Options{
+		pollInterval: time.Second,
+	}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return fmt.Errorf("error applying option: %w", err)
+		}
+	}
+
+	events, err := s.Watch(ctx, store.PollInterval(opts.pollInterval))
+	if err != nil {
+		return fmt.Errorf("error watching store: %w", err)
+	}
+
+	f := &follower{store: s, decoder: decoder, onLoad: onLoad}
+	f.loadLatest(ctx)
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.Type == store.VersionCreated {
+				f.loadLatest(ctx)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+type Store interface {
+	codec.ReadOnlyStore
+	Watch(context.Context, ...store.WatchOption) (<-chan store.Event, error)
+}
+
+type Option func(*Options) error
+
+type Options struct {
+	pollInterval time.Duration
+}
+
+// PollInterval sets how often the store directory is scanned for new versions. See store.PollInterval.
+func PollInterval(d time.Duration) Option {
+	return func(o *Options) error {
+		o.pollInterval = d
+		return nil
+	}
+}
+
+type follower struct {
+	store   Store
+	decoder codec.Decoder
+	onLoad  func(store.Version)
+	loaded  *store.Version
+}
+
+func (f *follower) loadLatest(ctx context.Context) {
+	versions, err := f.store.Versions()
+	if err != nil {
+		log.WithError(err).Error(ctx, "listing versions failed")
+		return
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		if f.loaded != nil && !version.Time.After(f.loaded.Time) {
+			return
+		}
+		_, err = codec.ReadContext(ctx, f.store, f.decoder, store.Time(version.Time))
+		if err == nil {
+			f.loaded = &version
+			f.onLoad(version)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.With("version", version.Time).WithError(err).Warn(ctx, "loading version failed")
+	}
+}
@@ -0,0 +1,165 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package follower_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/follower"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStart(t *testing.T) {
+
+	t.Run("should return error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		decoder := (&tests.FakeDecoder{}).Decode
+		onLoad := func(store.Version) {}
+
+		t.Run("when store is nil", func(t *testing.T) {
+			err := follower.Start(context.Background(), nil, decoder, onLoad)
+			assert.Error(t, err)
+		})
+
+		t.Run("when decoder is nil", func(t *testing.T) {
+			err := follower.Start(context.Background(), s, nil, onLoad)
+			assert.Error(t, err)
+		})
+
+		t.Run("when onLoad is nil", func(t *testing.T) {
+			err := follower.Start(context.Background(), s, decoder, nil)
+			assert.Error(t, err)
+		})
+
+		t.Run("when option returned error", func(t *testing.T) {
+			option := func(*follower.Options) error {
+				return errors.New("error")
+			}
+			err := follower.Start(context.Background(), s, decoder, onLoad, option)
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("should stop once context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		var err error
+		async := tests.RunAsync(func() {
+			err = follower.Start(ctx, s, (&tests.FakeDecoder{}).Decode, func(store.Version) {})
+		})
+		// when
+		cancel()
+		// then
+		async.WaitOrFailAfter(t, time.Second)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should load latest version on start", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("1"))
+		version := tests.WriteData(t, s, []byte("2"))
+		f := startFollower(t, s)
+		// expect
+		f.assertLoaded(t, version, "2")
+	})
+
+	t.Run("should load each new version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		f := startFollower(t, s)
+		// when
+		v1 := tests.WriteData(t, s, []byte("1"))
+		// then
+		f.assertLoaded(t, v1, "1")
+		// and when
+		v2 := tests.WriteData(t, s, []byte("2"))
+		// then
+		f.assertLoaded(t, v2, "2")
+	})
+
+	t.Run("should fall back to previous version when latest cannot be decoded", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		v1 := tests.WriteData(t, s, []byte("1"))
+		tests.WriteData(t, s, []byte("invalid"))
+		f := startFollower(t, s)
+		// expect
+		f.assertLoaded(t, v1, "1")
+	})
+
+	t.Run("should keep loaded version when new one cannot be decoded", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		f := startFollower(t, s)
+		v1 := tests.WriteData(t, s, []byte("1"))
+		f.assertLoaded(t, v1, "1")
+		// when
+		tests.WriteData(t, s, []byte("invalid"))
+		// then
+		f.assertNothingLoaded(t)
+		// and when
+		v3 := tests.WriteData(t, s, []byte("3"))
+		// then
+		f.assertLoaded(t, v3, "3")
+	})
+}
+
+type loaded struct {
+	version store.Version
+	data    string
+}
+
+type fakeFollower struct {
+	loaded chan loaded
+}
+
+func startFollower(t *testing.T, s *store.Store) *fakeFollower {
+	f := &fakeFollower{loaded: make(chan loaded, 10)}
+	var staging []byte
+	decoder := func(reader io.Reader) error {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		if string(data) == "invalid" {
+			return errors.New("invalid data")
+		}
+		staging = data
+		return nil
+	}
+	onLoad := func(version store.Version) {
+		f.loaded <- loaded{version: version, data: string(staging)}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	async := tests.RunAsync(func() {
+		err := follower.Start(ctx, s, decoder, onLoad, follower.PollInterval(time.Millisecond))
+		assert.NoError(t, err)
+	})
+	t.Cleanup(func() {
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+	})
+	return f
+}
+
+func (f *fakeFollower) assertLoaded(t *testing.T, version store.Version, data string) {
+	select {
+	case l := <-f.loaded:
+		assert.True(t, version.Time.Equal(l.version.Time), "unexpected version loaded")
+		assert.Equal(t, data, l.data)
+	case <-time.After(time.Second):
+		assert.FailNow(t, "timeout waiting for version to be loaded")
+	}
+}
+
+func (f *fakeFollower) assertNothingLoaded(t *testing.T) {
+	select {
+	case l := <-f.loaded:
+		assert.Failf(t, "unexpected version loaded", "%+v", l)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
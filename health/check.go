@@ -0,0 +1,138 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+)
+
+// WritableStore is implemented by *store.Store
+type WritableStore interface {
+	ProbeWrite(ctx context.Context) error
+}
+
+// Writable checks that a new version could be written to the store. See store.Store.ProbeWrite.
+func Writable(s WritableStore) Check {
+	return func(ctx context.Context) error {
+		return s.ProbeWrite(ctx)
+	}
+}
+
+// LatestIntegral checks that the latest version can be read and is not corrupted. The whole version is read. When
+// store has VerifyContext method (such as *store.Store does), the version is verified instead of read, so all entries
+// of multi-entry version are checked too. When maxAge is positive, the check also fails when the latest version is
+// older than maxAge. Empty store is not healthy.
+func LatestIntegral(s codec.ReadOnlyStore, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		version, err := checkLatest(ctx, s)
+		if err != nil {
+			return fmt.Errorf("latest version cannot be read: %w", err)
+		}
+		if age := time.Since(version.Time); maxAge > 0 && age > maxAge {
+			return fmt.Errorf("latest version %s is %s old, which is more than %s", version.Time, age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
+
+// verifierStore is implemented by *store.Store
+type verifierStore interface {
+	VerifyContext(ctx context.Context, t time.Time) error
+}
+
+func checkLatest(ctx context.Context, s codec.ReadOnlyStore) (store.Version, error) {
+	verifier, ok := s.(verifierStore)
+	if !ok {
+		return codec.ReadContext(ctx, s, discard)
+	}
+	versions, err := codec.ListVersions(ctx, s)
+	if err != nil {
+		return store.Version{}, err
+	}
+	if len(versions) == 0 {
+		return store.Version{}, store.NewVersionNotFoundError("empty store")
+	}
+	latest := versions[len(versions)-1]
+	return latest, verifier.VerifyContext(ctx, latest.Time)
+}
+
+func discard(reader io.Reader) error {
+	_, err := io.Copy(ioutil.Discard, reader)
+	return err
+}
+
+// VersionsStore is implemented by *store.Store
+type VersionsStore interface {
+	Versions() ([]store.Version, error)
+}
+
+// ReplicationLag checks how long the newest version of <from> store waits to be replicated to <to> store. The check
+// fails when the oldest version of <from> store, which is newer than the newest version of <to> store, was created
+// more than maxLag ago. Only versions are compared, data is not read.
+func ReplicationLag(from, to VersionsStore, maxLag time.Duration) Check {
+	return func(ctx context.Context) error {
+		fromVersions, err := from.Versions()
+		if err != nil {
+			return fmt.Errorf("error getting versions of <from> store: %w", err)
+		}
+		toVersions, err := to.Versions()
+		if err != nil {
+			return fmt.Errorf("error getting versions of <to> store: %w", err)
+		}
+		var replicated time.Time
+		if len(toVersions) > 0 {
+			replicated = toVersions[len(toVersions)-1].Time
+		}
+		for _, v := range fromVersions {
+			if !v.Time.After(replicated) {
+				continue
+			}
+			if lag := time.Since(v.Time); lag > maxLag {
+				return fmt.Errorf("version %s is not replicated for %s, which is more than %s", v.Time, lag.Round(time.Second), maxLag)
+			}
+			return nil
+		}
+		return nil
+	}
+}
+
+// LastCompaction checks that compacter finished successfully within the last maxAge. Until the first successful run,
+// the time is measured from the moment LastCompaction was called. The collector must be passed to compacter
+// using compacter.Collect option.
+func LastCompaction(c *compacter.Collector, maxAge time.Duration) Check {
+	return lastSuccess("compacter", func() time.Time { return c.Metrics().LastSuccess }, maxAge)
+}
+
+// LastReplication checks that replicator finished successfully within the last maxAge. Until the first successful
+// run, the time is measured from the moment LastReplication was called. The collector must be passed to replicator
+// using replicator.Collect option.
+func LastReplication(c *replicator.Collector, maxAge time.Duration) Check {
+	return lastSuccess("replicator", func() time.Time { return c.Metrics().LastSuccess }, maxAge)
+}
+
+func lastSuccess(component string, last func() time.Time, maxAge time.Duration) Check {
+	created := time.Now()
+	return func(ctx context.Context) error {
+		t := last()
+		if t.IsZero() {
+			if age := time.Since(created); age > maxAge {
+				return fmt.Errorf("no successful %s run for %s", component, age.Round(time.Second))
+			}
+			return nil
+		}
+		if age := time.Since(t); age > maxAge {
+			return fmt.Errorf("last successful %s run was %s ago, which is more than %s", component, age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
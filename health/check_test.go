@@ -0,0 +1,188 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package health_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/health"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritable(t *testing.T) {
+
+	t.Run("should pass when store is writable", func(t *testing.T) {
+		check := health.Writable(tests.OpenStore(t))
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should fail when store directory does not exist", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(dir))
+		check := health.Writable(s)
+		// expect
+		assert.Error(t, check(context.Background()))
+	})
+}
+
+func TestLatestIntegral(t *testing.T) {
+
+	t.Run("should fail when store is empty", func(t *testing.T) {
+		check := health.LatestIntegral(tests.OpenStore(t), 0)
+		assert.Error(t, check(context.Background()))
+	})
+
+	t.Run("should pass when latest version is integral", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		check := health.LatestIntegral(s, time.Minute)
+		// expect
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should fail when latest version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		check := health.LatestIntegral(s, 0)
+		// when
+		err = check(context.Background())
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+	})
+
+	t.Run("should fail when entry of latest version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		err = codec.WriteEntries(s, map[string]codec.Encoder{"entry": func(writer io.Writer) error {
+			_, err := writer.Write([]byte("entry"))
+			return err
+		}})
+		require.NoError(t, err)
+		files, err := filepath.Glob(path.Join(dir, "*.entry"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		tests.CorruptFile(t, files[0]) // manifest in data file is still intact
+		check := health.LatestIntegral(s, 0)
+		// when
+		err = check(context.Background())
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+	})
+
+	t.Run("should fail when latest version is too old", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"), store.WriteTime(time.Now().Add(-time.Hour)))
+		check := health.LatestIntegral(s, time.Minute)
+		// expect
+		assert.Error(t, check(context.Background()))
+	})
+}
+
+func TestReplicationLag(t *testing.T) {
+
+	t.Run("should pass when both stores are empty", func(t *testing.T) {
+		check := health.ReplicationLag(tests.OpenStore(t), tests.OpenStore(t), time.Minute)
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should pass when latest version was replicated", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("data"), store.WriteTime(time.Now().Add(-time.Hour)))
+		require.NoError(t, replicator.CopyFromTo(from, to))
+		check := health.ReplicationLag(from, to, time.Minute)
+		// expect
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should pass when version waits for replication shorter than max lag", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("data"))
+		check := health.ReplicationLag(from, to, time.Minute)
+		// expect
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should fail when version waits for replication longer than max lag", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("v1"), store.WriteTime(time.Now().Add(-2*time.Hour)))
+		require.NoError(t, replicator.CopyFromTo(from, to))
+		tests.WriteData(t, from, []byte("v2"), store.WriteTime(time.Now().Add(-time.Hour)))
+		tests.WriteData(t, from, []byte("v3"))
+		check := health.ReplicationLag(from, to, time.Minute)
+		// expect
+		assert.Error(t, check(context.Background()))
+	})
+}
+
+func TestLastCompaction(t *testing.T) {
+
+	t.Run("should pass before first run when max age has not passed", func(t *testing.T) {
+		check := health.LastCompaction(&compacter.Collector{}, time.Minute)
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should fail when there was no successful run for max age", func(t *testing.T) {
+		check := health.LastCompaction(&compacter.Collector{}, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		// expect
+		assert.Error(t, check(context.Background()))
+	})
+
+	t.Run("should pass after successful run", func(t *testing.T) {
+		collector := &compacter.Collector{}
+		check := health.LastCompaction(collector, 50*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, compacter.RunOnce(tests.OpenStore(t), compacter.Collect(collector)))
+		// expect
+		assert.NoError(t, check(context.Background()))
+	})
+}
+
+func TestLastReplication(t *testing.T) {
+
+	t.Run("should fail when there was no successful run for max age", func(t *testing.T) {
+		check := health.LastReplication(&replicator.Collector{}, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		// expect
+		assert.Error(t, check(context.Background()))
+	})
+
+	t.Run("should pass after successful run", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("data"))
+		collector := &replicator.Collector{}
+		check := health.LastReplication(collector, 50*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to, replicator.Interval(time.Millisecond), replicator.Collect(collector))
+		})
+		defer func() {
+			cancel()
+			async.WaitOrFailAfter(t, time.Second)
+		}()
+		// expect
+		assert.Eventually(t, func() bool {
+			return check(context.Background()) == nil
+		}, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,116 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package health provides checks of store, compacter and replicator state, which can be used for example
+// by Kubernetes readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check returns error when checked component is not healthy
+type Check func(ctx context.Context) error
+
+// Checks is a list of named checks. Zero value is ready to use. Checks is safe for concurrent use.
+type Checks struct {
+	mutex  sync.Mutex
+	checks []namedCheck
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Add adds a named check. Name must be unique.
+func (c *Checks) Add(name string, check Check) error {
+	if check == nil {
+		return errors.New("nil check")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, existing := range c.checks {
+		if existing.name == name {
+			return fmt.Errorf("check %s already added", name)
+		}
+	}
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+	return nil
+}
+
+// Report contains results of all checks, in the order checks were added
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Checks  []Result `json:"checks"`
+}
+
+type Result struct {
+	Name     string        `json:"name"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"` // in nanoseconds when encoded to JSON
+}
+
+// Run runs all checks concurrently and waits for them to finish. Report is healthy when all checks passed.
+func (c *Checks) Run(ctx context.Context) Report {
+	c.mutex.Lock()
+	checks := append([]namedCheck{}, c.checks...)
+	c.mutex.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, check := range checks {
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Healthy: true, Checks: results}
+	for _, result := range results {
+		if !result.Healthy {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check namedCheck) Result {
+	start := time.Now()
+	err := check.check(ctx)
+	result := Result{
+		Name:     check.name,
+		Healthy:  err == nil,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ServeHTTP runs all checks and writes Report encoded to JSON. Status is 200 when report is healthy, otherwise 503.
+func (c *Checks) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	report := c.Run(req.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.WithError(err).Warn(req.Context(), "writing health report failed")
+	}
+}
@@ -0,0 +1,116 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elgopher/deebee/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecks_Add(t *testing.T) {
+
+	t.Run("should return error for nil check", func(t *testing.T) {
+		checks := &health.Checks{}
+		err := checks.Add("name", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when check with the same name was added", func(t *testing.T) {
+		checks := &health.Checks{}
+		require.NoError(t, checks.Add("name", passing))
+		// when
+		err := checks.Add("name", passing)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestChecks_Run(t *testing.T) {
+
+	t.Run("should return healthy report when there are no checks", func(t *testing.T) {
+		checks := &health.Checks{}
+		report := checks.Run(context.Background())
+		assert.True(t, report.Healthy)
+		assert.Empty(t, report.Checks)
+	})
+
+	t.Run("should return results in the order checks were added", func(t *testing.T) {
+		checks := &health.Checks{}
+		require.NoError(t, checks.Add("first", passing))
+		require.NoError(t, checks.Add("second", failing))
+		// when
+		report := checks.Run(context.Background())
+		// then
+		assert.False(t, report.Healthy)
+		require.Len(t, report.Checks, 2)
+		assert.Equal(t, "first", report.Checks[0].Name)
+		assert.True(t, report.Checks[0].Healthy)
+		assert.Empty(t, report.Checks[0].Error)
+		assert.Equal(t, "second", report.Checks[1].Name)
+		assert.False(t, report.Checks[1].Healthy)
+		assert.Equal(t, "failed", report.Checks[1].Error)
+	})
+
+	t.Run("should pass context to checks", func(t *testing.T) {
+		checks := &health.Checks{}
+		require.NoError(t, checks.Add("ctx", func(ctx context.Context) error {
+			return ctx.Err()
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		report := checks.Run(ctx)
+		// then
+		assert.False(t, report.Healthy)
+	})
+}
+
+func TestChecks_ServeHTTP(t *testing.T) {
+
+	t.Run("should return 200 when healthy", func(t *testing.T) {
+		checks := &health.Checks{}
+		require.NoError(t, checks.Add("passing", passing))
+		// when
+		recorder := serve(checks)
+		// then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+		assert.True(t, report.Healthy)
+		assert.Equal(t, "passing", report.Checks[0].Name)
+	})
+
+	t.Run("should return 503 when not healthy", func(t *testing.T) {
+		checks := &health.Checks{}
+		require.NoError(t, checks.Add("failing", failing))
+		// when
+		recorder := serve(checks)
+		// then
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"error":"failed"`)
+	})
+}
+
+func passing(context.Context) error {
+	return nil
+}
+
+func failing(context.Context) error {
+	return errors.New("failed")
+}
+
+func serve(checks *health.Checks) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	checks.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	return recorder
+}
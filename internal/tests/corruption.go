@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -20,6 +21,17 @@ func CorruptFiles(t *testing.T, dir string) {
 	}
 }
 
+// CorruptDataFiles corrupts only .data files leaving checksums intact
+func CorruptDataFiles(t *testing.T, dir string) {
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".data") {
+			CorruptFile(t, path.Join(dir, file.Name()))
+		}
+	}
+}
+
 func CorruptFile(t *testing.T, file string) {
 	stat, err := os.Lstat(file)
 	require.NoError(t, err)
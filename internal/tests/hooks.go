@@ -0,0 +1,70 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package tests
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elgopher/deebee/store"
+)
+
+// HooksMock records operations ended. It is safe for concurrent use.
+type HooksMock struct {
+	mutex sync.Mutex
+	ended []EndedOperation
+}
+
+type EndedOperation struct {
+	Operation store.Operation
+	Result    store.Result
+	// Started is true when context passed to End was returned by Start
+	Started bool
+	// TraceID is a value stored in context using WithTraceID. Empty when not set.
+	TraceID string
+}
+
+type startedKey struct{}
+
+type traceIDKey struct{}
+
+// WithTraceID returns context with id, which is recorded by HooksMock
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+func (h *HooksMock) Start(ctx context.Context, _ store.Operation) context.Context {
+	return context.WithValue(ctx, startedKey{}, true)
+}
+
+func (h *HooksMock) End(ctx context.Context, op store.Operation, result store.Result) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	started, _ := ctx.Value(startedKey{}).(bool)
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	h.ended = append(h.ended, EndedOperation{
+		Operation: op,
+		Result:    result,
+		Started:   started,
+		TraceID:   traceID,
+	})
+}
+
+// Ended returns a copy of all operations ended so far
+func (h *HooksMock) Ended() []EndedOperation {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]EndedOperation{}, h.ended...)
+}
+
+// Operations returns names of all operations ended so far
+func (h *HooksMock) Operations() []store.Operation {
+	var operations []store.Operation
+	for _, e := range h.Ended() {
+		operations = append(operations, e.Operation)
+	}
+	return operations
+}
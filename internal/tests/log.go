@@ -0,0 +1,48 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package tests
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elgopher/yala/logger"
+)
+
+// LoggerAdapterMock records all logged entries. It is safe for concurrent use.
+type LoggerAdapterMock struct {
+	mutex   sync.Mutex
+	entries []logger.Entry
+}
+
+func (l *LoggerAdapterMock) Log(_ context.Context, entry logger.Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = append(l.entries, entry)
+}
+
+// Entries returns a copy of all entries logged with a given message
+func (l *LoggerAdapterMock) Entries(message string) []logger.Entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var entries []logger.Entry
+	for _, e := range l.entries {
+		if e.Message == message {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Field returns value of field with a given key, or nil if entry has no such field
+func Field(entry logger.Entry, key string) interface{} {
+	for _, f := range entry.Fields {
+		if f.Key == key {
+			return f.Value
+		}
+	}
+	return nil
+}
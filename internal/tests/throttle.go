@@ -0,0 +1,29 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package tests
+
+import (
+	"context"
+	"sync"
+)
+
+// LimiterMock counts bytes passed to WaitN. It is safe for concurrent use.
+type LimiterMock struct {
+	mutex       sync.Mutex
+	bytes       int
+	ReturnError error
+}
+
+func (l *LimiterMock) WaitN(_ context.Context, n int) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.bytes += n
+	return l.ReturnError
+}
+
+func (l *LimiterMock) Bytes() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.bytes
+}
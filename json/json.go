@@ -4,6 +4,7 @@
 package json
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 
@@ -15,6 +16,10 @@ func Read(s codec.ReadOnlyStore, out interface{}, options ...store.ReaderOption)
 	return codec.Read(s, Decoder(out), options...)
 }
 
+func ReadContext(ctx context.Context, s codec.ReadOnlyStore, out interface{}, options ...store.ReaderOption) (store.Version, error) {
+	return codec.ReadContext(ctx, s, Decoder(out), options...)
+}
+
 func Decoder(out interface{}) codec.Decoder {
 	return func(reader io.Reader) error {
 		return json.NewDecoder(reader).Decode(out)
@@ -25,6 +30,10 @@ func Write(s codec.WriteOnlyStore, in interface{}, options ...store.WriterOption
 	return codec.Write(s, Encoder(in), options...)
 }
 
+func WriteContext(ctx context.Context, s codec.WriteOnlyStore, in interface{}, options ...store.WriterOption) error {
+	return codec.WriteContext(ctx, s, Encoder(in), options...)
+}
+
 func Encoder(in interface{}) codec.Encoder {
 	return func(writer io.Writer) error {
 		return json.NewEncoder(writer).Encode(in)
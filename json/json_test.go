@@ -4,6 +4,7 @@
 package json_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/elgopher/deebee/internal/tests"
@@ -61,6 +62,36 @@ func TestRead(t *testing.T) {
 	})
 }
 
+func TestContext(t *testing.T) {
+	t.Run("should write and read json using context", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// when
+		err := json.WriteContext(ctx, s, State{Field: "value"})
+		require.NoError(t, err)
+		out := State{}
+		_, err = json.ReadContext(ctx, s, &out)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, State{Field: "value"}, out)
+	})
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := json.WriteContext(ctx, s, State{Field: "value"})
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		// and when
+		_, err = json.ReadContext(ctx, s, &State{})
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
 func TestEncoder(t *testing.T) {
 	t.Run("should encode", func(t *testing.T) {
 		s := tests.OpenStore(t)
@@ -0,0 +1,199 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+type ClientOption func(*ClientOptions) error
+
+type ClientOptions struct {
+	httpClient *http.Client
+	retries    int
+	retryDelay time.Duration
+	chunkSize  int
+}
+
+// HTTPClient sets the client used to send requests. By default, http.DefaultClient is used.
+func HTTPClient(c *http.Client) ClientOption {
+	return func(o *ClientOptions) error {
+		if c == nil {
+			return errors.New("nil http client")
+		}
+		o.httpClient = c
+		return nil
+	}
+}
+
+// Retries sets how many times a request is retried after the connection failed, waiting delay before each retry.
+// Downloads and uploads are resumed from the last byte received. By default, requests are retried 3 times, 1 second
+// apart.
+func Retries(n int, delay time.Duration) ClientOption {
+	return func(o *ClientOptions) error {
+		if n < 0 {
+			return fmt.Errorf("number of retries must not be negative, got %d", n)
+		}
+		o.retries = n
+		o.retryDelay = delay
+		return nil
+	}
+}
+
+// ChunkSize sets the size of chunks in which data is uploaded. Chunk is kept in memory until Server receives it,
+// so it can be sent again. Default is 1 MiB.
+func ChunkSize(bytes int) ClientOption {
+	return func(o *ClientOptions) error {
+		if bytes <= 0 {
+			return fmt.Errorf("chunk size must be positive, got %d", bytes)
+		}
+		o.chunkSize = bytes
+		return nil
+	}
+}
+
+// Client accesses a store exposed by Server. Client is safe for concurrent use.
+type Client struct {
+	url        string
+	httpClient *http.Client
+	retries    int
+	retryDelay time.Duration
+	chunkSize  int
+}
+
+// NewClient returns Client for Server registered under url, for example http://host:8080/store
+func NewClient(url string, options ...ClientOption) (*Client, error) {
+	if url == "" {
+		return nil, errors.New("empty url")
+	}
+	opts := &ClientOptions{
+		httpClient: http.DefaultClient,
+		retries:    3,
+		retryDelay: time.Second,
+		chunkSize:  1024 * 1024,
+	}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: opts.httpClient,
+		retries:    opts.retries,
+		retryDelay: opts.retryDelay,
+		chunkSize:  opts.chunkSize,
+	}, nil
+}
+
+// Versions return slice sorted by time, oldest first
+func (c *Client) Versions() ([]store.Version, error) {
+	return c.VersionsContext(context.Background())
+}
+
+func (c *Client) VersionsContext(ctx context.Context) ([]store.Version, error) {
+	response, err := c.do(ctx, http.MethodGet, c.url+versionsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(response)
+	if response.StatusCode != http.StatusOK {
+		return nil, decodeError(response, c.url)
+	}
+	var list []versionJSON
+	if err = json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding versions: %w", err)
+	}
+	versions := make([]store.Version, len(list))
+	for i, v := range list {
+		versions[i] = v.version()
+	}
+	return versions, nil
+}
+
+func (c *Client) DeleteVersion(t time.Time) error {
+	return c.DeleteVersionContext(context.Background(), t)
+}
+
+func (c *Client) DeleteVersionContext(ctx context.Context, t time.Time) error {
+	response, err := c.do(ctx, http.MethodDelete, c.versionURL(t), nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(response)
+	if response.StatusCode != http.StatusNoContent {
+		return decodeError(response, c.url)
+	}
+	return nil
+}
+
+func (c *Client) versionURL(t time.Time) string {
+	return c.url + versionsPath + "/" + url.PathEscape(formatTime(t))
+}
+
+// do sends request, retrying when the connection failed. Response with any status is returned.
+func (c *Client) do(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		response, err := c.httpClient.Do(req)
+		if err == nil {
+			return response, nil
+		}
+		if err = c.beforeRetry(ctx, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// beforeRetry returns err when request should not be retried, otherwise waits retry delay
+func (c *Client) beforeRetry(ctx context.Context, attempt int, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if attempt >= c.retries {
+		return fmt.Errorf("error sending request to remote store %s: %w", c.url, err)
+	}
+	log.With("url", c.url).With("attempt", attempt+1).WithError(err).Debug(ctx, "retrying request to remote store")
+	select {
+	case <-time.After(c.retryDelay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func closeBody(response *http.Response) {
+	_ = response.Body.Close()
+}
+
+func parseVersion(header http.Header) (store.Version, error) {
+	t, err := time.Parse(time.RFC3339Nano, header.Get(versionTimeHeader))
+	if err != nil {
+		return store.Version{}, fmt.Errorf("invalid %s header: %w", versionTimeHeader, err)
+	}
+	size, err := strconv.ParseInt(header.Get(versionSizeHeader), 10, 64)
+	if err != nil {
+		return store.Version{}, fmt.Errorf("invalid %s header: %w", versionSizeHeader, err)
+	}
+	return store.Version{Time: t, Size: size}, nil
+}
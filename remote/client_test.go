@@ -0,0 +1,443 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/compacter"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/remote"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ codec.ReadOnlyStore  = &remote.Client{}
+	_ codec.WriteOnlyStore = &remote.Client{}
+	_ compacter.Store      = &remote.Client{}
+)
+
+func TestNewClient(t *testing.T) {
+
+	t.Run("should return error for empty url", func(t *testing.T) {
+		_, err := remote.NewClient("")
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for invalid options", func(t *testing.T) {
+		options := map[string]remote.ClientOption{
+			"nil http client":     remote.HTTPClient(nil),
+			"negative retries":    remote.Retries(-1, 0),
+			"zero chunk size":     remote.ChunkSize(0),
+			"negative chunk size": remote.ChunkSize(-1),
+		}
+		for name, option := range options {
+			t.Run(name, func(t *testing.T) {
+				_, err := remote.NewClient("http://localhost", option)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestClient_Writer(t *testing.T) {
+
+	t.Run("should write version", func(t *testing.T) {
+		s, client := startServer(t)
+		// when
+		writer, err := client.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), writer.Version().Size)
+		assert.Equal(t, []byte("data"), tests.ReadData(t, s))
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.True(t, versions[0].Time.Equal(writer.Version().Time))
+	})
+
+	t.Run("should write version in many chunks", func(t *testing.T) {
+		s, client := startServer(t, remote.ChunkSize(3))
+		writer, err := client.Writer()
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("chunked "))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("chunked data"), tests.ReadData(t, s))
+	})
+
+	t.Run("should write version with given time", func(t *testing.T) {
+		s, client := startServer(t)
+		versionTime := time.Date(2021, 5, 1, 10, 0, 0, 123, time.UTC)
+		// when
+		writeData(t, client, []byte("data"), store.WriteTime(versionTime))
+		// then
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.True(t, versionTime.Equal(versions[0].Time))
+	})
+
+	t.Run("should return VersionAlreadyExists error", func(t *testing.T) {
+		s, client := startServer(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		_, err := client.Writer(store.WriteTime(version.Time))
+		// then
+		assert.True(t, store.IsVersionAlreadyExists(err))
+	})
+
+	t.Run("should replace version", func(t *testing.T) {
+		s, client := startServer(t)
+		version := tests.WriteData(t, s, []byte("old"))
+		// when
+		writeData(t, client, []byte("new"), store.WriteTime(version.Time), store.Replace)
+		// then
+		assert.Equal(t, []byte("new"), tests.ReadData(t, s))
+	})
+
+	t.Run("should write segmented version", func(t *testing.T) {
+		s, client := startServer(t)
+		// when
+		writeData(t, client, []byte("segmented"), store.SegmentSize(2))
+		// then
+		assert.Equal(t, []byte("segmented"), tests.ReadData(t, s))
+	})
+
+	t.Run("should not make version visible when aborted", func(t *testing.T) {
+		s, client := startServer(t)
+		writer, err := client.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should resume upload when response was lost", func(t *testing.T) {
+		transport := &flakyTransport{failPatch: 2}
+		s, client := startServer(t, remote.ChunkSize(2), remote.HTTPClient(&http.Client{Transport: transport}))
+		// when
+		writeData(t, client, []byte("resumed"))
+		// then
+		assert.Equal(t, []byte("resumed"), tests.ReadData(t, s))
+		assert.Equal(t, 0, transport.failPatch)
+	})
+
+	t.Run("should return error when all retries failed", func(t *testing.T) {
+		transport := &flakyTransport{dropPatch: 100}
+		s, client := startServer(t, remote.ChunkSize(2), remote.HTTPClient(&http.Client{Transport: transport}))
+		writer, err := client.Writer()
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("data"))
+		// then
+		require.Error(t, err)
+		assert.Error(t, writer.Close())
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should return ChecksumMismatch error when data was corrupted in transit", func(t *testing.T) {
+		transport := &flakyTransport{corruptPatch: true}
+		s, client := startServer(t, remote.HTTPClient(&http.Client{Transport: transport}))
+		writer, err := client.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should stop writing when context is cancelled", func(t *testing.T) {
+		_, client := startServer(t, remote.ChunkSize(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		writer, err := client.WriterContext(ctx)
+		require.NoError(t, err)
+		// when
+		cancel()
+		_, err = writer.Write([]byte("data"))
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestClient_Reader(t *testing.T) {
+
+	t.Run("should read latest version", func(t *testing.T) {
+		s, client := startServer(t)
+		tests.WriteData(t, s, []byte("old"))
+		latest := tests.WriteData(t, s, []byte("latest"))
+		// when
+		data, version := readData(t, client)
+		// then
+		assert.Equal(t, []byte("latest"), data)
+		assert.True(t, latest.Time.Equal(version.Time))
+		assert.Equal(t, int64(6), version.Size)
+	})
+
+	t.Run("should read version with given time", func(t *testing.T) {
+		s, client := startServer(t)
+		old := tests.WriteData(t, s, []byte("old"))
+		tests.WriteData(t, s, []byte("latest"))
+		// when
+		data, _ := readData(t, client, store.Time(old.Time))
+		// then
+		assert.Equal(t, []byte("old"), data)
+	})
+
+	t.Run("should return VersionNotFound error", func(t *testing.T) {
+		_, client := startServer(t)
+		// when
+		_, err := client.Reader()
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return VersionTooLarge error", func(t *testing.T) {
+		s, client := startServer(t)
+		tests.WriteData(t, s, []byte("data"))
+		// when
+		_, err := client.Reader(store.MaxReadSize(3))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+	})
+
+	t.Run("should return error when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		client := newClient(t, s)
+		// when
+		reader, err := client.Reader()
+		if err == nil {
+			_, err = io.ReadAll(reader)
+			_ = reader.Close()
+		}
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should resume interrupted download", func(t *testing.T) {
+		transport := &flakyTransport{interruptGet: 2}
+		s, client := startServer(t, remote.HTTPClient(&http.Client{Transport: transport}))
+		tests.WriteData(t, s, []byte("resumed download"))
+		// when
+		data, _ := readData(t, client)
+		// then
+		assert.Equal(t, []byte("resumed download"), data)
+		assert.Equal(t, 0, transport.interruptGet)
+	})
+
+	t.Run("should return ChecksumMismatch error when data was corrupted in transit", func(t *testing.T) {
+		transport := &flakyTransport{corruptGet: true}
+		s, client := startServer(t, remote.HTTPClient(&http.Client{Transport: transport}))
+		tests.WriteData(t, s, []byte("data"))
+		reader, err := client.Reader()
+		require.NoError(t, err)
+		// when
+		_, err = io.ReadAll(reader)
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+		assert.Error(t, reader.Close())
+	})
+}
+
+func TestClient_Versions(t *testing.T) {
+
+	t.Run("should list versions", func(t *testing.T) {
+		s, client := startServer(t)
+		first := tests.WriteData(t, s, []byte("first"))
+		second := tests.WriteData(t, s, []byte("second!"))
+		// when
+		versions, err := client.Versions()
+		// then
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.True(t, first.Time.Equal(versions[0].Time))
+		assert.Equal(t, int64(5), versions[0].Size)
+		assert.True(t, second.Time.Equal(versions[1].Time))
+		assert.Equal(t, int64(7), versions[1].Size)
+	})
+
+	t.Run("should return error when server is not available", func(t *testing.T) {
+		client, err := remote.NewClient("http://localhost:1", remote.Retries(1, 0))
+		require.NoError(t, err)
+		// when
+		_, err = client.Versions()
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_DeleteVersion(t *testing.T) {
+
+	t.Run("should delete version", func(t *testing.T) {
+		s, client := startServer(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		err := client.DeleteVersion(version.Time)
+		// then
+		require.NoError(t, err)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should return VersionNotFound error", func(t *testing.T) {
+		_, client := startServer(t)
+		// when
+		err := client.DeleteVersion(time.Now())
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+}
+
+func TestReplication(t *testing.T) {
+	t.Run("should replicate version to remote store", func(t *testing.T) {
+		source := tests.OpenStore(t)
+		version := tests.WriteData(t, source, []byte("replicated"))
+		destination, client := startServer(t)
+		// when
+		err := replicator.CopyFromTo(source, client)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("replicated"), tests.ReadData(t, destination))
+		versions, err := destination.Versions()
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.True(t, version.Time.Equal(versions[0].Time))
+	})
+}
+
+func startServer(t *testing.T, options ...remote.ClientOption) (*store.Store, *remote.Client) {
+	s := tests.OpenStore(t)
+	return s, newClient(t, s, options...)
+}
+
+func newClient(t *testing.T, s *store.Store, options ...remote.ClientOption) *remote.Client {
+	server, err := remote.NewServer(s)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	options = append([]remote.ClientOption{remote.Retries(3, 0)}, options...)
+	client, err := remote.NewClient(httpServer.URL, options...)
+	require.NoError(t, err)
+	return client
+}
+
+func writeData(t *testing.T, client *remote.Client, data []byte, options ...store.WriterOption) {
+	writer, err := client.Writer(options...)
+	require.NoError(t, err)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+}
+
+func readData(t *testing.T, client *remote.Client, options ...store.ReaderOption) ([]byte, store.Version) {
+	reader, err := client.Reader(options...)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	return data, reader.Version()
+}
+
+// flakyTransport simulates network failures and data corruption
+type flakyTransport struct {
+	mutex        sync.Mutex
+	failPatch    int  // number of PATCH requests which are sent, but their responses are lost
+	dropPatch    int  // number of PATCH requests which are not sent at all
+	interruptGet int  // number of GET responses interrupted after the first byte
+	corruptPatch bool // corrupt first byte of PATCH body
+	corruptGet   bool // corrupt first byte of GET body
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if req.Method == http.MethodPatch && f.dropPatch > 0 {
+		f.dropPatch--
+		return nil, errors.New("connection refused")
+	}
+	if req.Method == http.MethodPatch && f.corruptPatch {
+		req.Body = &corruptingReader{ReadCloser: req.Body}
+	}
+	response, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case req.Method == http.MethodPatch && f.failPatch > 0:
+		f.failPatch--
+		_ = response.Body.Close()
+		return nil, errors.New("connection reset")
+	case req.Method == http.MethodGet && response.StatusCode < 300 && f.interruptGet > 0:
+		f.interruptGet--
+		response.Body = &interruptedReader{ReadCloser: response.Body}
+	case req.Method == http.MethodGet && f.corruptGet:
+		response.Body = &corruptingReader{ReadCloser: response.Body}
+	}
+	return response, nil
+}
+
+type interruptedReader struct {
+	io.ReadCloser
+	read bool
+}
+
+func (i *interruptedReader) Read(p []byte) (int, error) {
+	if i.read {
+		return 0, errors.New("connection reset")
+	}
+	i.read = true
+	return i.ReadCloser.Read(p[:1])
+}
+
+type corruptingReader struct {
+	io.ReadCloser
+	corrupted bool
+}
+
+func (c *corruptingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.corrupted {
+		p[0]++
+		c.corrupted = true
+	}
+	return n, err
+}
@@ -0,0 +1,167 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package remote
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/elgopher/deebee/store"
+)
+
+func (c *Client) Reader(options ...store.ReaderOption) (store.Reader, error) {
+	return c.ReaderContext(context.Background(), options...)
+}
+
+// ReaderContext opens Reader which aborts reading once ctx is done. Data is verified using checksum sent by Server,
+// and ChecksumMismatchError is returned by Read when it does not match. Interrupted download is resumed.
+func (c *Client) ReaderContext(ctx context.Context, options ...store.ReaderOption) (store.Reader, error) {
+	opts, err := store.ApplyReaderOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if opts.MaxSize() > 0 {
+		query.Set("maxSize", strconv.FormatInt(opts.MaxSize(), 10))
+	}
+	latestURL := c.url + versionsPath + "/" + latest
+	if !opts.Time().IsZero() {
+		latestURL = c.versionURL(opts.Time())
+	}
+	response, err := c.do(ctx, http.MethodGet, withQuery(latestURL, query), nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer closeBody(response)
+		return nil, decodeError(response, c.url)
+	}
+	version, err := parseVersion(response.Header)
+	if err != nil {
+		closeBody(response)
+		return nil, err
+	}
+	return &reader{
+		ctx:      ctx,
+		client:   c,
+		url:      withQuery(c.versionURL(version.Time), query), // resumed download must read the same version
+		version:  version,
+		response: response,
+		checksum: newHash(),
+	}, nil
+}
+
+func withQuery(u string, query url.Values) string {
+	if len(query) == 0 {
+		return u
+	}
+	return u + "?" + query.Encode()
+}
+
+type reader struct {
+	ctx      context.Context
+	client   *Client
+	url      string
+	version  store.Version
+	response *http.Response // nil when download must be resumed
+	checksum hash.Hash      // checksum of current response body
+	offset   int64          // number of bytes read so far
+	attempt  int            // number of failed attempts since data was received
+	err      error          // returned by all following Read calls
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for {
+		if r.response == nil {
+			if err := r.resume(); err != nil {
+				r.err = err
+				return 0, err
+			}
+		}
+		n, err := r.response.Body.Read(p)
+		_, _ = r.checksum.Write(p[:n])
+		r.offset += int64(n)
+		if n > 0 {
+			r.attempt = 0
+		}
+		if err == nil {
+			return n, nil
+		}
+		if err == io.EOF {
+			r.err = r.verify()
+			if r.err == nil {
+				r.err = io.EOF
+			}
+			return n, r.err
+		}
+
+		closeBody(r.response)
+		r.response = nil
+		if err = r.client.beforeRetry(r.ctx, r.attempt, err); err != nil {
+			r.err = err
+			return n, err
+		}
+		r.attempt++
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume requests remaining bytes of the version
+func (r *reader) resume() error {
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	response, err := r.client.do(r.ctx, http.MethodGet, r.url, header)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPartialContent {
+		defer closeBody(response)
+		return decodeError(response, r.client.url)
+	}
+	r.response = response
+	r.checksum = newHash()
+	return nil
+}
+
+func (r *reader) verify() error {
+	expected, err := hex.DecodeString(r.response.Trailer.Get(checksumHeader))
+	if err != nil || len(expected) == 0 {
+		return fmt.Errorf("remote store %s did not send valid checksum of version %s", r.client.url, r.version.Time)
+	}
+	actual := r.checksum.Sum(nil)
+	if hex.EncodeToString(expected) != hex.EncodeToString(actual) {
+		return store.ChecksumMismatchError{Version: r.version, File: r.url, Expected: expected, Actual: actual}
+	}
+	if r.offset != r.version.Size {
+		return fmt.Errorf("received %d bytes of version %s, expected %d", r.offset, r.version.Time, r.version.Size)
+	}
+	return nil
+}
+
+// Close returns error when data read so far could not be verified
+func (r *reader) Close() error {
+	if r.response != nil {
+		closeBody(r.response)
+		r.response = nil
+	}
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+func (r *reader) Version() store.Version {
+	return r.version
+}
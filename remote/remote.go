@@ -0,0 +1,123 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package remote provides access to a store on another host over HTTP. Server exposes *store.Store and Client
+// implements codec.ReadOnlyStore, codec.WriteOnlyStore and compacter.Store, so it can be used for example as
+// a destination of replicator.
+//
+// Data is verified on both ends. Server verifies the integrity of a version while sending it, and Client verifies
+// the checksum of received data sent by Server in HTTP trailer. Uploaded data is verified by Server before the
+// version is made visible. Interrupted downloads are resumed using Range requests, and uploads are sent in chunks,
+// which are resent from the last byte received by Server.
+package remote
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+const (
+	versionTimeHeader  = "X-Deebee-Version-Time"
+	versionSizeHeader  = "X-Deebee-Version-Size"
+	checksumHeader     = "X-Deebee-Checksum" // hex-encoded CRC-32 of the body, sent in trailer when downloading
+	uploadOffsetHeader = "X-Deebee-Upload-Offset"
+
+	versionsPath = "/versions"
+	uploadsPath  = "/uploads"
+	latest       = "latest"
+)
+
+func newHash() hash.Hash {
+	return crc32.NewIEEE()
+}
+
+func encodeChecksum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+type versionJSON struct {
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+func (v versionJSON) version() store.Version {
+	return store.Version{Time: v.Time, Size: v.Size}
+}
+
+// error codes allow Client to return the same typed errors as store package does
+const (
+	codeVersionNotFound      = "version_not_found"
+	codeVersionAlreadyExists = "version_already_exists"
+	codeChecksumMismatch     = "checksum_mismatch"
+	codeIncomplete           = "incomplete"
+	codeVersionTooLarge      = "version_too_large"
+	codeStoreFull            = "store_full"
+	codeInsufficientSpace    = "insufficient_space"
+)
+
+type errorJSON struct {
+	Error   string       `json:"error"`
+	Code    string       `json:"code,omitempty"`
+	Version *versionJSON `json:"version,omitempty"`
+}
+
+func statusAndCode(err error) (int, string) {
+	switch {
+	case store.IsVersionNotFound(err):
+		return http.StatusNotFound, codeVersionNotFound
+	case store.IsVersionAlreadyExists(err):
+		return http.StatusConflict, codeVersionAlreadyExists
+	case store.IsChecksumMismatch(err):
+		return http.StatusInternalServerError, codeChecksumMismatch
+	case store.IsIncomplete(err):
+		return http.StatusInternalServerError, codeIncomplete
+	case store.IsVersionTooLarge(err):
+		return http.StatusRequestEntityTooLarge, codeVersionTooLarge
+	case store.IsStoreFull(err):
+		return http.StatusInsufficientStorage, codeStoreFull
+	case store.IsInsufficientSpace(err):
+		return http.StatusInsufficientStorage, codeInsufficientSpace
+	}
+	return http.StatusInternalServerError, ""
+}
+
+// decodeError converts error response to the error returned by Server's store
+func decodeError(response *http.Response, url string) error {
+	var body errorJSON
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body.Error == "" {
+		return fmt.Errorf("remote store %s returned %s", url, response.Status)
+	}
+	var version store.Version
+	if body.Version != nil {
+		version = body.Version.version()
+	}
+	msg := fmt.Sprintf("remote store %s: %s", url, body.Error)
+	switch body.Code {
+	case codeVersionNotFound:
+		return store.NewVersionNotFoundError(msg)
+	case codeVersionAlreadyExists:
+		return store.NewVersionAlreadyExistsError(msg)
+	case codeChecksumMismatch:
+		return store.ChecksumMismatchError{Version: version, File: url}
+	case codeIncomplete:
+		return store.IncompleteError{Version: version, File: url}
+	case codeVersionTooLarge:
+		return store.VersionTooLargeError{Version: version}
+	case codeStoreFull:
+		return store.StoreFullError{Dir: url}
+	case codeInsufficientSpace:
+		return store.InsufficientSpaceError{Dir: url}
+	}
+	return fmt.Errorf("%s (%s)", msg, response.Status)
+}
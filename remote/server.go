@@ -0,0 +1,473 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+type ServerOption func(*ServerOptions) error
+
+type ServerOptions struct {
+	uploadTimeout time.Duration
+}
+
+// UploadTimeout aborts uploads which did not receive any data for a given time, so versions written by clients which
+// disappeared do not hold resources forever. Uploads are checked in the background each time the timeout elapses, as
+// long as there are any uploads. Default is 10 minutes.
+func UploadTimeout(d time.Duration) ServerOption {
+	return func(o *ServerOptions) error {
+		if d <= 0 {
+			return fmt.Errorf("upload timeout must be positive, got %s", d)
+		}
+		o.uploadTimeout = d
+		return nil
+	}
+}
+
+// Server is http.Handler exposing a store. It should be registered under the path passed to NewClient, using
+// http.StripPrefix when the path is not root. Server does not authenticate clients, so it should be wrapped with
+// a handler doing that when the network is not trusted. Server is safe for concurrent use.
+type Server struct {
+	store         *store.Store
+	uploadTimeout time.Duration
+
+	mutex   sync.Mutex
+	uploads map[string]*upload
+	reaper  *time.Timer // aborts expired uploads, nil when there are no uploads
+}
+
+// upload is a version being uploaded. Fields are guarded by Server.mutex, except writer and checksum which can be
+// used only by the request which set busy to true.
+type upload struct {
+	writer       store.Writer
+	checksum     hash.Hash
+	offset       int64
+	busy         bool
+	lastActivity time.Time
+	committed    *versionJSON // set once version was closed successfully
+}
+
+func NewServer(s *store.Store, options ...ServerOption) (*Server, error) {
+	if s == nil {
+		return nil, errors.New("nil store")
+	}
+	opts := &ServerOptions{uploadTimeout: 10 * time.Minute}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	return &Server{
+		store:         s,
+		uploadTimeout: opts.uploadTimeout,
+		uploads:       map[string]*upload{},
+	}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := "/" + strings.Trim(req.URL.Path, "/")
+	switch {
+	case path == versionsPath && req.Method == http.MethodGet:
+		s.serveVersions(w, req)
+	case strings.HasPrefix(path, versionsPath+"/") && req.Method == http.MethodGet:
+		s.serveVersion(w, req, strings.TrimPrefix(path, versionsPath+"/"))
+	case strings.HasPrefix(path, versionsPath+"/") && req.Method == http.MethodDelete:
+		s.deleteVersion(w, req, strings.TrimPrefix(path, versionsPath+"/"))
+	case path == uploadsPath && req.Method == http.MethodPost:
+		s.startUpload(w, req)
+	case strings.HasPrefix(path, uploadsPath+"/"):
+		s.serveUpload(w, req, strings.TrimPrefix(path, uploadsPath+"/"))
+	default:
+		writeError(w, req, http.StatusNotFound, "", errors.New("not found"), nil)
+	}
+}
+
+func (s *Server) serveVersions(w http.ResponseWriter, req *http.Request) {
+	versions, err := s.store.VersionsContext(req.Context())
+	if err != nil {
+		writeStoreError(w, req, err, nil)
+		return
+	}
+	list := make([]versionJSON, len(versions))
+	for i, version := range versions {
+		list[i] = versionJSON{Time: version.Time.UTC(), Size: version.Size}
+	}
+	writeJSON(w, req, http.StatusOK, list)
+}
+
+// serveVersion streams version data, skipping bytes requested by "Range: bytes=<offset>-" header. Skipped bytes are
+// read too, so the integrity of the whole version is verified. Checksum of the body is sent in trailer. When an error
+// is found after the body was partially sent, the response is aborted using http.ErrAbortHandler.
+func (s *Server) serveVersion(w http.ResponseWriter, req *http.Request, version string) {
+	options := []store.ReaderOption{}
+	if version != latest {
+		t, err := time.Parse(time.RFC3339Nano, version)
+		if err != nil {
+			writeError(w, req, http.StatusBadRequest, "", fmt.Errorf("invalid version time: %w", err), nil)
+			return
+		}
+		options = append(options, store.Time(t))
+	}
+	if maxSize := req.URL.Query().Get("maxSize"); maxSize != "" {
+		n, err := strconv.ParseInt(maxSize, 10, 64)
+		if err != nil {
+			writeError(w, req, http.StatusBadRequest, "", fmt.Errorf("invalid maxSize: %w", err), nil)
+			return
+		}
+		options = append(options, store.MaxReadSize(n))
+	}
+	offset, err := parseRange(req.Header.Get("Range"))
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, "", err, nil)
+		return
+	}
+
+	reader, err := s.store.ReaderContext(req.Context(), options...)
+	if err != nil {
+		writeStoreError(w, req, err, nil)
+		return
+	}
+	v := reader.Version()
+	if offset > v.Size {
+		_ = reader.Close()
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", v.Size))
+		writeError(w, req, http.StatusRequestedRangeNotSatisfiable, "", fmt.Errorf("offset %d exceeds version size %d", offset, v.Size), &v)
+		return
+	}
+	if _, err = io.CopyN(ioutil.Discard, reader, offset); err != nil {
+		_ = reader.Close()
+		writeStoreError(w, req, err, &v)
+		return
+	}
+
+	checksum := newHash()
+	body := &countingWriter{writer: io.MultiWriter(w, checksum)}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(versionTimeHeader, formatTime(v.Time))
+	w.Header().Set(versionSizeHeader, strconv.FormatInt(v.Size, 10))
+	w.Header().Set("Trailer", checksumHeader)
+	status := http.StatusOK
+	if offset > 0 {
+		status = http.StatusPartialContent
+		if offset < v.Size {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, v.Size-1, v.Size))
+		}
+	}
+
+	body.beforeFirstWrite = func() { w.WriteHeader(status) }
+	_, err = io.Copy(body, reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && body.written == 0 {
+		w.Header().Del("Trailer")
+		writeStoreError(w, req, err, &v)
+		return
+	}
+	if err != nil {
+		log.With("version", v.Time).WithError(err).Warn(req.Context(), "aborting response with version data")
+		panic(http.ErrAbortHandler)
+	}
+	if body.written == 0 {
+		w.WriteHeader(status)
+	}
+	w.Header().Set(checksumHeader, encodeChecksum(checksum))
+}
+
+// parseRange parses "bytes=<offset>-" range. Zero is returned when header is empty.
+func parseRange(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+	if !strings.HasPrefix(header, "bytes=") || !strings.HasSuffix(header, "-") {
+		return 0, fmt.Errorf("unsupported range %q", header)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(header, "bytes="), "-"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("unsupported range %q", header)
+	}
+	return offset, nil
+}
+
+type countingWriter struct {
+	writer           io.Writer
+	written          int64
+	beforeFirstWrite func()
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.written == 0 && c.beforeFirstWrite != nil {
+		c.beforeFirstWrite()
+		c.beforeFirstWrite = nil
+	}
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+func (s *Server) deleteVersion(w http.ResponseWriter, req *http.Request, version string) {
+	t, err := time.Parse(time.RFC3339Nano, version)
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, "", fmt.Errorf("invalid version time: %w", err), nil)
+		return
+	}
+	if err = s.store.DeleteVersionContext(req.Context(), t); err != nil {
+		writeStoreError(w, req, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startUpload opens a Writer with options passed in query: time, replace and segmentSize
+func (s *Server) startUpload(w http.ResponseWriter, req *http.Request) {
+	options, err := writerOptions(req.URL.Query())
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, "", err, nil)
+		return
+	}
+	id, err := newUploadID()
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "", err, nil)
+		return
+	}
+	// writer is not bound to request context, because it outlives the request
+	writer, err := s.store.Writer(options...)
+	if err != nil {
+		writeStoreError(w, req, err, nil)
+		return
+	}
+
+	s.mutex.Lock()
+	s.uploads[id] = &upload{writer: writer, checksum: newHash(), lastActivity: time.Now()}
+	s.scheduleReaper()
+	s.mutex.Unlock()
+
+	version := writer.Version()
+	w.Header().Set("Location", uploadsPath+"/"+id)
+	writeJSON(w, req, http.StatusCreated, uploadJSON{ID: id, Time: version.Time.UTC()})
+}
+
+type uploadJSON struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+func writerOptions(query url.Values) ([]store.WriterOption, error) {
+	var options []store.WriterOption
+	if t := query.Get("time"); t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version time: %w", err)
+		}
+		options = append(options, store.WriteTime(parsed))
+	}
+	if query.Get("replace") == "true" {
+		options = append(options, store.Replace)
+	}
+	if segmentSize := query.Get("segmentSize"); segmentSize != "" {
+		n, err := strconv.ParseInt(segmentSize, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segmentSize: %w", err)
+		}
+		options = append(options, store.SegmentSize(n))
+	}
+	return options, nil
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error generating upload id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// scheduleReaper starts the timer aborting expired uploads, unless it is already running or there are no uploads.
+// Must be called with mutex locked.
+func (s *Server) scheduleReaper() {
+	if s.reaper == nil && len(s.uploads) > 0 {
+		s.reaper = time.AfterFunc(s.uploadTimeout, s.reap)
+	}
+}
+
+func (s *Server) reap() {
+	s.abortExpiredUploads()
+
+	s.mutex.Lock()
+	s.reaper = nil
+	s.scheduleReaper()
+	s.mutex.Unlock()
+}
+
+// abortExpiredUploads aborts uploads which were not active for longer than upload timeout
+func (s *Server) abortExpiredUploads() {
+	s.mutex.Lock()
+	var expired []*upload
+	for id, u := range s.uploads {
+		if !u.busy && time.Since(u.lastActivity) > s.uploadTimeout {
+			delete(s.uploads, id)
+			if u.committed == nil {
+				expired = append(expired, u)
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, u := range expired {
+		u.writer.AbortAndClose()
+	}
+}
+
+// serveUpload handles requests for upload:
+//
+//	HEAD   returns the number of bytes received so far in X-Deebee-Upload-Offset header
+//	PATCH  appends body to the version, X-Deebee-Upload-Offset header must be equal to the number of bytes received
+//	PUT    closes the version, X-Deebee-Checksum header must match the checksum of received data
+//	DELETE aborts the version
+func (s *Server) serveUpload(w http.ResponseWriter, req *http.Request, id string) {
+	s.mutex.Lock()
+	u, ok := s.uploads[id]
+	if ok && u.busy {
+		s.mutex.Unlock()
+		writeError(w, req, http.StatusConflict, "", errors.New("upload is used by another request"), nil)
+		return
+	}
+	if ok {
+		u.busy = true
+	}
+	s.mutex.Unlock()
+	if !ok {
+		writeError(w, req, http.StatusNotFound, "", fmt.Errorf("upload %s not found", id), nil)
+		return
+	}
+	defer func() {
+		s.mutex.Lock()
+		u.busy = false
+		u.lastActivity = time.Now()
+		s.mutex.Unlock()
+	}()
+
+	switch req.Method {
+	case http.MethodHead:
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.offset, 10))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch:
+		s.appendToUpload(w, req, u)
+	case http.MethodPut:
+		s.commitUpload(w, req, id, u)
+	case http.MethodDelete:
+		if u.committed == nil {
+			u.writer.AbortAndClose()
+		}
+		s.deleteUpload(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "HEAD, PATCH, PUT, DELETE")
+		writeError(w, req, http.StatusMethodNotAllowed, "", fmt.Errorf("method %s not allowed", req.Method), nil)
+	}
+}
+
+func (s *Server) appendToUpload(w http.ResponseWriter, req *http.Request, u *upload) {
+	if u.committed != nil {
+		writeError(w, req, http.StatusConflict, "", errors.New("upload already committed"), nil)
+		return
+	}
+	offset, err := strconv.ParseInt(req.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, "", fmt.Errorf("invalid %s header: %w", uploadOffsetHeader, err), nil)
+		return
+	}
+	if offset != u.offset {
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.offset, 10))
+		writeError(w, req, http.StatusConflict, "", fmt.Errorf("expected offset %d, got %d", u.offset, offset), nil)
+		return
+	}
+	// offset is updated even when body is interrupted, so the client can resume from the last received byte
+	body := &countingWriter{writer: io.MultiWriter(u.writer, u.checksum)}
+	_, err = io.Copy(body, req.Body)
+	u.offset += body.written
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.offset, 10))
+	if err != nil {
+		writeStoreError(w, req, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) commitUpload(w http.ResponseWriter, req *http.Request, id string, u *upload) {
+	if u.committed != nil {
+		// client retried the request, because it did not receive the response
+		writeJSON(w, req, http.StatusOK, u.committed)
+		return
+	}
+	expected := req.Header.Get(checksumHeader)
+	if size := req.Header.Get(uploadOffsetHeader); size != strconv.FormatInt(u.offset, 10) {
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.offset, 10))
+		writeError(w, req, http.StatusConflict, "", fmt.Errorf("expected size %d, got %s", u.offset, size), nil)
+		return
+	}
+	if actual := encodeChecksum(u.checksum); expected != actual {
+		version := u.writer.Version()
+		u.writer.AbortAndClose()
+		s.deleteUpload(id)
+		err := fmt.Errorf("checksum of uploaded data %s does not match checksum %s sent by client", actual, expected)
+		writeError(w, req, http.StatusUnprocessableEntity, codeChecksumMismatch, err, &version)
+		return
+	}
+	if err := u.writer.Close(); err != nil {
+		version := u.writer.Version()
+		s.deleteUpload(id)
+		writeStoreError(w, req, err, &version)
+		return
+	}
+	version := u.writer.Version()
+	u.committed = &versionJSON{Time: version.Time.UTC(), Size: version.Size}
+	writeJSON(w, req, http.StatusOK, u.committed)
+}
+
+func (s *Server) deleteUpload(id string) {
+	s.mutex.Lock()
+	delete(s.uploads, id)
+	s.mutex.Unlock()
+}
+
+func writeStoreError(w http.ResponseWriter, req *http.Request, err error, version *store.Version) {
+	status, code := statusAndCode(err)
+	writeError(w, req, status, code, err, version)
+}
+
+func writeError(w http.ResponseWriter, req *http.Request, status int, code string, err error, version *store.Version) {
+	body := errorJSON{Error: err.Error(), Code: code}
+	if version != nil {
+		body.Version = &versionJSON{Time: version.Time.UTC(), Size: version.Size}
+	}
+	writeJSON(w, req, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, req *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn(req.Context(), "writing remote store response failed")
+	}
+}
@@ -0,0 +1,114 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package remote_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/remote"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServer(t *testing.T) {
+
+	t.Run("should return error for nil store", func(t *testing.T) {
+		_, err := remote.NewServer(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for invalid upload timeout", func(t *testing.T) {
+		_, err := remote.NewServer(tests.OpenStore(t), remote.UploadTimeout(0))
+		assert.Error(t, err)
+	})
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+
+	t.Run("should return 404 for unknown path", func(t *testing.T) {
+		recorder := serve(t, newServer(t), http.MethodGet, "/unknown", "")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("should return 400 for invalid version time", func(t *testing.T) {
+		recorder := serve(t, newServer(t), http.MethodGet, "/versions/yesterday", "")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should return 404 for unknown upload", func(t *testing.T) {
+		recorder := serve(t, newServer(t), http.MethodHead, "/uploads/unknown", "")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("should reject chunk with wrong offset", func(t *testing.T) {
+		server := newServer(t)
+		upload := startUpload(t, server)
+		// when
+		recorder := serve(t, server, http.MethodPatch, upload, "data", "X-Deebee-Upload-Offset", "2")
+		// then
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Equal(t, "0", recorder.Header().Get("X-Deebee-Upload-Offset"))
+	})
+
+	t.Run("should abort expired upload without waiting for other requests", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		server, err := remote.NewServer(s, remote.UploadTimeout(time.Millisecond))
+		require.NoError(t, err)
+		// when
+		upload := startUpload(t, server)
+		// then
+		assert.Eventually(t, func() bool {
+			files, err := ioutil.ReadDir(dir)
+			return err == nil && len(files) == 0
+		}, time.Second, time.Millisecond, "files of aborted upload were not removed")
+		recorder := serve(t, server, http.MethodHead, upload, "")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("should not abort upload which is active", func(t *testing.T) {
+		server, err := remote.NewServer(tests.OpenStore(t), remote.UploadTimeout(50*time.Millisecond))
+		require.NoError(t, err)
+		upload := startUpload(t, server)
+		// when
+		for i := 0; i < 10; i++ {
+			time.Sleep(10 * time.Millisecond)
+			recorder := serve(t, server, http.MethodHead, upload, "")
+			// then
+			require.Equal(t, http.StatusNoContent, recorder.Code)
+		}
+	})
+}
+
+func newServer(t *testing.T) *remote.Server {
+	server, err := remote.NewServer(tests.OpenStore(t))
+	require.NoError(t, err)
+	return server
+}
+
+// startUpload returns path of started upload
+func startUpload(t *testing.T, server *remote.Server) string {
+	recorder := serve(t, server, http.MethodPost, "/uploads", "")
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	return recorder.Header().Get("Location")
+}
+
+func serve(t *testing.T, server *remote.Server, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	require.Zero(t, len(header)%2, "header must be name-value pairs")
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,257 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+func (c *Client) Writer(options ...store.WriterOption) (store.Writer, error) {
+	return c.WriterContext(context.Background(), options...)
+}
+
+// WriterContext opens Writer which aborts writing once ctx is done. Data is sent to Server in chunks, and the version
+// is made visible by Close only when Server received all data and its checksum matches.
+func (c *Client) WriterContext(ctx context.Context, options ...store.WriterOption) (store.Writer, error) {
+	opts, err := store.ApplyWriterOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if !opts.Time().IsZero() {
+		query.Set("time", formatTime(opts.Time()))
+	}
+	if opts.Replace() {
+		query.Set("replace", "true")
+	}
+	if opts.SegmentSize() > 0 {
+		query.Set("segmentSize", strconv.FormatInt(opts.SegmentSize(), 10))
+	}
+	response, err := c.do(ctx, http.MethodPost, withQuery(c.url+uploadsPath, query), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(response)
+	if response.StatusCode != http.StatusCreated {
+		return nil, decodeError(response, c.url)
+	}
+	var upload uploadJSON
+	if err = json.NewDecoder(response.Body).Decode(&upload); err != nil {
+		return nil, fmt.Errorf("error decoding upload: %w", err)
+	}
+	return &writer{
+		ctx:      ctx,
+		client:   c,
+		url:      c.url + uploadsPath + "/" + url.PathEscape(upload.ID),
+		version:  store.Version{Time: upload.Time},
+		chunk:    make([]byte, 0, c.chunkSize),
+		checksum: newHash(),
+	}, nil
+}
+
+type writer struct {
+	ctx      context.Context
+	client   *Client
+	url      string
+	version  store.Version
+	chunk    []byte    // data not yet received by Server
+	offset   int64     // number of bytes received by Server
+	checksum hash.Hash // checksum of all written data
+	err      error     // returned by all following Write calls
+	closed   bool
+}
+
+// retriableError is returned when chunk can be sent again after checking how many bytes Server received
+type retriableError struct {
+	err error
+}
+
+func (r retriableError) Error() string {
+	return r.err.Error()
+}
+
+func (r retriableError) Unwrap() error {
+	return r.err
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("writer already closed")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := cap(w.chunk) - len(w.chunk)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.chunk = append(w.chunk, p[:n]...)
+		_, _ = w.checksum.Write(p[:n])
+		w.version.Size += int64(n)
+		written += n
+		p = p[n:]
+		if len(w.chunk) == cap(w.chunk) {
+			if err := w.flush(); err != nil {
+				w.err = err
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush sends chunk to Server, resuming from the last byte received when the connection failed
+func (w *writer) flush() error {
+	attempt := 0
+	for len(w.chunk) > 0 {
+		err := w.sendChunk()
+		if err == nil {
+			continue
+		}
+		var retriable retriableError
+		if !errors.As(err, &retriable) {
+			return err
+		}
+		if err = w.client.beforeRetry(w.ctx, attempt, retriable.err); err != nil {
+			return err
+		}
+		attempt++
+		if err = w.syncOffset(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) sendChunk() error {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPatch, w.url, bytes.NewReader(w.chunk))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(uploadOffsetHeader, strconv.FormatInt(w.offset, 10))
+	response, err := w.client.httpClient.Do(req)
+	if err != nil {
+		return retriableError{err: err}
+	}
+	defer closeBody(response)
+	switch {
+	case response.StatusCode == http.StatusNoContent:
+		return w.acceptOffset(response.Header)
+	case response.StatusCode == http.StatusConflict:
+		// previous request was interrupted, or it is still processed by Server
+		return retriableError{err: decodeError(response, w.client.url)}
+	default:
+		return decodeError(response, w.client.url)
+	}
+}
+
+// syncOffset asks Server how many bytes it received
+func (w *writer) syncOffset() error {
+	response, err := w.client.do(w.ctx, http.MethodHead, w.url, nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(response)
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("remote store %s returned %s", w.client.url, response.Status)
+	}
+	return w.acceptOffset(response.Header)
+}
+
+// acceptOffset removes bytes received by Server from chunk
+func (w *writer) acceptOffset(header http.Header) error {
+	offset, err := strconv.ParseInt(header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %w", uploadOffsetHeader, err)
+	}
+	received := offset - w.offset
+	if received < 0 || received > int64(len(w.chunk)) {
+		return fmt.Errorf("remote store %s received %d bytes, but %d bytes were sent", w.client.url, offset,
+			w.offset+int64(len(w.chunk)))
+	}
+	w.chunk = append(w.chunk[:0], w.chunk[received:]...)
+	w.offset = offset
+	return nil
+}
+
+// Close sends remaining data and makes version visible. Version is aborted when Close failed.
+func (w *writer) Close() error {
+	if w.closed {
+		return errors.New("writer already closed")
+	}
+	if w.err != nil {
+		w.AbortAndClose()
+		return w.err
+	}
+	if err := w.flush(); err != nil {
+		w.AbortAndClose()
+		return err
+	}
+	w.closed = true
+
+	header := http.Header{}
+	header.Set(uploadOffsetHeader, strconv.FormatInt(w.offset, 10))
+	header.Set(checksumHeader, encodeChecksum(w.checksum))
+	response, err := w.client.do(w.ctx, http.MethodPut, w.url, header) // Server handles retried commit
+	if err != nil {
+		w.deleteUpload()
+		return err
+	}
+	defer closeBody(response)
+	if response.StatusCode != http.StatusOK {
+		err = decodeError(response, w.client.url)
+		w.deleteUpload()
+		return err
+	}
+	var version versionJSON
+	if err = json.NewDecoder(response.Body).Decode(&version); err != nil {
+		return fmt.Errorf("error decoding version: %w", err)
+	}
+	w.version = version.version()
+	return nil
+}
+
+func (w *writer) Version() store.Version {
+	return w.version
+}
+
+func (w *writer) AbortAndClose() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.deleteUpload()
+}
+
+// abortTimeout limits time spent on aborting upload, which is done even when writer context is done
+const abortTimeout = 10 * time.Second
+
+// deleteUpload aborts the version on Server. When it fails, Server aborts the version after upload timeout.
+func (w *writer) deleteUpload() {
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, w.url, nil)
+	if err != nil {
+		return
+	}
+	response, err := w.client.httpClient.Do(req)
+	if err != nil {
+		log.With("url", w.url).WithError(err).Debug(w.ctx, "aborting upload failed")
+		return
+	}
+	closeBody(response)
+}
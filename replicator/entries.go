@@ -0,0 +1,83 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/deebee/throttle"
+)
+
+type entriesStore interface {
+	Entries(time.Time) ([]store.Entry, error)
+	EntryReaderContext(context.Context, string, ...store.ReaderOption) (store.Reader, error)
+}
+
+type entriesWriterStore interface {
+	EntriesWriterContext(context.Context, ...store.WriterOption) (store.EntriesWriter, error)
+}
+
+// copyEntries copies all entries to a new version with the same time and returns the number of bytes copied.
+// Manifest reader is read and closed before copying, so a corrupted manifest is never copied. Version is aborted when
+// any of entries could not be read.
+func copyEntries(ctx context.Context, from entriesStore, manifest store.Reader, to codec.WriteOnlyStore, entries []store.Entry, limiter throttle.Limiter) (int64, error) {
+	t := manifest.Version().Time
+	_, err := io.Copy(ioutil.Discard, manifest)
+	if closeErr := manifest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	dest, ok := to.(entriesWriterStore)
+	if !ok {
+		return 0, errors.New("<to> store does not support entries")
+	}
+	writer, err := dest.EntriesWriterContext(ctx, store.WriteTime(t))
+	if err != nil {
+		return 0, err
+	}
+	var copied int64
+	for _, entry := range entries {
+		n, err := copyEntry(ctx, from, writer, t, entry.Name, limiter)
+		if err != nil {
+			writer.AbortAndClose()
+			return 0, err
+		}
+		copied += n
+	}
+	if err = writer.Close(); err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+func copyEntry(ctx context.Context, from entriesStore, writer store.EntriesWriter, t time.Time, name string, limiter throttle.Limiter) (int64, error) {
+	reader, err := from.EntryReaderContext(ctx, name, store.Time(t))
+	if err != nil {
+		return 0, err
+	}
+	entryWriter, err := writer.Entry(name)
+	if err != nil {
+		_ = reader.Close()
+		return 0, err
+	}
+	var source io.Reader = reader
+	if limiter != nil {
+		source = throttle.Reader(ctx, reader, limiter)
+	}
+	n, err := io.Copy(entryWriter, source)
+	if err != nil {
+		_ = reader.Close()
+		return 0, err
+	}
+	return n, reader.Close()
+}
@@ -0,0 +1,74 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyEntries(t *testing.T) {
+
+	t.Run("should copy all entries of latest version preserving the time", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		err := codec.WriteEntries(from, map[string]codec.Encoder{
+			"users":  stringEncoder("alice"),
+			"orders": stringEncoder("1,2,3"),
+		})
+		require.NoError(t, err)
+		// when
+		err = replicator.CopyFromTo(from, to)
+		// then
+		require.NoError(t, err)
+		versions, err := from.Versions()
+		require.NoError(t, err)
+		entries, err := to.Entries(versions[0].Time)
+		require.NoError(t, err)
+		assert.Equal(t, []store.Entry{{Name: "orders", Size: 5}, {Name: "users", Size: 5}}, entries)
+		users := &tests.FakeDecoder{}
+		_, err = codec.ReadEntry(to, "users", users.Decode)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("alice"), users.DataRead())
+	})
+
+	t.Run("should not copy version when entry is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		from, err := store.Open(dir)
+		require.NoError(t, err)
+		to := tests.OpenStore(t)
+		err = codec.WriteEntries(from, map[string]codec.Encoder{"users": stringEncoder("alice")})
+		require.NoError(t, err)
+		tests.UpdateFiles(t, dir, ".entry", "bob")
+		// when
+		err = replicator.CopyFromTo(from, to)
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+		assert.True(t, numberOfVersions(to, 0)())
+	})
+
+	t.Run("should return error when destination does not support entries", func(t *testing.T) {
+		from := tests.OpenStore(t)
+		err := codec.WriteEntries(from, map[string]codec.Encoder{"users": stringEncoder("alice")})
+		require.NoError(t, err)
+		to := &tests.StoreMock{ReturnWriter: &tests.WriterMock{}}
+		// when
+		err = replicator.CopyFromTo(from, to)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func stringEncoder(s string) codec.Encoder {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
@@ -0,0 +1,438 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/deebee/throttle"
+)
+
+type Destination struct {
+	Name  string // unique name used in logs and Status
+	Store codec.WriteOnlyStore
+}
+
+// StartFanOut replicates the latest version of <from> store to all destinations asynchronously in one minute
+// intervals. Unlike running StartFromTo for each destination, data is read from <from> store once and written to all
+// destinations concurrently. Destination which failed does not stop replication to the others. Versions written with
+// EntriesWriter are read separately for each destination.
+//
+// StartFanOut accepts Interval, OnWrite, Throttle, Collect, TrackStatus and DestinationTimeout options.
+func StartFanOut(ctx context.Context, from codec.ReadOnlyStore, destinations []Destination, options ...Option) error {
+	if from == nil {
+		return errors.New("nil <from> store")
+	}
+	if len(destinations) == 0 {
+		return errors.New("no destinations")
+	}
+	names := map[string]bool{}
+	for _, d := range destinations {
+		if d.Store == nil {
+			return fmt.Errorf("nil store of destination %s", d.Name)
+		}
+		if d.Name == "" || names[d.Name] {
+			return fmt.Errorf("destination name %q is empty or not unique", d.Name)
+		}
+		names[d.Name] = true
+	}
+
+	opts := &Options{
+		interval:           time.Minute,
+		destinationTimeout: time.Minute,
+	}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	if opts.allNamespaces || opts.sync != nil {
+		return errors.New("AllNamespaces and SyncHistory options are not supported by StartFanOut")
+	}
+
+	return runLoop(ctx, from, opts, func() {
+		start := time.Now()
+		results := fanOut(ctx, from, destinations, opts.limiter, opts.destinationTimeout)
+		copied, bytesCopied, err := summarize(ctx, destinations, results)
+		if opts.status != nil {
+			// source is listed after the run, so versions created during the run are counted as not replicated
+			versions, listErr := codec.ListVersions(ctx, from)
+			if listErr != nil {
+				versions = nil
+			}
+			opts.status.observeRun(versions, destinations, results)
+		}
+		if opts.collector != nil {
+			opts.collector.observeRun(time.Since(start), copied, bytesCopied, err)
+		}
+	})
+}
+
+// fanOutResult is the result of replicating the latest version to one destination
+type fanOutResult struct {
+	version store.Version
+	bytes   int64
+	err     error
+}
+
+// summarize logs failed destinations and returns the number of versions and bytes copied and the first error
+func summarize(ctx context.Context, destinations []Destination, results []fanOutResult) (int, int64, error) {
+	var (
+		copied      int
+		bytesCopied int64
+		firstErr    error
+	)
+	for i, result := range results {
+		switch {
+		case result.err == nil:
+			copied++
+			bytesCopied += result.bytes
+		case store.IsVersionAlreadyExists(result.err):
+		default:
+			if ctx.Err() == nil {
+				log.With("destination", destinations[i].Name).WithError(result.err).Error(ctx, "replicator.StartFanOut failed")
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error replicating to destination %s: %w", destinations[i].Name, result.err)
+			}
+		}
+	}
+	return copied, bytesCopied, firstErr
+}
+
+// fanOut copies the latest version to all destinations and returns results in the order of destinations
+func fanOut(ctx context.Context, from codec.ReadOnlyStore, destinations []Destination, limiter throttle.Limiter, timeout time.Duration) []fanOutResult {
+	results := make([]fanOutResult, len(destinations))
+	failAll := func(err error) []fanOutResult {
+		for i := range results {
+			results[i].err = err
+		}
+		return results
+	}
+
+	reader, err := codec.OpenReader(ctx, from)
+	if err != nil {
+		return failAll(err)
+	}
+	version := reader.Version()
+	for i := range results {
+		results[i].version = version
+	}
+
+	if e, ok := from.(entriesStore); ok {
+		if _, err = e.Entries(version.Time); err == nil {
+			_ = reader.Close()
+			for i, d := range destinations {
+				results[i].bytes, results[i].err = copyVersionWithTime(ctx, from, d.Store, version.Time, limiter)
+			}
+			return results
+		}
+		if !store.IsVersionNotFound(err) {
+			_ = reader.Close()
+			return failAll(err)
+		}
+	}
+	options := []store.WriterOption{store.WriteTime(version.Time)}
+	if s, ok := from.(segmentedStore); ok {
+		segmentSize, err := s.VersionSegmentSize(version.Time)
+		if err != nil {
+			_ = reader.Close()
+			return failAll(err)
+		}
+		if segmentSize > 0 {
+			options = append(options, store.SegmentSize(segmentSize))
+		}
+	}
+
+	writer := &fanOutWriter{timeout: timeout}
+	for i, d := range destinations {
+		w, err := codec.OpenWriter(ctx, d.Store, options...)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		writer.targets = append(writer.targets, newFanOutTarget(w, &results[i]))
+	}
+	if len(writer.targets) == 0 {
+		_ = reader.Close()
+		return results
+	}
+
+	var source io.Reader = reader
+	if limiter != nil {
+		source = throttle.Reader(ctx, reader, limiter)
+	}
+	n, err := io.Copy(writer, source)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		writer.abort(err)
+		return results
+	}
+	writer.close(n)
+	return results
+}
+
+// fanOutBuffer is the number of chunks buffered for each destination, so a slower destination does not slow down
+// the others until its buffer is full
+const fanOutBuffer = 16
+
+// fanOutWriter writes data to all targets. Each target writes in its own goroutine, so targets do not wait for each
+// other. Target which failed, or did not accept data within timeout, is aborted and skipped in following writes, so
+// the other targets still receive the version. Write returns error only when all targets failed.
+type fanOutWriter struct {
+	targets []*fanOutTarget
+	timeout time.Duration
+}
+
+// fanOutTarget writes chunks to the writer of one destination. Writer is used only by the goroutine of target.
+type fanOutTarget struct {
+	writer   store.Writer
+	chunks   chan []byte
+	failed   chan struct{} // closed once target failed
+	finished chan struct{} // closed once goroutine finished
+
+	mutex  sync.Mutex // guards result
+	result *fanOutResult
+}
+
+var (
+	errAllDestinationsFailed = errors.New("writing to all destinations failed")
+	errDestinationTimeout    = errors.New("destination did not accept data within timeout")
+)
+
+func newFanOutTarget(writer store.Writer, result *fanOutResult) *fanOutTarget {
+	t := &fanOutTarget{
+		writer:   writer,
+		chunks:   make(chan []byte, fanOutBuffer),
+		failed:   make(chan struct{}),
+		finished: make(chan struct{}),
+		result:   result,
+	}
+	go t.run()
+	return t
+}
+
+// run writes chunks until chunks channel is closed, then closes the writer making the version visible. Writer is
+// aborted instead when target failed in the meantime.
+func (t *fanOutTarget) run() {
+	defer close(t.finished)
+
+	for chunk := range t.chunks {
+		if t.err() != nil {
+			break
+		}
+		if _, err := t.writer.Write(chunk); err != nil {
+			t.fail(err)
+			break
+		}
+	}
+	if t.err() != nil {
+		t.writer.AbortAndClose()
+		return
+	}
+	if err := t.writer.Close(); err != nil {
+		t.fail(err)
+	}
+}
+
+// fail sets error of target, unless target already failed
+func (t *fanOutTarget) fail(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.result.err == nil {
+		t.result.err = err
+		close(t.failed)
+	}
+}
+
+func (t *fanOutTarget) err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.result.err
+}
+
+// send waits until target accepted the chunk or failed. Target is failed when timeout elapsed.
+func (t *fanOutTarget) send(chunk []byte, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case t.chunks <- chunk:
+	case <-t.failed:
+	case <-timer.C:
+		t.fail(errDestinationTimeout)
+	}
+}
+
+func (f *fanOutWriter) Write(p []byte) (int, error) {
+	chunk := make([]byte, len(p)) // p may be reused by the caller, while targets are still writing
+	copy(chunk, p)
+	active := false
+	for _, t := range f.targets {
+		if t.err() == nil {
+			t.send(chunk, f.timeout)
+		}
+		if t.err() == nil {
+			active = true
+		}
+	}
+	if !active {
+		return 0, errAllDestinationsFailed
+	}
+	return len(p), nil
+}
+
+// abort fails targets which did not fail yet and waits until their writers are aborted
+func (f *fanOutWriter) abort(err error) {
+	for _, t := range f.targets {
+		t.fail(err)
+	}
+	f.wait()
+}
+
+// close waits until targets wrote all chunks and closed their writers, making the version visible
+func (f *fanOutWriter) close(written int64) {
+	f.wait()
+	for _, t := range f.targets {
+		t.mutex.Lock()
+		if t.result.err == nil {
+			t.result.bytes = written
+		}
+		t.mutex.Unlock()
+	}
+}
+
+// wait stops sending chunks and waits until goroutines of targets finished. Targets which did not finish within
+// timeout are failed and abandoned.
+func (f *fanOutWriter) wait() {
+	for _, t := range f.targets {
+		close(t.chunks)
+	}
+	deadline := time.NewTimer(f.timeout)
+	defer deadline.Stop()
+	expired := false
+	for _, t := range f.targets {
+		if !expired {
+			select {
+			case <-t.finished:
+			case <-deadline.C:
+				expired = true
+			}
+		}
+		select {
+		case <-t.finished:
+		default:
+			t.fail(errDestinationTimeout)
+		}
+	}
+}
+
+// DestinationTimeout fails destination of StartFanOut which did not accept a chunk of data, or did not finish
+// writing the version, within a given time. Hung destination is abandoned, so it does not stop replication to the
+// others. Default is one minute.
+func DestinationTimeout(d time.Duration) Option {
+	return func(o *Options) error {
+		if d <= 0 {
+			return fmt.Errorf("destination timeout must be positive, got %s", d)
+		}
+		o.destinationTimeout = d
+		return nil
+	}
+}
+
+// Status tracks replication to each destination of StartFanOut. Zero value is ready to use. Status is safe for
+// concurrent use, so it can be read while replicator is running in the background.
+type Status struct {
+	mutex        sync.Mutex
+	versions     []store.Version // versions of source store listed after the last run
+	destinations []DestinationStatus
+}
+
+type DestinationStatus struct {
+	Name        string
+	LastVersion store.Version // The latest version replicated to destination. Zero if nothing was replicated yet
+	LastSuccess time.Time     // Time when the last successful run finished. Zero if there was no successful run
+	LastError   error         // Error of the last run. Nil when the last run succeeded
+	// Lag is the age of the oldest version of source store which is newer than LastVersion, that is how long
+	// destination has been missing versions. Zero when destination is up to date.
+	Lag time.Duration
+}
+
+// TrackStatus updates status after each run of StartFanOut.
+func TrackStatus(status *Status) Option {
+	return func(o *Options) error {
+		o.status = status
+		return nil
+	}
+}
+
+// Destinations returns status of each destination in the order passed to StartFanOut. Nil is returned before the
+// first run finished. Lag is computed from versions of source store listed after the last run.
+func (s *Status) Destinations() []DestinationStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.destinations == nil {
+		return nil
+	}
+	statuses := make([]DestinationStatus, len(s.destinations))
+	copy(statuses, s.destinations)
+	for i := range statuses {
+		if missing, ok := oldestVersionAfter(s.versions, statuses[i].LastVersion); ok {
+			statuses[i].Lag = time.Since(missing.Time)
+		}
+	}
+	return statuses
+}
+
+// oldestVersionAfter returns the oldest of sorted versions which is newer than given version
+func oldestVersionAfter(versions []store.Version, version store.Version) (store.Version, bool) {
+	for _, v := range versions {
+		if v.Time.After(version.Time) {
+			return v, true
+		}
+	}
+	return store.Version{}, false
+}
+
+func (s *Status) observeRun(versions []store.Version, destinations []Destination, results []fanOutResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if versions != nil {
+		s.versions = versions
+	}
+	if len(s.destinations) != len(destinations) {
+		s.destinations = make([]DestinationStatus, len(destinations))
+		for i, d := range destinations {
+			s.destinations[i].Name = d.Name
+		}
+	}
+	now := time.Now()
+	for i, result := range results {
+		status := &s.destinations[i]
+		switch {
+		case result.err == nil || store.IsVersionAlreadyExists(result.err):
+			status.LastVersion = result.version
+			status.LastSuccess = now
+			status.LastError = nil
+		default:
+			status.LastError = result.err
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartFanOut(t *testing.T) {
+
+	t.Run("should return error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		cases := map[string]struct {
+			destinations []replicator.Destination
+			options      []replicator.Option
+		}{
+			"when there are no destinations": {},
+			"when destination store is nil": {
+				destinations: []replicator.Destination{{Name: "nil"}},
+			},
+			"when destination name is empty": {
+				destinations: []replicator.Destination{{Store: s}},
+			},
+			"when destination names are not unique": {
+				destinations: []replicator.Destination{{Name: "a", Store: s}, {Name: "a", Store: s}},
+			},
+			"when option is not supported": {
+				destinations: []replicator.Destination{{Name: "a", Store: s}},
+				options:      []replicator.Option{replicator.AllNamespaces},
+			},
+			"when destination timeout is not positive": {
+				destinations: []replicator.Destination{{Name: "a", Store: s}},
+				options:      []replicator.Option{replicator.DestinationTimeout(0)},
+			},
+		}
+		for name, test := range cases {
+			t.Run(name, func(t *testing.T) {
+				err := replicator.StartFanOut(context.Background(), s, test.destinations, test.options...)
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("should return error when from is nil", func(t *testing.T) {
+		err := replicator.StartFanOut(context.Background(), nil, []replicator.Destination{{Name: "a", Store: tests.OpenStore(t)}})
+		assert.Error(t, err)
+	})
+
+	t.Run("should copy latest version to all destinations reading it once", func(t *testing.T) {
+		from := &countingStore{Store: tests.OpenStore(t)}
+		first, second := tests.OpenStore(t), tests.OpenStore(t)
+		version := tests.WriteData(t, from.Store, []byte("data"))
+		collector := &replicator.Collector{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFanOut(ctx, from,
+				[]replicator.Destination{{Name: "first", Store: first}, {Name: "second", Store: second}},
+				replicator.Interval(time.Millisecond), replicator.Collect(collector))
+		})
+		// then
+		assert.Eventually(t, func() bool { return collector.Metrics().Runs > 0 }, time.Second, time.Millisecond)
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		assert.Equal(t, []byte("data"), tests.ReadData(t, first, store.Time(version.Time)))
+		assert.Equal(t, []byte("data"), tests.ReadData(t, second, store.Time(version.Time)))
+		assert.Equal(t, 2, collector.Metrics().Copied)
+		assert.Equal(t, int64(8), collector.Metrics().BytesCopied)
+		assert.LessOrEqual(t, from.readers, collector.Metrics().Runs, "version was read more than once per run")
+	})
+
+	t.Run("should copy to other destinations when one failed", func(t *testing.T) {
+		from, healthy := tests.OpenStore(t), tests.OpenStore(t)
+		failingWriter := &failingWriter{}
+		failing := &tests.StoreMock{ReturnWriter: failingWriter}
+		version := tests.WriteData(t, from, []byte("data"))
+		status := &replicator.Status{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFanOut(ctx, from,
+				[]replicator.Destination{{Name: "failing", Store: failing}, {Name: "healthy", Store: healthy}},
+				replicator.Interval(time.Millisecond), replicator.TrackStatus(status))
+		})
+		// then
+		assert.Eventually(t, func() bool { return len(status.Destinations()) == 2 }, time.Second, time.Millisecond)
+		destinations := status.Destinations()
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		assert.Equal(t, []byte("data"), tests.ReadData(t, healthy, store.Time(version.Time)))
+		assert.True(t, failingWriter.IsAborted())
+
+		failed, succeeded := destinations[0], destinations[1]
+		assert.Equal(t, "failing", failed.Name)
+		assert.Error(t, failed.LastError)
+		assert.True(t, failed.LastSuccess.IsZero())
+		assert.True(t, failed.LastVersion.Time.IsZero())
+		assert.Greater(t, int64(failed.Lag), int64(0))
+
+		assert.Equal(t, "healthy", succeeded.Name)
+		assert.NoError(t, succeeded.LastError)
+		assert.False(t, succeeded.LastSuccess.IsZero())
+		assert.True(t, version.Time.Equal(succeeded.LastVersion.Time))
+		assert.Zero(t, succeeded.Lag)
+	})
+
+	t.Run("should copy to other destinations when one is hung", func(t *testing.T) {
+		from, healthy := tests.OpenStore(t), tests.OpenStore(t)
+		hung := &tests.StoreMock{ReturnWriter: newHungWriter(t)}
+		version := tests.WriteData(t, from, make([]byte, 1024*1024))
+		status := &replicator.Status{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFanOut(ctx, from,
+				[]replicator.Destination{{Name: "hung", Store: hung}, {Name: "healthy", Store: healthy}},
+				replicator.Interval(time.Millisecond), replicator.TrackStatus(status),
+				replicator.DestinationTimeout(50*time.Millisecond))
+		})
+		// then
+		assert.Eventually(t, func() bool { return len(status.Destinations()) == 2 }, time.Second, time.Millisecond)
+		destinations := status.Destinations()
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		assert.Equal(t, version.Size, int64(len(tests.ReadData(t, healthy, store.Time(version.Time)))))
+		assert.Error(t, destinations[0].LastError)
+		assert.NoError(t, destinations[1].LastError)
+	})
+
+	t.Run("should report lag from the oldest version missing in destination", func(t *testing.T) {
+		from := tests.OpenStore(t)
+		now := time.Now()
+		tests.WriteData(t, from, []byte("replicated"), store.WriteTime(now.Add(-3*time.Hour)))
+		status := &replicator.Status{}
+		runFanOut := func(to codec.WriteOnlyStore) {
+			collector := &replicator.Collector{}
+			ctx, cancel := context.WithCancel(context.Background())
+			async := tests.RunAsync(func() {
+				_ = replicator.StartFanOut(ctx, from, []replicator.Destination{{Name: "to", Store: to}},
+					replicator.Interval(time.Millisecond), replicator.TrackStatus(status), replicator.Collect(collector))
+			})
+			assert.Eventually(t, func() bool { return collector.Metrics().Runs > 0 }, time.Second, time.Millisecond)
+			cancel()
+			async.WaitOrFailAfter(t, time.Second)
+		}
+		runFanOut(tests.OpenStore(t))
+		require.Zero(t, status.Destinations()[0].Lag)
+		tests.WriteData(t, from, []byte("missing"), store.WriteTime(now.Add(-2*time.Hour)))
+		tests.WriteData(t, from, []byte("missing"), store.WriteTime(now.Add(-time.Hour)))
+		// when
+		runFanOut(&tests.StoreMock{ReturnWriter: &failingWriter{}})
+		// then
+		lag := status.Destinations()[0].Lag
+		assert.GreaterOrEqual(t, int64(lag), int64(2*time.Hour))
+		assert.Less(t, int64(lag), int64(3*time.Hour))
+	})
+
+	t.Run("should not copy corrupted version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		from, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, from, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		to := tests.OpenStore(t)
+		status := &replicator.Status{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFanOut(ctx, from, []replicator.Destination{{Name: "to", Store: to}},
+				replicator.Interval(time.Millisecond), replicator.TrackStatus(status))
+		})
+		// then
+		assert.Eventually(t, func() bool { return len(status.Destinations()) == 1 }, time.Second, time.Millisecond)
+		destinations := status.Destinations()
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		assert.Error(t, destinations[0].LastError)
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+}
+
+func TestStatus_Destinations(t *testing.T) {
+	t.Run("should return nil before first run", func(t *testing.T) {
+		status := &replicator.Status{}
+		assert.Nil(t, status.Destinations())
+	})
+}
+
+type countingStore struct {
+	*store.Store
+	readers int
+}
+
+func (c *countingStore) ReaderContext(ctx context.Context, options ...store.ReaderOption) (store.Reader, error) {
+	c.readers++
+	return c.Store.ReaderContext(ctx, options...)
+}
+
+type failingWriter struct {
+	tests.WriterMock
+}
+
+func (f *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk failure")
+}
+
+// hungWriter blocks Write until the test is finished
+type hungWriter struct {
+	tests.WriterMock
+	release chan struct{}
+}
+
+func newHungWriter(t *testing.T) *hungWriter {
+	w := &hungWriter{release: make(chan struct{})}
+	t.Cleanup(func() { close(w.release) })
+	return w
+}
+
+func (h *hungWriter) Write(p []byte) (int, error) {
+	<-h.release
+	return len(p), nil
+}
@@ -0,0 +1,65 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+)
+
+type Metrics struct {
+	Runs           int       // Number of replication runs
+	Failed         int       // Number of runs which returned error (including runs stopped because context was done)
+	Copied         int       // Number of versions copied
+	AlreadyExisted int       // Number of runs which copied nothing, because the latest versions were already replicated
+	BytesCopied    int64     // Number of bytes of all copied versions
+	LastSuccess    time.Time // Time when the last successful run finished. Zero if there was no successful run
+	TotalTime      time.Duration
+	Latency        store.Histogram
+}
+
+// Collector collects Metrics of replication runs. Zero value is ready to use. Collector is safe for concurrent use,
+// so metrics can be read while replicator is running in the background.
+type Collector struct {
+	mutex   sync.Mutex
+	metrics Metrics
+}
+
+// Collect updates metrics of collector after each run of StartFromTo.
+func Collect(collector *Collector) Option {
+	return func(o *Options) error {
+		o.collector = collector
+		return nil
+	}
+}
+
+// Metrics returns a copy of metrics
+func (c *Collector) Metrics() Metrics {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.metrics
+}
+
+func (c *Collector) observeRun(elapsed time.Duration, copied int, bytesCopied int64, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.metrics.Runs++
+	c.metrics.Copied += copied
+	c.metrics.BytesCopied += bytesCopied
+	c.metrics.TotalTime += elapsed
+	c.metrics.Latency.Observe(elapsed)
+	switch {
+	case err != nil && !store.IsVersionAlreadyExists(err):
+		c.metrics.Failed++
+	case copied == 0:
+		c.metrics.AlreadyExisted++
+		c.metrics.LastSuccess = time.Now()
+	default:
+		c.metrics.LastSuccess = time.Now()
+	}
+}
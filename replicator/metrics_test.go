@@ -0,0 +1,61 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollect(t *testing.T) {
+
+	t.Run("should collect metrics of replication runs", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		data := []byte("data")
+		tests.WriteData(t, from, data)
+		collector := &replicator.Collector{}
+		before := time.Now()
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to, replicator.Interval(time.Millisecond), replicator.Collect(collector))
+		})
+		// when
+		assert.Eventually(t, func() bool {
+			return collector.Metrics().AlreadyExisted > 0
+		}, time.Second, time.Millisecond)
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		// then
+		metrics := collector.Metrics()
+		assert.Equal(t, 1, metrics.Copied)
+		assert.Equal(t, int64(len(data)), metrics.BytesCopied)
+		assert.Equal(t, metrics.Runs, metrics.Copied+metrics.AlreadyExisted+metrics.Failed)
+		assert.False(t, metrics.LastSuccess.Before(before))
+		assert.Equal(t, metrics.Runs, metrics.Latency.Count)
+	})
+
+	t.Run("should count failed runs", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		collector := &replicator.Collector{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to, replicator.Interval(time.Millisecond), replicator.Collect(collector))
+		})
+		// when
+		assert.Eventually(t, func() bool {
+			return collector.Metrics().Failed > 0 // there is no version in from store
+		}, time.Second, time.Millisecond)
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		// then
+		metrics := collector.Metrics()
+		assert.Zero(t, metrics.Copied)
+		assert.True(t, metrics.LastSuccess.IsZero())
+	})
+}
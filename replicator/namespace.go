@@ -0,0 +1,87 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+// NamespacedStore is implemented by *store.Store
+type NamespacedStore interface {
+	Namespaces() ([]string, error)
+	Namespace(name string) (*store.Store, error)
+}
+
+// AllNamespaces makes StartFromTo replicate the latest version of each namespace of <from> store, including nested
+// ones, to the namespace with the same name in <to> store. When SyncHistory was used, all missing versions of each
+// namespace are copied. Namespaces without versions are skipped. Replication continues when one of namespaces failed.
+// Both stores must implement NamespacedStore.
+var AllNamespaces Option = func(o *Options) error {
+	o.allNamespaces = true
+	return nil
+}
+
+func checkNamespaced(from codec.ReadOnlyStore, to codec.WriteOnlyStore) error {
+	if _, ok := from.(NamespacedStore); !ok {
+		return errors.New("<from> store does not support namespaces")
+	}
+	if _, ok := to.(NamespacedStore); !ok {
+		return errors.New("<to> store does not support namespaces")
+	}
+	return nil
+}
+
+// replicateNamespaces returns the number of versions and bytes copied. The first error is returned.
+func replicateNamespaces(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore, copyVersions copyFunc) (int, int64, error) {
+	var (
+		copied      int
+		bytesCopied int64
+	)
+	c, n, err := copyVersions(ctx, from, to)
+	switch {
+	case err == nil:
+		copied += c
+		bytesCopied += n
+	case store.IsVersionAlreadyExists(err) || store.IsVersionNotFound(err):
+		err = nil // nothing to copy
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return copied, bytesCopied, ctxErr
+	}
+
+	fromNamespaced, toNamespaced := from.(NamespacedStore), to.(NamespacedStore)
+	names, nsErr := fromNamespaced.Namespaces()
+	if nsErr != nil {
+		return copied, bytesCopied, fmt.Errorf("error listing namespaces: %w", nsErr)
+	}
+	for _, name := range names {
+		c, b, nsErr := replicateNamespace(ctx, fromNamespaced, toNamespaced, name, copyVersions)
+		copied += c
+		bytesCopied += b
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return copied, bytesCopied, ctxErr
+		}
+		if nsErr != nil && err == nil {
+			err = fmt.Errorf("error replicating namespace %s: %w", name, nsErr)
+		}
+	}
+	return copied, bytesCopied, err
+}
+
+func replicateNamespace(ctx context.Context, from, to NamespacedStore, name string, copyVersions copyFunc) (int, int64, error) {
+	fromNamespace, err := from.Namespace(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	toNamespace, err := to.Namespace(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return replicateNamespaces(ctx, fromNamespace, toNamespace, copyVersions)
+}
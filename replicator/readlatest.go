@@ -25,15 +25,19 @@ func ReadLatest(decoder codec.Decoder, stores ...codec.ReadOnlyStore) (store.Ver
 			return store.Version{}, errors.New("nil store")
 		}
 	}
+	var err error
 	versions := listStoreVersions(stores)
 	for versions.hasMore() {
 		storeIndex, version := versions.removeLatestVersion()
 		s := stores[storeIndex]
-		_, err := codec.Read(s, decoder, store.Time(version.Time))
+		_, err = codec.Read(s, decoder, store.Time(version.Time))
 		if err == nil {
 			return version, nil
 		}
 	}
+	if err != nil {
+		return store.Version{}, store.NewVersionNotFoundErrorWithCause("no version can be decoded", err)
+	}
 	return store.Version{}, store.NewVersionNotFoundError("no version can be decoded")
 }
 
@@ -0,0 +1,101 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+// Repair finds corrupted versions in local store and restores them using intact copies from replicas.
+//
+// A copy is used only when it has the same time and checksum as the local version and it can be read without errors.
+// Replicas are tried in the order given. Versions are restored atomically using store.Replace.
+//
+// Repair returns all restored versions. Error is returned when at least one corrupted version could not be repaired.
+func Repair(local RepairableStore, replicas ...ReplicaStore) ([]store.Version, error) {
+	if local == nil {
+		return nil, errors.New("nil local store")
+	}
+	for _, replica := range replicas {
+		if replica == nil {
+			return nil, errors.New("nil replica store")
+		}
+	}
+
+	versions, err := local.Versions()
+	if err != nil {
+		return nil, fmt.Errorf("error getting versions: %w", err)
+	}
+
+	var (
+		repaired    []store.Version
+		notRepaired int
+	)
+	for _, version := range versions {
+		if !isCorrupted(local, version) {
+			continue
+		}
+		if repairVersion(local, version.Time, replicas) {
+			repaired = append(repaired, version)
+		} else {
+			notRepaired++
+		}
+	}
+
+	if notRepaired > 0 {
+		return repaired, fmt.Errorf("%d corrupted versions could not be repaired", notRepaired)
+	}
+	return repaired, nil
+}
+
+type RepairableStore interface {
+	codec.ReadOnlyStore
+	codec.WriteOnlyStore
+	Checksum(time.Time) ([]byte, error)
+}
+
+type ReplicaStore interface {
+	codec.ReadOnlyStore
+	Checksum(time.Time) ([]byte, error)
+}
+
+func isCorrupted(s codec.ReadOnlyStore, version store.Version) bool {
+	_, err := codec.Read(s, readAllDiscarding, store.Time(version.Time))
+	return store.IsCorrupted(err)
+}
+
+func repairVersion(local RepairableStore, t time.Time, replicas []ReplicaStore) bool {
+	expectedChecksum, err := local.Checksum(t)
+	if err != nil {
+		return false
+	}
+	for _, replica := range replicas {
+		checksum, err := replica.Checksum(t)
+		if err != nil || !bytes.Equal(expectedChecksum, checksum) {
+			continue
+		}
+		reader, err := replica.Reader(store.Time(t))
+		if err != nil {
+			continue
+		}
+		if _, err = copyVersion(context.Background(), reader, local, nil, store.Replace); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func readAllDiscarding(reader io.Reader) error {
+	_, err := io.Copy(ioutil.Discard, reader)
+	return err
+}
@@ -0,0 +1,104 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepair(t *testing.T) {
+
+	t.Run("should return error when local store is nil", func(t *testing.T) {
+		_, err := replicator.Repair(nil, tests.OpenStore(t))
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when replica is nil", func(t *testing.T) {
+		_, err := replicator.Repair(tests.OpenStore(t), nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should not repair anything when versions are not corrupted", func(t *testing.T) {
+		local, replica := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, local, []byte("data"))
+		// when
+		repaired, err := replicator.Repair(local, replica)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, repaired)
+	})
+
+	t.Run("should repair corrupted version using replica", func(t *testing.T) {
+		localDir := tests.TempDir(t)
+		local, _ := store.Open(localDir)
+		replica := tests.OpenStore(t)
+		data := []byte("data")
+		version := tests.WriteData(t, local, data)
+		tests.WriteData(t, replica, data, store.WriteTime(version.Time))
+		tests.CorruptDataFiles(t, localDir)
+		// when
+		repaired, err := replicator.Repair(local, replica)
+		// then
+		require.NoError(t, err)
+		require.Len(t, repaired, 1)
+		assert.True(t, version.Time.Equal(repaired[0].Time))
+		assert.Equal(t, data, tests.ReadData(t, local, store.Time(version.Time)))
+	})
+
+	t.Run("should skip replica with different checksum", func(t *testing.T) {
+		localDir := tests.TempDir(t)
+		local, _ := store.Open(localDir)
+		different, replica := tests.OpenStore(t), tests.OpenStore(t)
+		data := []byte("data")
+		version := tests.WriteData(t, local, data)
+		tests.WriteData(t, different, []byte("other"), store.WriteTime(version.Time))
+		tests.WriteData(t, replica, data, store.WriteTime(version.Time))
+		tests.CorruptDataFiles(t, localDir)
+		// when
+		repaired, err := replicator.Repair(local, different, replica)
+		// then
+		require.NoError(t, err)
+		assert.Len(t, repaired, 1)
+		assert.Equal(t, data, tests.ReadData(t, local, store.Time(version.Time)))
+	})
+
+	t.Run("should skip corrupted replica", func(t *testing.T) {
+		localDir, corruptedDir := tests.TempDir(t), tests.TempDir(t)
+		local, _ := store.Open(localDir)
+		corrupted, _ := store.Open(corruptedDir)
+		replica := tests.OpenStore(t)
+		data := []byte("data")
+		version := tests.WriteData(t, local, data)
+		tests.WriteData(t, corrupted, data, store.WriteTime(version.Time))
+		tests.WriteData(t, replica, data, store.WriteTime(version.Time))
+		tests.CorruptDataFiles(t, localDir)
+		tests.CorruptDataFiles(t, corruptedDir)
+		// when
+		repaired, err := replicator.Repair(local, corrupted, replica)
+		// then
+		require.NoError(t, err)
+		assert.Len(t, repaired, 1)
+		assert.Equal(t, data, tests.ReadData(t, local, store.Time(version.Time)))
+	})
+
+	t.Run("should return error when intact copy was not found", func(t *testing.T) {
+		localDir := tests.TempDir(t)
+		local, _ := store.Open(localDir)
+		replica := tests.OpenStore(t)
+		version := tests.WriteData(t, local, []byte("data"))
+		tests.WriteData(t, replica, []byte("other"), store.WriteTime(version.Time))
+		tests.CorruptDataFiles(t, localDir)
+		// when
+		repaired, err := replicator.Repair(local, replica)
+		// then
+		assert.Error(t, err)
+		assert.Empty(t, repaired)
+	})
+}
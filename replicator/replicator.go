@@ -3,7 +3,7 @@
 
 // Package replicator provides very simple replication functionality which copies most recent state version from one store
 // to another. This can be used for example when you want to replicate state from local disk to a shared (network) disk
-// such as AWS EFS.
+// such as AWS EFS. Sync copies the whole version history instead.
 package replicator
 
 import (
@@ -15,19 +15,27 @@ import (
 
 	"github.com/elgopher/deebee/codec"
 	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/deebee/throttle"
 )
 
 func CopyFromTo(from codec.ReadOnlyStore, to codec.WriteOnlyStore) error {
+	return CopyFromToContext(context.Background(), from, to)
+}
+
+// CopyFromToContext is like CopyFromTo but aborts copying once ctx is done. Partially written version is aborted.
+func CopyFromToContext(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore) error {
 	if from == nil {
 		return errors.New("nil <from> store")
 	}
 	if to == nil {
 		return errors.New("nil <to> store")
 	}
-	return copyLatest(from, to)
+	_, err := copyLatest(ctx, from, to, nil)
+	return err
 }
 
-// StartFromTo replicates state asynchronously in one minute intervals
+// StartFromTo replicates state asynchronously in one minute intervals. Use OnWrite to replicate new versions
+// immediately.
 func StartFromTo(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore, options ...Option) error {
 	if from == nil {
 		return errors.New("nil <from> store")
@@ -48,22 +56,42 @@ func StartFromTo(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOn
 		}
 	}
 
-	for {
-		select {
-		case <-time.After(opts.interval):
-			if err := CopyFromTo(from, to); err != nil && !store.IsVersionAlreadyExists(err) {
-				log.WithError(err).Error(ctx, "replicator.CopyFromTo failed")
-			}
-		case <-ctx.Done():
-			return nil
+	if opts.allNamespaces {
+		if err := checkNamespaced(from, to); err != nil {
+			return err
 		}
 	}
+	if opts.sync != nil {
+		if err := checkSyncDestination(to, opts.sync); err != nil {
+			return err
+		}
+	}
+
+	return runLoop(ctx, from, opts, func() {
+		start := time.Now()
+		copied, bytesCopied, err := replicate(ctx, from, to, opts)
+		if opts.collector != nil {
+			opts.collector.observeRun(time.Since(start), copied, bytesCopied, err)
+		}
+		if err != nil && !store.IsVersionAlreadyExists(err) && ctx.Err() == nil {
+			log.WithError(err).Error(ctx, "replicator.CopyFromTo failed")
+		}
+	})
 }
 
 type Option func(*Options) error
 
 type Options struct {
-	interval time.Duration
+	interval      time.Duration
+	limiter       throttle.Limiter
+	collector     *Collector
+	allNamespaces bool
+	sync          *SyncOptions
+	status        *Status
+	onWrite       bool
+	debounce      time.Duration
+
+	destinationTimeout time.Duration
 }
 
 func Interval(d time.Duration) Option {
@@ -73,25 +101,121 @@ func Interval(d time.Duration) Option {
 	}
 }
 
-func copyLatest(from codec.ReadOnlyStore, to codec.WriteOnlyStore) error {
-	reader, err := from.Reader()
+// SyncHistory makes StartFromTo copy all versions missing in <to> store instead of only the latest one, the same way
+// Sync does. <to> store must implement SyncDestination.
+func SyncHistory(options ...SyncOption) Option {
+	return func(o *Options) error {
+		o.sync = &SyncOptions{}
+		for _, apply := range options {
+			if apply == nil {
+				continue
+			}
+			if err := apply(o.sync); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Throttle limits the number of bytes per second copied by StartFromTo. Bytes are counted once, when read from the
+// source store.
+func Throttle(limiter throttle.Limiter) Option {
+	return func(o *Options) error {
+		o.limiter = limiter
+		return nil
+	}
+}
+
+// copyFunc copies versions and returns the number of versions and bytes copied
+type copyFunc func(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore) (int, int64, error)
+
+// replicate copies the latest version, or all missing versions when SyncHistory was used. When AllNamespaces was used,
+// versions of each namespace are copied too. It returns the number of versions and bytes copied.
+func replicate(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore, opts *Options) (int, int64, error) {
+	copyVersions := func(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore) (int, int64, error) {
+		n, err := copyLatest(ctx, from, to, opts.limiter)
+		if err != nil {
+			return 0, 0, err
+		}
+		return 1, n, nil
+	}
+	if opts.sync != nil {
+		copyVersions = func(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore) (int, int64, error) {
+			result, err := syncVersions(ctx, from, to.(SyncDestination), opts.sync, opts.limiter)
+			return len(result.Copied), result.BytesCopied, err
+		}
+	}
+	if opts.allNamespaces {
+		return replicateNamespaces(ctx, from, to, copyVersions)
+	}
+	return copyVersions(ctx, from, to)
+}
+
+func copyLatest(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore, limiter throttle.Limiter) (int64, error) {
+	reader, err := codec.OpenReader(ctx, from)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	return copyOpened(ctx, from, reader, to, limiter)
+}
+
+// copyOpened copies version opened by reader, preserving its entries and segment size. Reader is always closed.
+func copyOpened(ctx context.Context, from codec.ReadOnlyStore, reader store.Reader, to codec.WriteOnlyStore, limiter throttle.Limiter) (int64, error) {
+	if e, ok := from.(entriesStore); ok {
+		entries, err := e.Entries(reader.Version().Time)
+		if err == nil {
+			return copyEntries(ctx, e, reader, to, entries, limiter)
+		}
+		if !store.IsVersionNotFound(err) {
+			_ = reader.Close()
+			return 0, err
+		}
+	}
+	var options []store.WriterOption
+	if s, ok := from.(segmentedStore); ok {
+		segmentSize, err := s.VersionSegmentSize(reader.Version().Time)
+		if err != nil {
+			_ = reader.Close()
+			return 0, err
+		}
+		if segmentSize > 0 {
+			options = append(options, store.SegmentSize(segmentSize))
+		}
 	}
-	writer, err := to.Writer(store.WriteTime(reader.Version().Time))
+	return copyVersion(ctx, reader, to, limiter, options...)
+}
+
+// segmentedStore is implemented by *store.Store. Segmented version is copied with the same segment size.
+type segmentedStore interface {
+	VersionSegmentSize(time.Time) (int64, error)
+}
+
+// copyVersion copies data from reader to a new version with the same time and returns the number of bytes copied.
+// Zero is returned when version was not copied. Reader is always closed. Limiter can be nil.
+func copyVersion(ctx context.Context, reader store.Reader, to codec.WriteOnlyStore, limiter throttle.Limiter, options ...store.WriterOption) (int64, error) {
+	options = append([]store.WriterOption{store.WriteTime(reader.Version().Time)}, options...)
+	writer, err := codec.OpenWriter(ctx, to, options...)
 	if err != nil {
 		_ = reader.Close()
-		return err
+		return 0, err
 	}
-	_, err = io.Copy(writer, reader)
+	var source io.Reader = reader
+	if limiter != nil {
+		source = throttle.Reader(ctx, reader, limiter)
+	}
+	n, err := io.Copy(writer, source)
 	if err != nil {
 		writer.AbortAndClose()
 		_ = reader.Close()
-		return err
+		return 0, err
 	}
 	if err := reader.Close(); err != nil {
 		writer.AbortAndClose()
-		return err
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
 	}
-	return writer.Close()
+	return n, nil
 }
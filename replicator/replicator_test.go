@@ -72,6 +72,52 @@ func TestCopyFromTo(t *testing.T) {
 
 }
 
+func TestCopyFromToContext(t *testing.T) {
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := replicator.CopyFromToContext(ctx, from, to)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, numberOfVersions(to, 0)())
+	})
+
+	t.Run("should copy latest version", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		data := []byte("data")
+		version := tests.WriteData(t, from, data)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// when
+		err := replicator.CopyFromToContext(ctx, from, to)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, data, tests.ReadData(t, to, store.Time(version.Time)))
+	})
+
+	t.Run("should pass context to stores", func(t *testing.T) {
+		fromHooks, toHooks := &tests.HooksMock{}, &tests.HooksMock{}
+		from := tests.OpenStore(t, store.WithHooks(fromHooks))
+		to := tests.OpenStore(t, store.WithHooks(toHooks))
+		tests.WriteData(t, from, []byte("data"))
+		ctx := tests.WithTraceID(context.Background(), "trace")
+		// when
+		err := replicator.CopyFromToContext(ctx, from, to)
+		// then
+		require.NoError(t, err)
+		readOperations := fromHooks.Ended()[2:] // skip writing
+		assert.Len(t, readOperations, 2)
+		for _, e := range append(readOperations, toHooks.Ended()...) {
+			assert.Equal(t, "trace", e.TraceID, "operation %s", e.Operation)
+		}
+		assert.Equal(t, []store.Operation{store.OpenWriter, store.CloseWriter}, toHooks.Operations())
+	})
+}
+
 func TestStartFromTo(t *testing.T) {
 
 	t.Run("should return error when from is nil", func(t *testing.T) {
@@ -141,6 +187,26 @@ func TestStartFromTo(t *testing.T) {
 	})
 }
 
+func TestThrottle(t *testing.T) {
+
+	t.Run("should throttle copying", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		data := []byte("data")
+		tests.WriteData(t, from, data)
+		limiter := &tests.LimiterMock{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to, replicator.Interval(time.Millisecond), replicator.Throttle(limiter))
+		})
+		// when
+		assert.Eventually(t, numberOfVersions(to, 1), 100*time.Millisecond, time.Millisecond)
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		// then
+		assert.Equal(t, len(data), limiter.Bytes())
+	})
+}
+
 func TestReadLatest(t *testing.T) {
 	t.Run("should return error", func(t *testing.T) {
 		t.Run("when no store is given", func(t *testing.T) {
@@ -287,3 +353,43 @@ func numberOfVersions(s *store.Store, l int) func() bool {
 		return len(versions) == l
 	}
 }
+
+func TestAllNamespaces(t *testing.T) {
+
+	t.Run("should return error when store does not support namespaces", func(t *testing.T) {
+		from := &tests.StoreMock{}
+		// when
+		err := replicator.StartFromTo(context.Background(), from, tests.OpenStore(t), replicator.AllNamespaces)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should copy latest version of store and all its namespaces", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		users, err := from.Namespace("users")
+		require.NoError(t, err)
+		nested, err := users.Namespace("nested")
+		require.NoError(t, err)
+		tests.WriteData(t, from, []byte("root"))
+		tests.WriteData(t, nested, []byte("nested"))
+		collector := &replicator.Collector{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to,
+				replicator.AllNamespaces, replicator.Interval(time.Millisecond), replicator.Collect(collector))
+		})
+		// then
+		toUsers, err := to.Namespace("users")
+		require.NoError(t, err)
+		toNested, err := toUsers.Namespace("nested")
+		require.NoError(t, err)
+		assert.Eventually(t, numberOfVersions(toNested, 1), 100*time.Millisecond, time.Millisecond)
+		assert.Eventually(t, numberOfVersions(to, 1), 100*time.Millisecond, time.Millisecond)
+		assert.True(t, numberOfVersions(toUsers, 0)())
+		assert.Equal(t, []byte("nested"), tests.ReadData(t, toNested))
+		assert.Eventually(t, func() bool { return collector.Metrics().Copied == 2 }, 100*time.Millisecond, time.Millisecond)
+		// cleanup
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+	})
+}
@@ -0,0 +1,28 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopySegmentedVersion(t *testing.T) {
+	from, to := tests.OpenStore(t), tests.OpenStore(t)
+	data := []byte("0123456789")
+	version := tests.WriteData(t, from, data, store.SegmentSize(4))
+	// when
+	err := replicator.CopyFromTo(from, to)
+	// then
+	require.NoError(t, err)
+	segmentSize, err := to.VersionSegmentSize(version.Time)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), segmentSize)
+	assert.Equal(t, data, tests.ReadData(t, to))
+}
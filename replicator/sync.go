@@ -0,0 +1,181 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/deebee/throttle"
+)
+
+// SyncDestination is implemented by *store.Store and remote.Client
+type SyncDestination interface {
+	codec.ReadOnlyStore
+	codec.WriteOnlyStore
+}
+
+type SyncOption func(*SyncOptions) error
+
+type SyncOptions struct {
+	since, until    time.Time
+	mirrorDeletions bool
+}
+
+// Window limits synchronization to versions with time in range [since, until). Zero time means no limit.
+func Window(since, until time.Time) SyncOption {
+	return func(o *SyncOptions) error {
+		if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+			return fmt.Errorf("since %s must be before until %s", since, until)
+		}
+		o.since = since
+		o.until = until
+		return nil
+	}
+}
+
+// MirrorDeletions deletes versions from <to> store which do not exist in <from> store, so both stores have the same
+// history. Only versions within Window are deleted. <to> store must have DeleteVersion(time.Time) error method.
+var MirrorDeletions SyncOption = func(o *SyncOptions) error {
+	o.mirrorDeletions = true
+	return nil
+}
+
+// SyncResult describes changes made in <to> store
+type SyncResult struct {
+	Copied      []store.Version
+	Deleted     []store.Version
+	Skipped     []store.Version // versions corrupted in <from> store
+	BytesCopied int64
+}
+
+func Sync(from codec.ReadOnlyStore, to SyncDestination, options ...SyncOption) (SyncResult, error) {
+	return SyncContext(context.Background(), from, to, options...)
+}
+
+// SyncContext copies all versions of <from> store missing in <to> store, oldest first, preserving their time. Unlike
+// CopyFromTo, versions written between runs are not lost. Corrupted versions are skipped, so one bad version does
+// not stop replication of the others.
+//
+// SyncContext returns changes made so far, even when error is returned.
+func SyncContext(ctx context.Context, from codec.ReadOnlyStore, to SyncDestination, options ...SyncOption) (SyncResult, error) {
+	if from == nil {
+		return SyncResult{}, errors.New("nil <from> store")
+	}
+	if to == nil {
+		return SyncResult{}, errors.New("nil <to> store")
+	}
+	opts, err := applySyncOptions(to, options)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	return syncVersions(ctx, from, to, opts, nil)
+}
+
+func applySyncOptions(to codec.WriteOnlyStore, options []SyncOption) (*SyncOptions, error) {
+	opts := &SyncOptions{}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	return opts, checkSyncDestination(to, opts)
+}
+
+func checkSyncDestination(to codec.WriteOnlyStore, opts *SyncOptions) error {
+	if _, ok := to.(SyncDestination); !ok {
+		return errors.New("<to> store does not support listing versions")
+	}
+	if _, ok := to.(codec.VersionDeleter); opts.mirrorDeletions && !ok {
+		return errors.New("<to> store does not support deleting versions")
+	}
+	return nil
+}
+
+func syncVersions(ctx context.Context, from codec.ReadOnlyStore, to SyncDestination, opts *SyncOptions, limiter throttle.Limiter) (SyncResult, error) {
+	var result SyncResult
+
+	fromVersions, err := codec.ListVersions(ctx, from)
+	if err != nil {
+		return result, fmt.Errorf("error listing versions of <from> store: %w", err)
+	}
+	toVersions, err := codec.ListVersions(ctx, to)
+	if err != nil {
+		return result, fmt.Errorf("error listing versions of <to> store: %w", err)
+	}
+
+	existing := timesOf(toVersions)
+	for _, version := range fromVersions {
+		if !opts.inWindow(version.Time) || existing[version.Time.UnixNano()] {
+			continue
+		}
+		n, err := copyVersionWithTime(ctx, from, to, version.Time, limiter)
+		switch {
+		case err == nil:
+			result.Copied = append(result.Copied, version)
+			result.BytesCopied += n
+		case ctx.Err() != nil:
+			return result, ctx.Err()
+		case store.IsCorrupted(err):
+			log.With("version", version.Time).WithError(err).Warn(ctx, "skipping corrupted version")
+			result.Skipped = append(result.Skipped, version)
+		case store.IsVersionNotFound(err) || store.IsVersionAlreadyExists(err):
+			// version was deleted or copied by someone else in the meantime
+		default:
+			return result, fmt.Errorf("error copying version %s: %w", version.Time, err)
+		}
+	}
+
+	if !opts.mirrorDeletions {
+		return result, nil
+	}
+	deleter := to.(codec.VersionDeleter)
+	sources := timesOf(fromVersions)
+	for _, version := range toVersions {
+		if !opts.inWindow(version.Time) || sources[version.Time.UnixNano()] {
+			continue
+		}
+		err = codec.DeleteVersion(ctx, deleter, version.Time)
+		if err != nil && !store.IsVersionNotFound(err) {
+			return result, fmt.Errorf("error deleting version %s: %w", version.Time, err)
+		}
+		if err == nil {
+			result.Deleted = append(result.Deleted, version)
+		}
+	}
+	return result, nil
+}
+
+func copyVersionWithTime(ctx context.Context, from codec.ReadOnlyStore, to codec.WriteOnlyStore, t time.Time, limiter throttle.Limiter) (int64, error) {
+	reader, err := codec.OpenReader(ctx, from, store.Time(t))
+	if err != nil {
+		return 0, err
+	}
+	return copyOpened(ctx, from, reader, to, limiter)
+}
+
+func (o *SyncOptions) inWindow(t time.Time) bool {
+	if !o.since.IsZero() && t.Before(o.since) {
+		return false
+	}
+	if !o.until.IsZero() && !t.Before(o.until) {
+		return false
+	}
+	return true
+}
+
+func timesOf(versions []store.Version) map[int64]bool {
+	times := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		times[version.Time.UnixNano()] = true
+	}
+	return times
+}
@@ -0,0 +1,184 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+
+	t.Run("should return error when from is nil", func(t *testing.T) {
+		_, err := replicator.Sync(nil, tests.OpenStore(t))
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when to is nil", func(t *testing.T) {
+		_, err := replicator.Sync(tests.OpenStore(t), nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for invalid window", func(t *testing.T) {
+		now := time.Now()
+		_, err := replicator.Sync(tests.OpenStore(t), tests.OpenStore(t), replicator.Window(now, now.Add(-time.Second)))
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when destination does not support deleting versions", func(t *testing.T) {
+		_, err := replicator.Sync(tests.OpenStore(t), &tests.StoreMock{}, replicator.MirrorDeletions)
+		assert.Error(t, err)
+	})
+
+	t.Run("should copy all missing versions", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		v1 := tests.WriteData(t, from, []byte("v1"))
+		v2 := tests.WriteData(t, from, []byte("v2"))
+		tests.WriteData(t, to, []byte("v2"), store.WriteTime(v2.Time))
+		v3 := tests.WriteData(t, from, []byte("v3"))
+		// when
+		result, err := replicator.Sync(from, to)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result.Copied, 2)
+		assert.True(t, v1.Time.Equal(result.Copied[0].Time))
+		assert.True(t, v3.Time.Equal(result.Copied[1].Time))
+		assert.Equal(t, int64(4), result.BytesCopied)
+		assert.Equal(t, []byte("v1"), tests.ReadData(t, to, store.Time(v1.Time)))
+		assert.Equal(t, []byte("v3"), tests.ReadData(t, to, store.Time(v3.Time)))
+	})
+
+	t.Run("should not copy anything when stores are in sync", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("data"))
+		_, err := replicator.Sync(from, to)
+		require.NoError(t, err)
+		// when
+		result, err := replicator.Sync(from, to)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, result.Copied)
+	})
+
+	t.Run("should skip corrupted versions", func(t *testing.T) {
+		fromDir := tests.TempDir(t)
+		from, err := store.Open(fromDir)
+		require.NoError(t, err)
+		to := tests.OpenStore(t)
+		corrupted := tests.WriteData(t, from, []byte("corrupted"))
+		tests.CorruptDataFiles(t, fromDir)
+		intact := tests.WriteData(t, from, []byte("intact"))
+		// when
+		result, err := replicator.Sync(from, to)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result.Skipped, 1)
+		assert.True(t, corrupted.Time.Equal(result.Skipped[0].Time))
+		require.Len(t, result.Copied, 1)
+		assert.True(t, intact.Time.Equal(result.Copied[0].Time))
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should copy only versions within window", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		tests.WriteData(t, from, []byte("before"), store.WriteTime(base))
+		inside := tests.WriteData(t, from, []byte("inside"), store.WriteTime(base.Add(time.Hour)))
+		tests.WriteData(t, from, []byte("after"), store.WriteTime(base.Add(2*time.Hour)))
+		// when
+		result, err := replicator.Sync(from, to, replicator.Window(base.Add(time.Minute), base.Add(2*time.Hour)))
+		// then
+		require.NoError(t, err)
+		require.Len(t, result.Copied, 1)
+		assert.True(t, inside.Time.Equal(result.Copied[0].Time))
+	})
+
+	t.Run("should not delete versions by default", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, to, []byte("only in destination"))
+		// when
+		result, err := replicator.Sync(from, to)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, result.Deleted)
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should mirror deletions within window", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		outside := tests.WriteData(t, to, []byte("outside"), store.WriteTime(base))
+		deleted := tests.WriteData(t, to, []byte("deleted"), store.WriteTime(base.Add(time.Hour)))
+		kept := tests.WriteData(t, from, []byte("kept"), store.WriteTime(base.Add(2*time.Hour)))
+		tests.WriteData(t, to, []byte("kept"), store.WriteTime(kept.Time))
+		// when
+		result, err := replicator.Sync(from, to, replicator.MirrorDeletions, replicator.Window(base.Add(time.Minute), time.Time{}))
+		// then
+		require.NoError(t, err)
+		require.Len(t, result.Deleted, 1)
+		assert.True(t, deleted.Time.Equal(result.Deleted[0].Time))
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.True(t, outside.Time.Equal(versions[0].Time))
+		assert.True(t, kept.Time.Equal(versions[1].Time))
+	})
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		result, err := replicator.SyncContext(ctx, from, to)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, result.Copied)
+	})
+}
+
+func TestSyncHistory(t *testing.T) {
+
+	t.Run("should return error when destination does not support listing versions", func(t *testing.T) {
+		err := replicator.StartFromTo(context.Background(), tests.OpenStore(t), writeOnlyStore{tests.OpenStore(t)},
+			replicator.SyncHistory())
+		assert.Error(t, err)
+	})
+
+	t.Run("should continuously copy all versions in the background", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("v1"))
+		tests.WriteData(t, from, []byte("v2"))
+		collector := &replicator.Collector{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to,
+				replicator.SyncHistory(), replicator.Interval(time.Millisecond), replicator.Collect(collector))
+		})
+		// then
+		assert.Eventually(t, numberOfVersions(to, 2), 100*time.Millisecond, time.Millisecond)
+		tests.WriteData(t, from, []byte("v3"))
+		assert.Eventually(t, numberOfVersions(to, 3), 100*time.Millisecond, time.Millisecond)
+		assert.Eventually(t, func() bool { return collector.Metrics().Copied == 3 }, 100*time.Millisecond, time.Millisecond)
+		// cleanup
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+	})
+}
+
+type writeOnlyStore struct {
+	codec.WriteOnlyStore
+}
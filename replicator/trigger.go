@@ -0,0 +1,74 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elgopher/deebee/codec"
+	"github.com/elgopher/deebee/store"
+)
+
+// watchingStore is implemented by *store.Store
+type watchingStore interface {
+	Watch(context.Context, ...store.WatchOption) (<-chan store.Event, error)
+}
+
+// OnWrite makes StartFromTo and StartFanOut replicate as soon as a new version is created in <from> store, instead of
+// waiting for the next interval. Replication starts debounce after the version was created, so versions created in
+// quick succession are replicated in one run. Interval is still used as a safety net, for example when the store
+// could not be scanned. With AllNamespaces, only versions of <from> store itself trigger replication. <from> store
+// must have Watch method (see store.Store.Watch).
+func OnWrite(debounce time.Duration) Option {
+	return func(o *Options) error {
+		if debounce < 0 {
+			return fmt.Errorf("debounce must not be negative, got %s", debounce)
+		}
+		o.onWrite = true
+		o.debounce = debounce
+		return nil
+	}
+}
+
+// runLoop calls run after each interval, and when OnWrite was used, after a new version was created. It returns
+// once ctx is done.
+func runLoop(ctx context.Context, from codec.ReadOnlyStore, opts *Options, run func()) error {
+	var events <-chan store.Event
+	if opts.onWrite {
+		w, ok := from.(watchingStore)
+		if !ok {
+			return errors.New("<from> store does not support watching")
+		}
+		var err error
+		if events, err = w.Watch(ctx); err != nil {
+			return fmt.Errorf("error watching <from> store: %w", err)
+		}
+	}
+
+	var (
+		interval = time.After(opts.interval)
+		debounce <-chan time.Time // nil when no version was created since the last run
+	)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil // ctx is done
+			} else if event.Type == store.VersionCreated && debounce == nil {
+				debounce = time.After(opts.debounce)
+			}
+			continue
+		case <-debounce:
+		case <-interval:
+		case <-ctx.Done():
+			return nil
+		}
+		run()
+		interval = time.After(opts.interval)
+		debounce = nil
+	}
+}
@@ -0,0 +1,91 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package replicator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/replicator"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnWrite(t *testing.T) {
+
+	t.Run("should return error for negative debounce", func(t *testing.T) {
+		err := replicator.StartFromTo(context.Background(), tests.OpenStore(t), tests.OpenStore(t), replicator.OnWrite(-1))
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when store does not support watching", func(t *testing.T) {
+		err := replicator.StartFromTo(context.Background(), &tests.StoreMock{}, tests.OpenStore(t), replicator.OnWrite(0))
+		assert.Error(t, err)
+	})
+
+	t.Run("should replicate new version without waiting for interval", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to, replicator.Interval(time.Hour), replicator.OnWrite(0))
+		})
+		// then
+		assert.Eventually(t, writeUntilReplicated(t, from, to), time.Second, 10*time.Millisecond)
+		// cleanup
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+	})
+
+	t.Run("should replicate versions created within debounce in one run", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		collector := &replicator.Collector{}
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFromTo(ctx, from, to, replicator.Interval(time.Hour), replicator.OnWrite(50*time.Millisecond),
+				replicator.SyncHistory(), replicator.Collect(collector))
+		})
+		assert.Eventually(t, writeUntilReplicated(t, from, to), time.Second, 100*time.Millisecond)
+		runs := collector.Metrics().Runs
+		// when
+		tests.WriteData(t, from, []byte("first"))
+		tests.WriteData(t, from, []byte("second"))
+		// then
+		assert.Eventually(t, func() bool { return collector.Metrics().Runs == runs+1 }, time.Second, time.Millisecond)
+		versions, err := from.Versions()
+		assert.NoError(t, err)
+		assert.True(t, numberOfVersions(to, len(versions))())
+		// cleanup
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+		assert.Equal(t, runs+1, collector.Metrics().Runs)
+	})
+
+	t.Run("should replicate new version to all destinations of fan-out", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		async := tests.RunAsync(func() {
+			_ = replicator.StartFanOut(ctx, from, []replicator.Destination{{Name: "to", Store: to}},
+				replicator.Interval(time.Hour), replicator.OnWrite(0))
+		})
+		// then
+		assert.Eventually(t, writeUntilReplicated(t, from, to), time.Second, 10*time.Millisecond)
+		// cleanup
+		cancel()
+		async.WaitOrFailAfter(t, time.Second)
+	})
+}
+
+// writeUntilReplicated writes a new version each time it is called, until <to> store has any version. Versions
+// written before the replicator started watching <from> store are not replicated immediately.
+func writeUntilReplicated(t *testing.T, from, to *store.Store) func() bool {
+	return func() bool {
+		if !numberOfVersions(to, 0)() {
+			return true
+		}
+		tests.WriteData(t, from, []byte("data"))
+		return false
+	}
+}
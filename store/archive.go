@@ -0,0 +1,436 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	archiveFormat       = "deebee/archive"
+	archiveMetadataFile = "deebee.json"
+)
+
+// archiveMetadata is the first file of archive
+type archiveMetadata struct {
+	Format   string            `json:"format"`
+	Exported time.Time         `json:"exported"`
+	Versions []archivedVersion `json:"versions"`
+}
+
+type archivedVersion struct {
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// Export writes a tar archive with all files of versions accepted by filter, including checksums and files of
+// entries and segments. When filter is nil, all versions are exported. Integrity of each file is verified while
+// writing, so corrupted version is never exported. Exported versions are returned.
+func (s *Store) Export(w io.Writer, filter func(Version) bool) ([]Version, error) {
+	return s.ExportContext(context.Background(), w, filter)
+}
+
+// ExportContext is like Export but aborts exporting once ctx is done. Archive written so far is incomplete then.
+func (s *Store) ExportContext(ctx context.Context, w io.Writer, filter func(Version) bool) ([]Version, error) {
+	versions, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+	var exported []Version
+	for _, version := range versions {
+		if filter == nil || filter(version) {
+			exported = append(exported, version)
+		}
+	}
+
+	metadata := archiveMetadata{Format: archiveFormat, Exported: time.Now(), Versions: []archivedVersion{}}
+	for _, version := range exported {
+		metadata.Versions = append(metadata.Versions, archivedVersion{Time: version.Time, Size: version.Size})
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding archive metadata: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	header := &tar.Header{Name: archiveMetadataFile, Mode: 0664, Size: int64(len(metadataJSON)), ModTime: metadata.Exported}
+	if err = tw.WriteHeader(header); err != nil {
+		return nil, fmt.Errorf("error writing archive metadata: %w", err)
+	}
+	if _, err = tw.Write(metadataJSON); err != nil {
+		return nil, fmt.Errorf("error writing archive metadata: %w", err)
+	}
+	for _, version := range exported {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = s.exportVersion(ctx, tw, version); err != nil {
+			return nil, fmt.Errorf("error exporting version %s: %w", version.Time, err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return nil, fmt.Errorf("error closing archive: %w", err)
+	}
+	return exported, nil
+}
+
+// exportVersion writes files of entries or segments and manifest marker first, then data file and its checksum
+func (s *Store) exportVersion(ctx context.Context, tw *tar.Writer, version Version) error {
+	dataFile := s.dataFilename(version.Time)
+	checksumFile := checksumFileForDataFile(dataFile)
+	checksum, err := ioutil.ReadFile(checksumFile)
+	if os.IsNotExist(err) {
+		return IncompleteError{Version: version, File: checksumFile}
+	}
+	if err != nil {
+		return fmt.Errorf("error reading checksum file %s: %w", checksumFile, err)
+	}
+	parts, err := s.versionParts(ctx, version)
+	if err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if err = s.exportFile(ctx, tw, version, p.file, p.checksum); err != nil {
+			return err
+		}
+	}
+	marker := manifestFilename(dataFile)
+	if _, err = os.Stat(marker); err == nil {
+		if err = tw.WriteHeader(&tar.Header{Name: path.Base(marker), Mode: 0664, ModTime: version.Time}); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error checking manifest marker %s: %w", marker, err)
+	}
+	if err = s.exportFile(ctx, tw, version, dataFile, checksum); err != nil {
+		return err
+	}
+	header := &tar.Header{Name: path.Base(checksumFile), Mode: 0664, Size: int64(len(checksum)), ModTime: version.Time}
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(checksum)
+	return err
+}
+
+// exportFile copies file to archive verifying its checksum
+func (s *Store) exportFile(ctx context.Context, tw *tar.Writer, version Version, file string, checksum []byte) error {
+	r, err := s.newReader(ctx, file, version, 0, time.Now())
+	if IsVersionNotFound(err) {
+		return IncompleteError{Version: version, File: file}
+	}
+	if err != nil {
+		return err
+	}
+	r.expectedChecksum = checksum
+	r.part = true
+
+	stat, err := r.file.Stat()
+	if err != nil {
+		_ = r.close()
+		return fmt.Errorf("error getting stat of file %s: %w", file, err)
+	}
+	header := &tar.Header{Name: path.Base(file), Mode: 0664, Size: stat.Size(), ModTime: stat.ModTime()}
+	if err = tw.WriteHeader(header); err != nil {
+		_ = r.close()
+		return err
+	}
+	if _, err = io.Copy(tw, r); err != nil {
+		_ = r.close()
+		return err
+	}
+	return r.close()
+}
+
+type versionPart struct {
+	file     string
+	checksum []byte
+}
+
+// versionParts returns files of entries or segments of a version, together with their checksums
+func (s *Store) versionParts(ctx context.Context, version Version) ([]versionPart, error) {
+	dataFile := s.dataFilename(version.Time)
+	filename := segmentFilename
+	m, err := s.readManifest(ctx, version, segmentsFormat)
+	if IsVersionNotFound(err) {
+		filename = entryFilename
+		m, err = s.readManifest(ctx, version, manifestFormat)
+		if IsVersionNotFound(err) {
+			return nil, nil // version has no parts
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]versionPart, len(m.Entries))
+	for i, entry := range m.Entries {
+		parts[i] = versionPart{file: filename(dataFile, entry.Name), checksum: entry.Checksum}
+	}
+	return parts, nil
+}
+
+type ImportOption func(*ImportOptions) error
+
+type ImportOptions struct {
+	skipExisting bool
+}
+
+// SkipExisting skips versions which already exist in the store. By default, Import returns VersionAlreadyExists error
+// and nothing is imported.
+var SkipExisting ImportOption = func(o *ImportOptions) error {
+	o.skipExisting = true
+	return nil
+}
+
+// Import reads a tar archive written by Export and adds its versions to the store. All files are first written to
+// a temporary directory inside the store directory, and each version is fully verified before it becomes visible.
+// When archive is corrupted, nothing is imported. Imported versions are returned.
+//
+// MaxVersionSize and MaxStoreSize apply to imported versions and are checked before each file is written to the
+// temporary directory. When any limit is exceeded, VersionTooLarge or StoreFull error is returned and nothing is
+// imported.
+func (s *Store) Import(r io.Reader, options ...ImportOption) ([]Version, error) {
+	return s.ImportContext(context.Background(), r, options...)
+}
+
+// ImportContext is like Import but aborts importing once ctx is done. Versions made visible so far are not removed.
+func (s *Store) ImportContext(ctx context.Context, r io.Reader, options ...ImportOption) ([]Version, error) {
+	opts := &ImportOptions{}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	if err := s.ensureFreeSpace(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+	limits, err := s.writeLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	stagingDir, err := ioutil.TempDir(s.dir, ".import-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(stagingDir)
+	}()
+
+	unpacker := &archiveUnpacker{
+		dir:      stagingDir,
+		storeDir: s.dir,
+		limits:   limits,
+		skip: func(t time.Time) bool {
+			return opts.skipExisting && containsVersion(existing, t)
+		},
+	}
+	metadata, err := unpacker.unpack(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var imported []Version
+	for _, v := range metadata.Versions {
+		if containsVersion(existing, v.Time) {
+			if opts.skipExisting {
+				continue
+			}
+			return nil, versionAlreadyExistsError{msg: fmt.Sprintf("version %s already exists", v.Time)}
+		}
+		imported = append(imported, Version{Time: v.Time, Size: v.Size})
+	}
+
+	staging := &Store{
+		dir:               stagingDir,
+		hooks:             noHooks{},
+		areChecksumsEqual: s.areChecksumsEqual,
+	}
+	for _, v := range imported {
+		if err = staging.VerifyContext(ctx, v.Time); err != nil {
+			return nil, fmt.Errorf("error verifying version %s: %w", v.Time, err)
+		}
+	}
+
+	for i, version := range imported {
+		if err = ctx.Err(); err != nil {
+			return imported[:i], err
+		}
+		if err = s.moveVersion(staging, version.Time); err != nil {
+			return imported[:i], fmt.Errorf("error importing version %s: %w", version.Time, err)
+		}
+		s.notifyWatchers()
+	}
+	return imported, nil
+}
+
+// archiveUnpacker writes files of archived versions to dir. Size of each file is checked against limits before the
+// file is created, so an oversized archive is rejected before it fills the disk. Like in Writer, size of the version
+// includes files of entries and segments.
+type archiveUnpacker struct {
+	dir      string
+	storeDir string
+	limits   writeLimits
+	skip     func(time.Time) bool // returns true for versions which files should not be written
+	sizes    map[string]int64     // unpacked bytes of each version by data file name
+	total    int64                // unpacked bytes of all versions
+}
+
+// unpack writes files to dir and returns archive metadata. Only files of versions listed in metadata are accepted.
+func (u *archiveUnpacker) unpack(ctx context.Context, r io.Reader) (archiveMetadata, error) {
+	u.sizes = map[string]int64{}
+	var metadata *archiveMetadata
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return archiveMetadata{}, err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return archiveMetadata{}, fmt.Errorf("error reading archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return archiveMetadata{}, fmt.Errorf("unexpected archive entry %s", header.Name)
+		}
+
+		if header.Name == archiveMetadataFile {
+			if metadata != nil {
+				return archiveMetadata{}, errors.New("duplicated archive metadata")
+			}
+			metadata = &archiveMetadata{}
+			if err = json.NewDecoder(tr).Decode(metadata); err != nil {
+				return archiveMetadata{}, fmt.Errorf("error decoding archive metadata: %w", err)
+			}
+			if metadata.Format != archiveFormat {
+				return archiveMetadata{}, fmt.Errorf("unsupported archive format %q", metadata.Format)
+			}
+			continue
+		}
+		if metadata == nil {
+			return archiveMetadata{}, errors.New("archive metadata must be the first file")
+		}
+		version, ok := archivedVersionOf(header.Name, metadata.Versions)
+		if !ok {
+			return archiveMetadata{}, fmt.Errorf("unexpected archive entry %s", header.Name)
+		}
+		if u.skip(version.Time) {
+			continue
+		}
+		if err = u.reserve(version.Time, header); err != nil {
+			return archiveMetadata{}, err
+		}
+		if err = writeArchivedFile(tr, path.Join(u.dir, header.Name)); err != nil {
+			return archiveMetadata{}, err
+		}
+	}
+	if metadata == nil {
+		return archiveMetadata{}, errors.New("archive metadata not found")
+	}
+	return *metadata, nil
+}
+
+// reserve returns error when file described by header does not fit within limits. Checksum file is not part of the
+// version size, but still counts towards the store size.
+func (u *archiveUnpacker) reserve(t time.Time, header *tar.Header) error {
+	dataFile := dataFileBase(t)
+	size := u.sizes[dataFile]
+	if header.Name != checksumFileForDataFile(dataFile) {
+		size += header.Size
+	}
+	if u.limits.version > 0 && size > u.limits.version {
+		return VersionTooLargeError{Version: Version{Time: t, Size: size}, Limit: u.limits.version}
+	}
+	if u.limits.store > 0 && u.total+header.Size > u.limits.storeAvailable {
+		return StoreFullError{Dir: u.storeDir, Limit: u.limits.store}
+	}
+	u.sizes[dataFile] = size
+	u.total += header.Size
+	return nil
+}
+
+// archivedVersionOf returns the version which data file, checksum or file of entry or segment has given name
+func archivedVersionOf(name string, versions []archivedVersion) (archivedVersion, bool) {
+	if path.Base(name) != name || strings.HasPrefix(name, ".") {
+		return archivedVersion{}, false
+	}
+	for _, v := range versions {
+		dataFile := dataFileBase(v.Time)
+		if name == dataFile || name == checksumFileForDataFile(dataFile) || isPartFileOf(dataFile, name) {
+			return v, true
+		}
+	}
+	return archivedVersion{}, false
+}
+
+func writeArchivedFile(r io.Reader, name string) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0664)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %w", name, err)
+	}
+	if _, err = io.Copy(file, r); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error writing file %s: %w", name, err)
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error syncing file %s: %w", name, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("error closing file %s: %w", name, err)
+	}
+	return nil
+}
+
+func containsVersion(versions []Version, t time.Time) bool {
+	for _, v := range versions {
+		if v.Time.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// moveVersion renames files of a version from staging store. Checksum file is renamed last, so the version becomes
+// visible only when all other files are in place.
+func (s *Store) moveVersion(staging *Store, t time.Time) error {
+	stagedDataFile := staging.dataFilename(t)
+	files, err := ioutil.ReadDir(staging.dir)
+	if err != nil {
+		return fmt.Errorf("reading dir %s failed: %w", staging.dir, err)
+	}
+	var names []string
+	for _, file := range files {
+		if isPartFileOf(stagedDataFile, file.Name()) {
+			names = append(names, file.Name())
+		}
+	}
+	names = append(names, path.Base(stagedDataFile), path.Base(checksumFileForDataFile(stagedDataFile)))
+	for _, name := range names {
+		if err = os.Rename(path.Join(staging.dir, name), path.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Export(t *testing.T) {
+
+	t.Run("should export only versions accepted by filter", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("v1"))
+		v2 := tests.WriteData(t, s, []byte("v2"))
+		var archive bytes.Buffer
+		// when
+		exported, err := s.Export(&archive, func(v store.Version) bool { return v.Time.Equal(v2.Time) })
+		// then
+		require.NoError(t, err)
+		require.Len(t, exported, 1)
+		assert.True(t, v2.Time.Equal(exported[0].Time))
+		assert.Len(t, archiveFiles(t, archive.Bytes()), 3) // metadata, data and checksum
+	})
+
+	t.Run("should return error when version is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		// when
+		_, err = s.Export(&bytes.Buffer{}, nil)
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+	})
+}
+
+func TestStore_Import(t *testing.T) {
+
+	t.Run("should import all kinds of versions exported from another store", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		regular := tests.WriteData(t, from, []byte("regular"))
+		segmented := tests.WriteData(t, from, []byte("0123456789"), store.SegmentSize(4))
+		withEntries := writeEntries(t, from, map[string]string{"users": "alice", "orders": "1,2,3"})
+		archive := export(t, from)
+		// when
+		imported, err := to.Import(archive)
+		// then
+		require.NoError(t, err)
+		assert.Len(t, imported, 3)
+		assert.Equal(t, []byte("regular"), tests.ReadData(t, to, store.Time(regular.Time)))
+		assert.Equal(t, []byte("0123456789"), tests.ReadData(t, to, store.Time(segmented.Time)))
+		assert.Equal(t, "alice", readEntry(t, to, "users"))
+		entries, err := to.Entries(withEntries.Time)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Empty(t, hiddenFiles(t, to))
+	})
+
+	t.Run("should return VersionAlreadyExists error and import nothing when version exists", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		v1 := tests.WriteData(t, from, []byte("v1"))
+		tests.WriteData(t, from, []byte("v2"))
+		tests.WriteData(t, to, []byte("existing"), store.WriteTime(v1.Time))
+		// when
+		_, err := to.Import(export(t, from))
+		// then
+		assert.True(t, store.IsVersionAlreadyExists(err))
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should skip existing versions", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		v1 := tests.WriteData(t, from, []byte("v1"))
+		v2 := tests.WriteData(t, from, []byte("v2"))
+		tests.WriteData(t, to, []byte("existing"), store.WriteTime(v1.Time))
+		// when
+		imported, err := to.Import(export(t, from), store.SkipExisting)
+		// then
+		require.NoError(t, err)
+		require.Len(t, imported, 1)
+		assert.True(t, v2.Time.Equal(imported[0].Time))
+		assert.Equal(t, []byte("existing"), tests.ReadData(t, to, store.Time(v1.Time)))
+	})
+
+	t.Run("should import nothing when archive is corrupted", func(t *testing.T) {
+		from, to := tests.OpenStore(t), tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("first"))
+		tests.WriteData(t, from, []byte("second"))
+		archive := export(t, from).Bytes()
+		corrupted := bytes.Replace(archive, []byte("second"), []byte("SECOND"), 1)
+		// when
+		_, err := to.Import(bytes.NewReader(corrupted))
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		assert.Empty(t, hiddenFiles(t, to))
+	})
+
+	t.Run("should return VersionTooLarge error and import nothing when version exceeds the limit", func(t *testing.T) {
+		from := tests.OpenStore(t)
+		tests.WriteData(t, from, []byte("small"))
+		tests.WriteData(t, from, []byte("0123456789"), store.SegmentSize(4))
+		to := tests.OpenStore(t, store.MaxVersionSize(10))
+		// when
+		_, err := to.Import(export(t, from))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		assert.Empty(t, hiddenFiles(t, to))
+	})
+
+	t.Run("should return StoreFull error and import nothing when versions exceed the store limit", func(t *testing.T) {
+		from := tests.OpenStore(t)
+		tests.WriteData(t, from, make([]byte, 40))
+		tests.WriteData(t, from, make([]byte, 40))
+		to := tests.OpenStore(t, store.MaxStoreSize(100))
+		tests.WriteData(t, to, make([]byte, 20))
+		// when
+		_, err := to.Import(export(t, from))
+		// then
+		assert.True(t, store.IsStoreFull(err))
+		versions, err := to.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Empty(t, hiddenFiles(t, to))
+	})
+
+	t.Run("should reject oversized file before writing it", func(t *testing.T) {
+		limits := map[string]struct {
+			option       store.Option
+			isLimitError func(error) bool
+		}{
+			"version": {option: store.MaxVersionSize(1024), isLimitError: store.IsVersionTooLarge},
+			"store":   {option: store.MaxStoreSize(1024), isLimitError: store.IsStoreFull},
+		}
+		for name, limit := range limits {
+			limit := limit
+			t.Run(name, func(t *testing.T) {
+				from := tests.OpenStore(t)
+				tests.WriteData(t, from, []byte("data"))
+				to := tests.OpenStore(t, limit.option)
+				// archive ends right after the header, so it could be imported only by writing the file first
+				archive := truncatedArchive(t, export(t, from).Bytes(), 1<<30)
+				// when
+				_, err := to.Import(bytes.NewReader(archive))
+				// then
+				assert.True(t, limit.isLimitError(err), "unexpected error: %v", err)
+				assert.Empty(t, hiddenFiles(t, to))
+			})
+		}
+	})
+
+	t.Run("should import versions within limits", func(t *testing.T) {
+		from := tests.OpenStore(t)
+		tests.WriteData(t, from, make([]byte, 40))
+		to := tests.OpenStore(t, store.MaxVersionSize(40), store.MaxStoreSize(100))
+		// when
+		imported, err := to.Import(export(t, from))
+		// then
+		require.NoError(t, err)
+		assert.Len(t, imported, 1)
+	})
+
+	t.Run("should return error when archive has unexpected file", func(t *testing.T) {
+		to := tests.OpenStore(t)
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		metadata := []byte(`{"format":"deebee/archive","versions":[]}`)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "deebee.json", Mode: 0664, Size: int64(len(metadata))}))
+		_, err := tw.Write(metadata)
+		require.NoError(t, err)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0664}))
+		require.NoError(t, tw.Close())
+		// when
+		_, err = to.Import(&archive)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when archive has no metadata", func(t *testing.T) {
+		to := tests.OpenStore(t)
+		var archive bytes.Buffer
+		require.NoError(t, tar.NewWriter(&archive).Close())
+		// when
+		_, err := to.Import(&archive)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func export(t *testing.T, s *store.Store) *bytes.Buffer {
+	t.Helper()
+	var archive bytes.Buffer
+	_, err := s.Export(&archive, nil)
+	require.NoError(t, err)
+	return &archive
+}
+
+// truncatedArchive returns archive with metadata followed only by the header of the next file, which claims given size
+func truncatedArchive(t *testing.T, archive []byte, size int64) []byte {
+	t.Helper()
+	var truncated bytes.Buffer
+	tr := tar.NewReader(bytes.NewReader(archive))
+	tw := tar.NewWriter(&truncated)
+	metadata, err := tr.Next()
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(metadata))
+	_, err = io.Copy(tw, tr)
+	require.NoError(t, err)
+	header, err := tr.Next()
+	require.NoError(t, err)
+	header.Size = size
+	require.NoError(t, tw.WriteHeader(header))
+	return truncated.Bytes()
+}
+
+func archiveFiles(t *testing.T, archive []byte) []string {
+	t.Helper()
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	return names
+}
+
+// hiddenFiles returns files with names starting with '.', such as temporary directories
+func hiddenFiles(t *testing.T, s *store.Store) []string {
+	t.Helper()
+	info, err := s.Info()
+	require.NoError(t, err)
+	files, err := ioutil.ReadDir(info.Dir)
+	require.NoError(t, err)
+	var hidden []string
+	for _, file := range files {
+		if file.Name()[0] == '.' {
+			hidden = append(hidden, file.Name())
+		}
+	}
+	return hidden
+}
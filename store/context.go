@@ -0,0 +1,38 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import "context"
+
+// runContext runs f and returns ctx.Err() as soon as ctx is done, even if f is still blocked in a file system call
+// (for example on a hung NFS mount). In such case f continues running in a separate goroutine and cleanup is executed
+// once f finishes. Cleanup can be nil.
+func runContext(ctx context.Context, f func(), cleanup func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		f()
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if cleanup != nil {
+			go func() {
+				<-done
+				cleanup()
+			}()
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,458 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// Entry is a named part of a multi-entry version
+type Entry struct {
+	Name string
+	Size int64
+}
+
+// EntriesWriter writes a version consisting of multiple named entries, which can be read individually using
+// Store.EntryReader. Entries are written one after another. All entries become visible at once when Close is called.
+type EntriesWriter interface {
+	// Entry finishes writing the previous entry and returns writer of a new one. Name must be unique within the
+	// version. It may contain letters, digits, '_', '-' and '.', but must not start with '.'.
+	Entry(name string) (io.Writer, error)
+	// Close must be called to make version readable
+	Close() error
+	Version() Version
+	// AbortAndClose aborts writing version. None of entries will be available to read.
+	AbortAndClose()
+}
+
+func (s *Store) EntriesWriter(options ...WriterOption) (EntriesWriter, error) {
+	return s.EntriesWriterContext(context.Background(), options...)
+}
+
+// EntriesWriterContext opens EntriesWriter which aborts writing once ctx is done. Replace option is not supported.
+//
+// Data file of the version contains a manifest with names, sizes and checksums of all entries, and the version is
+// marked by an empty manifest file. Store.Reader reads the manifest. Version.Size is the total size of all entries
+// and limits such as MaxVersionSize apply to all entries together.
+func (s *Store) EntriesWriterContext(ctx context.Context, options ...WriterOption) (EntriesWriter, error) {
+	opts := &WriterOptions{}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	if opts.replace {
+		return nil, errors.New("replacing multi-entry version is not supported")
+	}
+	if opts.segmentSize > 0 {
+		return nil, errors.New("multi-entry version cannot be segmented")
+	}
+
+	w, err := s.WriterContext(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	w.(*writer).manifest = true
+	return &entriesWriter{
+		writer:   w.(*writer),
+		manifest: manifest{Format: manifestFormat, Entries: []manifestEntry{}},
+		filename: entryFilename,
+	}, nil
+}
+
+// entriesWriter writes files of entries and then manifest to data file. It is also used for writing segments.
+type entriesWriter struct {
+	writer   *writer // writes manifest to data file
+	manifest manifest
+	current  *entryWriter
+	filename func(dataFile, name string) string
+	syncs    *backgroundSyncs // nil when files are synced before writing the next entry
+}
+
+func (e *entriesWriter) Entry(name string) (io.Writer, error) {
+	if e.writer.aborted {
+		return nil, errors.New("version was aborted")
+	}
+	if !nameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid entry name %q", name)
+	}
+	if _, ok := e.manifest.entry(name); ok || (e.current != nil && e.current.name == name) {
+		return nil, fmt.Errorf("entry %s already written", name)
+	}
+	if err := e.finishEntry(); err != nil {
+		return nil, err
+	}
+	if err := e.writer.ctx.Err(); err != nil {
+		e.abort()
+		return nil, err
+	}
+
+	filename := e.filename(e.writer.dataFile, name)
+	// data file was created exclusively, so any existing entry file is a leftover of interrupted write
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0664)
+	if err != nil {
+		e.abort()
+		return nil, fmt.Errorf("error opening the file %s for writing: %w", filename, err)
+	}
+	e.writer.partFiles = append(e.writer.partFiles, filename)
+	e.current = &entryWriter{
+		parent:   e,
+		name:     name,
+		file:     file,
+		checksum: newHash(),
+	}
+	return e.current, nil
+}
+
+// finishEntry syncs and closes the file of current entry, and adds the entry to manifest. Version is aborted on error.
+// When syncing in background, error of previously finished entries is returned.
+func (e *entriesWriter) finishEntry() error {
+	current := e.current
+	e.current = nil
+	if current != nil {
+		e.manifest.Entries = append(e.manifest.Entries, manifestEntry{
+			Name:     current.name,
+			Size:     current.size,
+			Checksum: current.checksum.Sum([]byte{}),
+		})
+	}
+
+	if e.syncs != nil {
+		if current != nil {
+			e.syncs.start(current.file, e.writer.sync)
+		}
+		if err := e.syncs.failed(); err != nil {
+			e.abort()
+			return err
+		}
+		return nil
+	}
+	if current == nil {
+		return nil
+	}
+	if err := e.writer.sync(current.file); err != nil {
+		_ = current.file.Close()
+		e.abort()
+		return fmt.Errorf("error syncing file: %w", err)
+	}
+	if err := current.file.Close(); err != nil {
+		e.abort()
+		return fmt.Errorf("error closing file: %w", err)
+	}
+	return nil
+}
+
+func (e *entriesWriter) Close() error {
+	if e.writer.aborted {
+		return errors.New("version was aborted")
+	}
+	if err := e.finishEntry(); err != nil {
+		return err
+	}
+	if e.syncs != nil {
+		if err := e.syncs.wait(); err != nil {
+			e.abort()
+			return err
+		}
+	}
+	data, err := json.Marshal(e.manifest)
+	if err != nil {
+		e.abort()
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	if _, err = e.writer.Write(data); err != nil {
+		e.abort()
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return e.writer.Close()
+}
+
+func (e *entriesWriter) Version() Version {
+	return e.writer.Version()
+}
+
+func (e *entriesWriter) AbortAndClose() {
+	e.closeCurrent()
+	if e.syncs != nil {
+		_ = e.syncs.wait() // files must be closed before removing
+	}
+	e.writer.AbortAndClose()
+}
+
+func (e *entriesWriter) abort() {
+	e.closeCurrent()
+	if e.syncs != nil {
+		_ = e.syncs.wait() // files must be closed before removing
+	}
+	e.writer.abort()
+}
+
+func (e *entriesWriter) closeCurrent() {
+	if e.current != nil {
+		_ = e.current.file.Close()
+		e.current = nil
+	}
+}
+
+type entryWriter struct {
+	parent   *entriesWriter
+	name     string
+	file     *os.File
+	size     int64
+	checksum hash.Hash
+}
+
+func (e *entryWriter) Write(p []byte) (int, error) {
+	if e.parent.writer.aborted {
+		return 0, errors.New("version was aborted")
+	}
+	if e.parent.current != e {
+		return 0, fmt.Errorf("writing entry %s is finished", e.name)
+	}
+	w := e.parent.writer
+	if w.limiter != nil {
+		if err := w.limiter.WaitN(w.ctx, len(p)); err != nil {
+			e.parent.abort()
+			return 0, err
+		}
+	}
+
+	defer w.flushMetrics(time.Now())
+
+	if err := w.checkLimits(len(p)); err != nil {
+		e.parent.abort()
+		return 0, err
+	}
+
+	var n int
+	err := w.retryOnNoSpace(func() error {
+		written, writeErr := w.write(e.file, p[n:])
+		n += written
+		return writeErr
+	})
+	if err != nil && w.ctx.Err() != nil {
+		e.parent.abort()
+	}
+	e.size += int64(n)
+	w.partsSize += int64(n)
+	checksumStart := time.Now()
+	e.checksum.Write(p[:n])
+	w.checksumTime += time.Since(checksumStart)
+
+	w.bytesWritten += n
+	return n, err
+}
+
+const manifestFormat = "deebee/entries"
+
+// manifest is written to the data file of multi-entry or segmented version. Such version is marked by a file returned
+// by manifestFilename, so data written using Writer is never mistaken for a manifest.
+type manifest struct {
+	Format      string          `json:"format"`
+	SegmentSize int64           `json:"segmentSize,omitempty"`
+	Entries     []manifestEntry `json:"entries"`
+}
+
+type manifestEntry struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Checksum []byte `json:"checksum"`
+}
+
+func (m manifest) entry(name string) (manifestEntry, bool) {
+	for _, entry := range m.Entries {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return manifestEntry{}, false
+}
+
+// Entries returns entries of a version written using EntriesWriter, in the order they were written.
+// VersionNotFound error is returned when version does not exist or it was written using Writer.
+func (s *Store) Entries(t time.Time) ([]Entry, error) {
+	_, version, err := s.chooseVersion([]ReaderOption{Time(t)})
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.readManifest(context.Background(), version, manifestFormat)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(m.Entries))
+	for i, entry := range m.Entries {
+		entries[i] = Entry{Name: entry.Name, Size: entry.Size}
+	}
+	return entries, nil
+}
+
+// EntryReader opens Reader of a single entry, without reading other entries. By default, entry of the latest version
+// is read. Reader.Version returns the size of the entry. VersionNotFound error is returned when the version has no
+// such entry.
+func (s *Store) EntryReader(name string, options ...ReaderOption) (Reader, error) {
+	return s.EntryReaderContext(context.Background(), name, options...)
+}
+
+// EntryReaderContext is like EntryReader but aborts reading once ctx is done.
+func (s *Store) EntryReaderContext(ctx context.Context, name string, options ...ReaderOption) (Reader, error) {
+	s.updateMetrics(func(m *Metrics) { m.Read.ReaderCalls++ })
+	hookCtx := s.hooks.Start(ctx, OpenReader)
+
+	var (
+		r   Reader
+		err error
+	)
+	closeReader := func() {
+		if r != nil {
+			_ = r.Close()
+		}
+	}
+	if ctxErr := runContext(ctx, func() { r, err = s.openEntryReader(ctx, name, options) }, closeReader); ctxErr != nil {
+		s.hooks.End(hookCtx, OpenReader, Result{Err: ctxErr})
+		return nil, ctxErr
+	}
+	result := Result{Err: err}
+	if r != nil {
+		result.Version = r.Version()
+	}
+	s.hooks.End(hookCtx, OpenReader, result)
+	return r, err
+}
+
+func (s *Store) openEntryReader(ctx context.Context, name string, options []ReaderOption) (Reader, error) {
+	opened := time.Now()
+	opts, version, err := s.chooseVersion(options)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.readManifest(ctx, version, manifestFormat)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := m.entry(name)
+	if !ok {
+		return nil, NewVersionNotFoundError(fmt.Sprintf("entry %s not found in version %s", name, version.Time))
+	}
+
+	entryVersion := Version{Time: version.Time, Size: entry.Size}
+	if opts.maxSize > 0 && entry.Size > opts.maxSize {
+		return nil, VersionTooLargeError{Version: entryVersion, Limit: opts.maxSize}
+	}
+	filename := entryFilename(s.dataFilename(version.Time), name)
+	r, err := s.newReader(ctx, filename, entryVersion, opts.maxSize, opened)
+	if IsVersionNotFound(err) {
+		return nil, IncompleteError{Version: version, File: filename}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.expectedChecksum = entry.Checksum
+	return r, nil
+}
+
+// readManifest reads and verifies manifest of a version with a given format. VersionNotFound error is returned when
+// the version has no such manifest, for example when version was written using Writer.
+func (s *Store) readManifest(ctx context.Context, version Version, format string) (manifest, error) {
+	dataFile := s.dataFilename(version.Time)
+	_, err := os.Stat(manifestFilename(dataFile))
+	if os.IsNotExist(err) {
+		return manifest{}, NewVersionNotFoundError(fmt.Sprintf("version %s has no manifest", version.Time))
+	}
+	if err != nil {
+		return manifest{}, fmt.Errorf("error checking manifest of version %s: %w", version.Time, err)
+	}
+
+	m, err := s.decodeManifest(ctx, dataFile, version)
+	if err != nil {
+		return manifest{}, err
+	}
+	if m.Format != format {
+		return manifest{}, NewVersionNotFoundError(fmt.Sprintf("version %s has no %s manifest", version.Time, format))
+	}
+	return m, nil
+}
+
+// decodeManifest reads manifest from data file, verifying its checksum
+func (s *Store) decodeManifest(ctx context.Context, dataFile string, version Version) (manifest, error) {
+	r, err := s.newReader(ctx, dataFile, version, 0, time.Now())
+	if err != nil {
+		return manifest{}, err
+	}
+	r.part = true
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		_ = r.close()
+		return manifest{}, err
+	}
+	if err = r.close(); err != nil {
+		return manifest{}, err
+	}
+
+	var m manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return manifest{}, fmt.Errorf("error decoding manifest of version %s: %w", version.Time, err)
+	}
+	return m, nil
+}
+
+// partFiles returns manifest marker and files of entries or segments listed in manifest. Checksum of manifest is not
+// verified, so files of a corrupted version can be removed too. False is returned when manifest cannot be decoded.
+func partFiles(dataFile string) ([]string, bool) {
+	marker := manifestFilename(dataFile)
+	if _, err := os.Stat(marker); os.IsNotExist(err) {
+		return nil, true
+	}
+	data, err := ioutil.ReadFile(dataFile)
+	if err != nil {
+		return nil, false
+	}
+	var m manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, false
+	}
+	filename := entryFilename
+	switch m.Format {
+	case manifestFormat:
+	case segmentsFormat:
+		filename = segmentFilename
+	default:
+		return nil, false
+	}
+	files := []string{marker}
+	for _, entry := range m.Entries {
+		files = append(files, filename(dataFile, entry.Name))
+	}
+	return files, true
+}
+
+// removePartFiles removes files of all entries or segments of a version, finding them by reading the directory.
+// It is used only when manifest of the version cannot be decoded.
+func (s *Store) removePartFiles(dataFile string) error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("reading dir %s failed: %w", s.dir, err)
+	}
+	for _, file := range files {
+		if !isPartFileOf(dataFile, file.Name()) {
+			continue
+		}
+		name := path.Join(s.dir, file.Name())
+		if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing file %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_EntriesWriter(t *testing.T) {
+
+	t.Run("should write entries which can be read individually", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		// when
+		version := writeEntries(t, s, map[string]string{"users": "alice", "orders": "1,2,3"})
+		// then
+		assert.Equal(t, "alice", readEntry(t, s, "users"))
+		assert.Equal(t, "1,2,3", readEntry(t, s, "orders"))
+		// and
+		entries, err := s.Entries(version.Time)
+		require.NoError(t, err)
+		assert.Equal(t, []store.Entry{{Name: "orders", Size: 5}, {Name: "users", Size: 5}}, entries)
+	})
+
+	t.Run("should make version visible only after Close", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writer, err := s.EntriesWriter()
+		require.NoError(t, err)
+		entry, err := writer.Entry("users")
+		require.NoError(t, err)
+		_, err = entry.Write([]byte("alice"))
+		require.NoError(t, err)
+		// when
+		versions, err := s.Versions()
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		// and when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		versions, err = s.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should remove all files when aborted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		writer, err := s.EntriesWriter()
+		require.NoError(t, err)
+		entry, err := writer.Entry("users")
+		require.NoError(t, err)
+		_, err = entry.Write([]byte("alice"))
+		require.NoError(t, err)
+		_, err = writer.Entry("orders")
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("should return error when entry name is used twice", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writer, err := s.EntriesWriter()
+		require.NoError(t, err)
+		defer writer.AbortAndClose()
+		_, err = writer.Entry("users")
+		require.NoError(t, err)
+		// when
+		_, err = writer.Entry("users")
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for invalid entry name", func(t *testing.T) {
+		names := []string{"", ".hidden", "a/b", "a b"}
+		for _, name := range names {
+			t.Run(name, func(t *testing.T) {
+				s := tests.OpenStore(t)
+				writer, err := s.EntriesWriter()
+				require.NoError(t, err)
+				defer writer.AbortAndClose()
+				// when
+				_, err = writer.Entry(name)
+				// then
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("should return error when Replace option is used", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		// when
+		_, err := s.EntriesWriter(store.Replace)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should apply MaxVersionSize to all entries together", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxVersionSize(8))
+		writer, err := s.EntriesWriter()
+		require.NoError(t, err)
+		users, err := writer.Entry("users")
+		require.NoError(t, err)
+		_, err = users.Write([]byte("alice"))
+		require.NoError(t, err)
+		orders, err := writer.Entry("orders")
+		require.NoError(t, err)
+		// when
+		_, err = orders.Write([]byte("1,2,3"))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+		assert.Error(t, writer.Close())
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should return error when writing finished entry", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writer, err := s.EntriesWriter()
+		require.NoError(t, err)
+		defer writer.AbortAndClose()
+		users, err := writer.Entry("users")
+		require.NoError(t, err)
+		_, err = writer.Entry("orders")
+		require.NoError(t, err)
+		// when
+		_, err = users.Write([]byte("alice"))
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestStore_EntryReader(t *testing.T) {
+
+	t.Run("should return entry of a given version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		first := writeEntries(t, s, map[string]string{"users": "alice"})
+		writeEntries(t, s, map[string]string{"users": "bob"})
+		// when
+		reader, err := s.EntryReader("users", store.Time(first.Time))
+		// then
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, "alice", string(data))
+		assert.True(t, first.Time.Equal(reader.Version().Time))
+		assert.Equal(t, int64(5), reader.Version().Size)
+	})
+
+	t.Run("should return VersionNotFound error when entry does not exist", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writeEntries(t, s, map[string]string{"users": "alice"})
+		// when
+		_, err := s.EntryReader("orders")
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return VersionNotFound error when version was written using Writer", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		// when
+		_, err := s.EntryReader("users")
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return VersionNotFound error when store is empty", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		// when
+		_, err := s.EntryReader("users")
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return VersionTooLarge error when entry exceeds MaxReadSize", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writeEntries(t, s, map[string]string{"users": "alice"})
+		// when
+		_, err := s.EntryReader("users", store.MaxReadSize(4))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+	})
+
+	t.Run("should return ChecksumMismatch error when entry file is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		writeEntries(t, s, map[string]string{"users": "alice", "orders": "1,2,3"})
+		tests.CorruptFile(t, entryFile(t, dir, "users"))
+		reader, err := s.EntryReader("users")
+		require.NoError(t, err)
+		// when
+		_, err = ioutil.ReadAll(reader)
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+		_ = reader.Close()
+		// and
+		assert.Equal(t, "1,2,3", readEntry(t, s, "orders"))
+	})
+
+	t.Run("should return Incomplete error when entry file is missing", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		writeEntries(t, s, map[string]string{"users": "alice"})
+		require.NoError(t, os.Remove(entryFile(t, dir, "users")))
+		// when
+		_, err = s.EntryReader("users")
+		// then
+		assert.True(t, store.IsIncomplete(err))
+	})
+}
+
+func TestStore_Entries(t *testing.T) {
+	t.Run("should return VersionNotFound error when version was written using Writer", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte(`{"format":"other"}`))
+		// when
+		_, err := s.Entries(version.Time)
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return VersionNotFound error when data written using Writer looks like manifest", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		data := []byte(`{"format":"deebee/entries","entries":[{"name":"users","size":0,"checksum":null}]}`)
+		version := tests.WriteData(t, s, data)
+		// when
+		_, err := s.Entries(version.Time)
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+		assert.Equal(t, data, tests.ReadData(t, s, store.Time(version.Time)))
+		assert.NoError(t, s.Verify(version.Time))
+	})
+
+	t.Run("should return empty slice for version without entries", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writer, err := s.EntriesWriter()
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		entries, err := s.Entries(writer.Version().Time)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestStore_DeleteVersionWithEntries(t *testing.T) {
+	dir := tests.TempDir(t)
+	s, err := store.Open(dir)
+	require.NoError(t, err)
+	version := writeEntries(t, s, map[string]string{"users": "alice", "orders": "1,2,3"})
+	// when
+	err = s.DeleteVersion(version.Time)
+	// then
+	require.NoError(t, err)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestStore_DeleteVersionWithCorruptedManifest(t *testing.T) {
+	dir := tests.TempDir(t)
+	s, err := store.Open(dir)
+	require.NoError(t, err)
+	version := writeEntries(t, s, map[string]string{"users": "alice", "orders": "1,2,3"})
+	tests.CorruptDataFiles(t, dir)
+	// when
+	err = s.DeleteVersion(version.Time)
+	// then
+	require.NoError(t, err)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+// writeEntries writes entries in alphabetical order
+func writeEntries(t *testing.T, s *store.Store, entries map[string]string) store.Version {
+	t.Helper()
+	writer, err := s.EntriesWriter(store.WriteTime(time.Now()))
+	require.NoError(t, err)
+	for _, name := range []string{"orders", "users"} {
+		data, ok := entries[name]
+		if !ok {
+			continue
+		}
+		entry, err := writer.Entry(name)
+		require.NoError(t, err)
+		_, err = entry.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return writer.Version()
+}
+
+func readEntry(t *testing.T, s *store.Store, name string) string {
+	t.Helper()
+	reader, err := s.EntryReader(name)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	return string(data)
+}
+
+func entryFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "."+name+".entry") {
+			return filepath.Join(dir, file.Name())
+		}
+	}
+	require.FailNow(t, "entry file not found", name)
+	return ""
+}
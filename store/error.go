@@ -14,8 +14,46 @@ func IsVersionNotFound(err error) bool {
 }
 
 func IsVersionAlreadyExists(err error) bool {
-	_, ok := err.(versionAlreadyExistsError)
-	return ok
+	target := versionAlreadyExistsError{}
+	return errors.As(err, &target)
+}
+
+// IsChecksumMismatch returns true when data of the version does not match its checksum. ChecksumMismatchError can
+// be extracted from err using errors.As.
+func IsChecksumMismatch(err error) bool {
+	target := ChecksumMismatchError{}
+	return errors.As(err, &target)
+}
+
+// IsIncomplete returns true when some file of the version is missing, for example checksum file was removed.
+// IncompleteError can be extracted from err using errors.As.
+func IsIncomplete(err error) bool {
+	target := IncompleteError{}
+	return errors.As(err, &target)
+}
+
+// IsVersionTooLarge returns true when version exceeded the limit set by MaxVersionSize or MaxReadSize option.
+func IsVersionTooLarge(err error) bool {
+	target := VersionTooLargeError{}
+	return errors.As(err, &target)
+}
+
+// IsStoreFull returns true when total size of store exceeded the limit set by MaxStoreSize option.
+func IsStoreFull(err error) bool {
+	target := StoreFullError{}
+	return errors.As(err, &target)
+}
+
+// IsInsufficientSpace returns true when there is less free space on disk than required by MinFreeSpace option.
+func IsInsufficientSpace(err error) bool {
+	target := InsufficientSpaceError{}
+	return errors.As(err, &target)
+}
+
+// IsCorrupted returns true when version cannot be trusted, because its data does not match the checksum or some of
+// its files are missing. Errors caused by inaccessible disk are not considered corruption.
+func IsCorrupted(err error) bool {
+	return IsChecksumMismatch(err) || IsIncomplete(err)
 }
 
 func NewVersionNotFoundError(msg string) error {
@@ -26,6 +64,10 @@ func NewVersionNotFoundErrorWithCause(msg string, cause error) error {
 	return versionNotFoundError{msg: msg, cause: cause}
 }
 
+func NewVersionAlreadyExistsError(msg string) error {
+	return versionAlreadyExistsError{msg: msg}
+}
+
 type versionNotFoundError struct {
 	msg   string
 	cause error
@@ -38,6 +80,10 @@ func (e versionNotFoundError) Error() string {
 	return fmt.Sprintf("%s: %s", e.msg, e.cause)
 }
 
+func (e versionNotFoundError) Unwrap() error {
+	return e.cause
+}
+
 type versionAlreadyExistsError struct {
 	msg string
 }
@@ -45,3 +91,51 @@ type versionAlreadyExistsError struct {
 func (v versionAlreadyExistsError) Error() string {
 	return v.msg
 }
+
+type ChecksumMismatchError struct {
+	Version  Version
+	File     string // data file which was read
+	Expected []byte // checksum read from checksum file
+	Actual   []byte // checksum calculated from data
+}
+
+func (e ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("invalid checksum when reading file %s: expected %x, actual %x", e.File, e.Expected, e.Actual)
+}
+
+type IncompleteError struct {
+	Version Version
+	File    string // missing file
+}
+
+func (e IncompleteError) Error() string {
+	return fmt.Sprintf("version %s is incomplete: file %s is missing", e.Version.Time, e.File)
+}
+
+type VersionTooLargeError struct {
+	Version Version
+	Limit   int64
+}
+
+func (e VersionTooLargeError) Error() string {
+	return fmt.Sprintf("version %s exceeded size limit of %d bytes", e.Version.Time, e.Limit)
+}
+
+type StoreFullError struct {
+	Dir   string
+	Limit int64
+}
+
+func (e StoreFullError) Error() string {
+	return fmt.Sprintf("store %s exceeded size limit of %d bytes", e.Dir, e.Limit)
+}
+
+type InsufficientSpaceError struct {
+	Dir      string
+	Free     uint64
+	Required uint64
+}
+
+func (e InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient free space for store %s: %d bytes free, %d bytes required", e.Dir, e.Free, e.Required)
+}
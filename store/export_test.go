@@ -0,0 +1,14 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import "os"
+
+// SyncWith replaces syncing of files, so tests can observe and fail syncs
+func SyncWith(sync func(*os.File) error) WriterOption {
+	return func(o *WriterOptions) error {
+		o.sync = sync
+		return nil
+	}
+}
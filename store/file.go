@@ -4,6 +4,7 @@
 package store
 
 import (
+	"fmt"
 	"path"
 	"strings"
 	"time"
@@ -13,11 +14,19 @@ const (
 	dataFileDateFormat = "2006-01-02T15_04_05.999999999Z"
 	dataFileSuffix     = ".data"
 	checksumFileSuffix = ".sum"
+	replacementSuffix  = ".replace"
+	entryFileSuffix    = ".entry"
+	segmentFileSuffix  = ".segment"
+	manifestFileSuffix = ".manifest"
 )
 
 func (s *Store) dataFilename(t time.Time) string {
-	name := t.UTC().Format(dataFileDateFormat) + dataFileSuffix
-	return path.Join(s.dir, name)
+	return path.Join(s.dir, dataFileBase(t))
+}
+
+// dataFileBase returns name of data file without directory
+func dataFileBase(t time.Time) string {
+	return t.UTC().Format(dataFileDateFormat) + dataFileSuffix
 }
 
 func isDataFile(name string) bool {
@@ -36,3 +45,52 @@ func isChecksum(name string) bool {
 func checksumFileForDataFile(name string) string {
 	return name + checksumFileSuffix
 }
+
+func replacementFileForDataFile(name string) string {
+	return name + replacementSuffix
+}
+
+func replacementFileForChecksumFile(name string) string {
+	return name + replacementSuffix
+}
+
+func entryFilename(dataFile, entryName string) string {
+	return dataFile + "." + entryName + entryFileSuffix
+}
+
+func segmentFilename(dataFile, segmentName string) string {
+	return dataFile + "." + segmentName + segmentFileSuffix
+}
+
+func segmentName(index int) string {
+	return fmt.Sprintf("%06d", index)
+}
+
+// manifestFilename returns name of empty file marking that data file of the version contains manifest of entries or
+// segments
+func manifestFilename(dataFile string) string {
+	return dataFile + manifestFileSuffix
+}
+
+// dataFileForPartFile returns false when name is not a file of entry or segment
+func dataFileForPartFile(name string) (string, bool) {
+	if !strings.HasSuffix(name, entryFileSuffix) && !strings.HasSuffix(name, segmentFileSuffix) {
+		return "", false
+	}
+	// entry names may contain dots, but data file name always ends with the first occurrence of dataFileSuffix
+	end := strings.Index(name, dataFileSuffix+".")
+	if end < 0 {
+		return "", false
+	}
+	return name[:end+len(dataFileSuffix)], true
+}
+
+// isPartFileOf returns true when name is a file of entry or segment, or manifest marker of a given version
+func isPartFileOf(dataFile, name string) bool {
+	base := path.Base(dataFile)
+	if name == base+manifestFileSuffix {
+		return true
+	}
+	return strings.HasPrefix(name, base+".") &&
+		(strings.HasSuffix(name, entryFileSuffix) || strings.HasSuffix(name, segmentFileSuffix))
+}
@@ -0,0 +1,59 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import "context"
+
+// Hooks are called around store operations. They can be used to bridge the store with tracing libraries, such as
+// OpenTelemetry. Hooks must be safe for concurrent use.
+type Hooks interface {
+	// Start is called when operation starts. ctx is the context passed to store method (context.Background() for
+	// methods without context). Returned context is passed to End, so it can carry a span.
+	Start(ctx context.Context, op Operation) context.Context
+	// End is called when operation ends.
+	End(ctx context.Context, op Operation, result Result)
+}
+
+// Operation is a name of store operation reported to Hooks
+type Operation string
+
+const (
+	OpenReader  Operation = "Reader"
+	CloseReader Operation = "Reader.Close"
+	OpenWriter  Operation = "Writer"
+	CloseWriter Operation = "Writer.Close"
+	AbortWriter Operation = "Writer.AbortAndClose"
+	Delete      Operation = "DeleteVersion"
+	List        Operation = "Versions"
+)
+
+// Result of operation reported to Hooks
+type Result struct {
+	// Version read, written or deleted. Zero when operation is List or when version was not chosen because of error
+	Version Version
+	// Bytes read or written. Reported by CloseReader, CloseWriter and AbortWriter
+	Bytes int64
+	Err   error
+}
+
+// WithHooks registers hooks called around store operations. Context passed to ReaderContext, WriterContext,
+// VersionsContext and DeleteVersionContext is passed to hooks, therefore operations can be linked to traces of
+// requests.
+func WithHooks(hooks Hooks) Option {
+	return func(s *Store) error {
+		if hooks == nil {
+			hooks = noHooks{}
+		}
+		s.hooks = hooks
+		return nil
+	}
+}
+
+type noHooks struct{}
+
+func (noHooks) Start(ctx context.Context, _ Operation) context.Context {
+	return ctx
+}
+
+func (noHooks) End(context.Context, Operation, Result) {}
@@ -0,0 +1,174 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHooks(t *testing.T) {
+
+	t.Run("should accept nil hooks", func(t *testing.T) {
+		s := tests.OpenStore(t, store.WithHooks(nil))
+		tests.WriteData(t, s, []byte("data"))
+		tests.ReadData(t, s)
+	})
+
+	t.Run("should report writing", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		ctx := tests.WithTraceID(context.Background(), "trace")
+		writer, err := s.WriterContext(ctx)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		version := writer.Version()
+		assert.Equal(t,
+			[]tests.EndedOperation{
+				{
+					Operation: store.OpenWriter,
+					Result:    store.Result{Version: store.Version{Time: version.Time}},
+					Started:   true,
+					TraceID:   "trace",
+				},
+				{
+					Operation: store.CloseWriter,
+					Result:    store.Result{Version: version, Bytes: 4},
+					Started:   true,
+					TraceID:   "trace",
+				},
+			},
+			hooks.Ended())
+	})
+
+	t.Run("should report aborted writing", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		ended := hooks.Ended()
+		require.Len(t, ended, 2)
+		assert.Equal(t, store.AbortWriter, ended[1].Operation)
+		assert.Equal(t, int64(4), ended[1].Result.Bytes)
+		assert.True(t, ended[1].Started)
+	})
+
+	t.Run("should report reading", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		version := tests.WriteData(t, s, []byte("data"))
+		ctx := tests.WithTraceID(context.Background(), "trace")
+		reader, err := s.ReaderContext(ctx)
+		require.NoError(t, err)
+		_, err = io.ReadAll(reader)
+		require.NoError(t, err)
+		// when
+		err = reader.Close()
+		// then
+		require.NoError(t, err)
+		ended := hooks.Ended()[2:] // skip writing
+		require.Len(t, ended, 2)
+		assert.Equal(t, store.OpenReader, ended[0].Operation)
+		assert.Equal(t, store.CloseReader, ended[1].Operation)
+		for _, e := range ended {
+			assert.True(t, e.Result.Version.Time.Equal(version.Time))
+			assert.NoError(t, e.Result.Err)
+			assert.True(t, e.Started)
+			assert.Equal(t, "trace", e.TraceID)
+		}
+		assert.Equal(t, int64(4), ended[1].Result.Bytes)
+	})
+
+	t.Run("should report error", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		// when
+		_, err := s.Reader()
+		// then
+		require.Error(t, err)
+		ended := hooks.Ended()
+		require.Len(t, ended, 1)
+		assert.Equal(t, store.OpenReader, ended[0].Operation)
+		assert.Equal(t, err, ended[0].Result.Err)
+	})
+
+	t.Run("should report listing versions", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		// when
+		_, err := s.VersionsContext(tests.WithTraceID(context.Background(), "trace"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t,
+			[]tests.EndedOperation{
+				{Operation: store.List, Started: true, TraceID: "trace"},
+			},
+			hooks.Ended())
+	})
+
+	t.Run("should report deleting version", func(t *testing.T) {
+		hooks := &tests.HooksMock{}
+		s := tests.OpenStore(t, store.WithHooks(hooks))
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		err := s.DeleteVersionContext(tests.WithTraceID(context.Background(), "trace"), version.Time)
+		// then
+		require.NoError(t, err)
+		ended := hooks.Ended()
+		assert.Equal(t,
+			tests.EndedOperation{
+				Operation: store.Delete,
+				Result:    store.Result{Version: store.Version{Time: version.Time}},
+				Started:   true,
+				TraceID:   "trace",
+			},
+			ended[len(ended)-1])
+	})
+}
+
+func TestStore_VersionsContext(t *testing.T) {
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		_, err := s.VersionsContext(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestStore_DeleteVersionContext(t *testing.T) {
+
+	t.Run("should not delete version when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := s.DeleteVersionContext(ctx, version.Time)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+}
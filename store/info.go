@@ -0,0 +1,49 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+// Info contains disk usage and version statistics of the store
+type Info struct {
+	Dir string
+	// TotalBytes is the size of all files in the store directory, including checksums and incomplete files
+	TotalBytes int64
+	// Versions is the number of versions which can be read
+	Versions int
+	// VersionBytes is the size of data of all versions
+	VersionBytes int64
+	// Oldest, Newest and Largest are zero when there are no versions
+	Oldest  Version
+	Newest  Version
+	Largest Version
+	// IncompleteFiles is the number of data files without checksum, checksum files without data and temporary files
+	// left by writers. Some of them may belong to versions being written right now.
+	IncompleteFiles int
+}
+
+// Info returns statistics computed from the store directory. Data files are not read.
+func (s *Store) Info() (Info, error) {
+	scan, err := s.scanDir()
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		Dir:             s.dir,
+		TotalBytes:      scan.totalBytes,
+		Versions:        len(scan.versions),
+		IncompleteFiles: scan.incompleteFiles,
+	}
+	if len(scan.versions) == 0 {
+		return info, nil
+	}
+	info.Oldest = scan.versions[0]
+	info.Newest = scan.versions[len(scan.versions)-1]
+	for _, v := range scan.versions {
+		info.VersionBytes += v.Size
+		if v.Size > info.Largest.Size || info.Largest.Time.IsZero() {
+			info.Largest = v
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,99 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Info(t *testing.T) {
+
+	t.Run("should return info of empty store", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		// when
+		info, err := s.Info()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, store.Info{Dir: dir}, info)
+	})
+
+	t.Run("should return statistics of versions", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		oldest := tests.WriteData(t, s, []byte("12"))
+		largest := tests.WriteData(t, s, []byte("1234"))
+		newest := tests.WriteData(t, s, []byte("123"))
+		// when
+		info, err := s.Info()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, info.Versions)
+		assert.Equal(t, int64(9), info.VersionBytes)
+		assert.True(t, oldest.Time.Equal(info.Oldest.Time))
+		assert.True(t, newest.Time.Equal(info.Newest.Time))
+		assert.True(t, largest.Time.Equal(info.Largest.Time))
+		assert.Equal(t, int64(4), info.Largest.Size)
+		assert.Equal(t, 0, info.IncompleteFiles)
+		assert.Greater(t, info.TotalBytes, info.VersionBytes) // checksum files are included
+	})
+
+	t.Run("should return total size of entries of multi-entry version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("1234"))
+		entries := writeEntries(t, s, map[string]string{
+			"orders": strings.Repeat("o", 1000),
+			"users":  strings.Repeat("u", 1000),
+		})
+		// when
+		info, err := s.Info()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, int64(2004), info.VersionBytes)
+		assert.True(t, entries.Time.Equal(info.Largest.Time))
+		assert.Equal(t, int64(2000), info.Largest.Size)
+		assert.Equal(t, int64(2000), entries.Size)
+	})
+
+	t.Run("should count incomplete files", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		writer, err := s.Writer() // data file without checksum
+		require.NoError(t, err)
+		defer writer.AbortAndClose()
+		tests.TouchFile(t, path.Join(dir, "2021-01-01T00_00_00Z.data.sum"))
+		tests.TouchFile(t, path.Join(dir, "2021-01-02T00_00_00Z.data.replace"))
+		// when
+		info, err := s.Info()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, info.Versions)
+		assert.Equal(t, 3, info.IncompleteFiles)
+	})
+
+	t.Run("should ignore directories", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, os.Mkdir(path.Join(dir, "2021-01-01T00_00_00Z.data"), 0775))
+		// when
+		info, err := s.Info()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, info.Versions)
+		assert.Equal(t, 0, info.IncompleteFiles)
+	})
+}
@@ -0,0 +1,130 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/elgopher/yala/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLoggerAdapter(t *testing.T) {
+
+	t.Run("should log data file without checksum file at debug level", func(t *testing.T) {
+		adapter := setLoggerAdapter(t)
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		removeFilesWithExtension(t, dir, ".sum")
+		// when
+		_, err = s.Versions()
+		// then
+		require.NoError(t, err)
+		entries := adapter.Entries("skipping data file without checksum file")
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.DebugLevel, entries[0].Level)
+		assert.Equal(t, dir, tests.Field(entries[0], "dir"))
+	})
+
+	t.Run("should log checksum file without data file", func(t *testing.T) {
+		adapter := setLoggerAdapter(t)
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		removeFilesWithExtension(t, dir, ".data")
+		// when
+		_, err = s.Versions()
+		// then
+		require.NoError(t, err)
+		entries := adapter.Entries("found checksum file without data file")
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.WarnLevel, entries[0].Level)
+	})
+
+	t.Run("should log checksum mismatch once", func(t *testing.T) {
+		adapter := setLoggerAdapter(t)
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		reader, err := s.Reader()
+		require.NoError(t, err)
+		// when
+		_, _ = io.ReadAll(reader)
+		_ = reader.Close()
+		// then
+		entries := adapter.Entries("version integrity check failed")
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.ErrorLevel, entries[0].Level)
+		assert.True(t, store.IsChecksumMismatch(entries[0].Error))
+		assert.True(t, version.Time.Equal(tests.Field(entries[0], "version").(time.Time)))
+	})
+
+	t.Run("should log missing checksum", func(t *testing.T) {
+		adapter := setLoggerAdapter(t)
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		reader, err := s.Reader()
+		require.NoError(t, err)
+		removeFilesWithExtension(t, dir, ".sum")
+		// when
+		_, _ = io.ReadAll(reader)
+		_ = reader.Close()
+		// then
+		entries := adapter.Entries("version integrity check failed")
+		require.Len(t, entries, 1)
+		assert.True(t, store.IsIncomplete(entries[0].Error))
+	})
+
+	t.Run("should log aborted write", func(t *testing.T) {
+		adapter := setLoggerAdapter(t)
+		s := tests.OpenStore(t)
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		entries := adapter.Entries("version aborted")
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.InfoLevel, entries[0].Level)
+		assert.Equal(t, writer.Version().Time, tests.Field(entries[0], "version"))
+		assert.Equal(t, int64(4), tests.Field(entries[0], "bytesWritten"))
+	})
+
+	t.Run("should log aborted write when context is cancelled", func(t *testing.T) {
+		adapter := setLoggerAdapter(t)
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		writer, err := s.WriterContext(ctx)
+		require.NoError(t, err)
+		cancel()
+		// when
+		_ = writer.Close()
+		// then
+		assert.Len(t, adapter.Entries("version aborted"), 1)
+	})
+}
+
+func setLoggerAdapter(t *testing.T) *tests.LoggerAdapterMock {
+	adapter := &tests.LoggerAdapterMock{}
+	store.SetLoggerAdapter(adapter)
+	t.Cleanup(func() {
+		store.SetLoggerAdapter(nil)
+	})
+	return adapter
+}
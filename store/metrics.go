@@ -6,20 +6,100 @@ package store
 import "time"
 
 type Metrics struct {
-	Read  ReadMetrics
-	Write WriteMetrics
+	Read     ReadMetrics
+	Write    WriteMetrics
+	Delete   DeleteMetrics
+	Versions VersionsMetrics
 }
 
 type ReadMetrics struct {
 	ReaderCalls    int // Number of Store.Reader() calls
 	TotalBytesRead int
 	TotalTime      time.Duration
+	Latency        Histogram     // Time between opening and closing the Reader
+	ChecksumTime   time.Duration // Time spent calculating and validating checksums
+	// ChecksumMismatches is the number of readers which found that data does not match the checksum
+	ChecksumMismatches int
+	// MissingChecksums is the number of readers which found that checksum file was missing (version was incomplete)
+	MissingChecksums int
 }
 
 type WriteMetrics struct {
 	WriterCalls       int // Number of Store.Writer() calls
 	Successful        int // Number of successful writes (when writer was closed without aborting)
 	Aborted           int // Number of aborted writes (when Writer.AbortAndClose was called)
+	Failed            int // Number of writes which failed while closing the Writer
 	TotalBytesWritten int
 	TotalTime         time.Duration
+	Latency           Histogram     // Time between opening the Writer and closing or aborting it
+	ChecksumTime      time.Duration // Time spent calculating and writing checksums
+	SyncTime          time.Duration // Time spent flushing data to disk (fsync)
+	SyncLatency       Histogram
+}
+
+type DeleteMetrics struct {
+	DeleteVersionCalls int // Number of Store.DeleteVersion() calls
+	Deleted            int // Number of versions successfully deleted
+	TotalTime          time.Duration
+	Latency            Histogram
+}
+
+type VersionsMetrics struct {
+	Scans     int // Number of directory scans, both by Store.Versions() and internal ones (such as Store.Reader())
+	TotalTime time.Duration
+	Latency   Histogram
+}
+
+// LatencyBuckets are upper bounds of Histogram buckets. The last bucket of Histogram counts latencies higher than
+// the last bound. Must not be modified.
+var LatencyBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a distribution of latencies. It can be copied safely, because buckets are stored in an array.
+type Histogram struct {
+	// Buckets[i] is the number of latencies lower or equal LatencyBuckets[i] and higher than LatencyBuckets[i-1].
+	// The last element is the number of latencies higher than all LatencyBuckets.
+	Buckets [len(LatencyBuckets) + 1]int
+	Count   int
+	Sum     time.Duration
+}
+
+// Observe adds latency d to the histogram
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.Buckets[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Cumulative returns number of latencies lower or equal each of LatencyBuckets. It is a format used by Prometheus.
+func (h Histogram) Cumulative() [len(LatencyBuckets)]int {
+	var cumulative [len(LatencyBuckets)]int
+	count := 0
+	for i := range cumulative {
+		count += h.Buckets[i]
+		cumulative[i] = count
+	}
+	return cumulative
+}
+
+func (s *Store) updateMetrics(update func(*Metrics)) {
+	s.metricsMutex.Lock()
+	defer s.metricsMutex.Unlock()
+
+	update(&s.metrics)
 }
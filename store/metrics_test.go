@@ -4,11 +4,14 @@
 package store_test
 
 import (
+	"io"
 	"testing"
+	"time"
 
 	"github.com/elgopher/deebee/internal/tests"
 	"github.com/elgopher/deebee/store"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStore_Metrics(t *testing.T) {
@@ -34,8 +37,16 @@ func TestStore_Metrics(t *testing.T) {
 				Aborted:           0,
 				TotalBytesWritten: len(data),
 				TotalTime:         metrics.TotalTime,
+				Latency:           metrics.Latency,
+				ChecksumTime:      metrics.ChecksumTime,
+				SyncTime:          metrics.SyncTime,
+				SyncLatency:       metrics.SyncLatency,
 			},
 			metrics)
+		assert.Equal(t, 1, metrics.Latency.Count)
+		assert.Equal(t, 1, metrics.SyncLatency.Count)
+		assert.Equal(t, metrics.SyncLatency.Sum, metrics.SyncTime)
+		assert.Positive(t, metrics.ChecksumTime)
 	})
 
 	t.Run("should update metrics after aborted write", func(t *testing.T) {
@@ -54,8 +65,21 @@ func TestStore_Metrics(t *testing.T) {
 				Aborted:           1,
 				TotalBytesWritten: len(data),
 				TotalTime:         metrics.TotalTime,
+				Latency:           metrics.Latency,
+				ChecksumTime:      metrics.ChecksumTime,
 			},
 			metrics)
+		assert.Equal(t, 1, metrics.Latency.Count)
+	})
+
+	t.Run("should measure negligible sync time when NoSync was used", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"), store.NoSync)
+		// when
+		metrics := s.Metrics().Write
+		// then
+		assert.Equal(t, 1, metrics.SyncLatency.Count)
+		assert.Less(t, metrics.SyncTime, time.Millisecond)
 	})
 
 	t.Run("should change metrics after executing Reader", func(t *testing.T) {
@@ -72,7 +96,131 @@ func TestStore_Metrics(t *testing.T) {
 				ReaderCalls:    1,
 				TotalBytesRead: 1,
 				TotalTime:      metrics.TotalTime,
+				ChecksumTime:   metrics.ChecksumTime,
 			},
 			metrics)
 	})
+
+	t.Run("should observe reader latency after Close", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		// when
+		tests.ReadData(t, s)
+		// then
+		metrics := s.Metrics().Read
+		assert.Equal(t, 1, metrics.Latency.Count)
+		assert.Positive(t, metrics.ChecksumTime)
+		assert.Zero(t, metrics.ChecksumMismatches)
+		assert.Zero(t, metrics.MissingChecksums)
+	})
+
+	t.Run("should count checksum mismatch once per reader", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		r, err := s.Reader()
+		require.NoError(t, err)
+		_, _ = io.ReadAll(r)
+		_ = r.Close()
+		// when
+		metrics := s.Metrics().Read
+		// then
+		assert.Equal(t, 1, metrics.ChecksumMismatches)
+		assert.Zero(t, metrics.MissingChecksums)
+	})
+
+	t.Run("should count missing checksum", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		r, err := s.Reader()
+		require.NoError(t, err)
+		removeFilesWithExtension(t, dir, ".sum")
+		_, _ = io.ReadAll(r)
+		_ = r.Close()
+		// when
+		metrics := s.Metrics().Read
+		// then
+		assert.Equal(t, 1, metrics.MissingChecksums)
+		assert.Zero(t, metrics.ChecksumMismatches)
+	})
+
+	t.Run("should change metrics after DeleteVersion", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		require.NoError(t, s.DeleteVersion(version.Time))
+		_ = s.DeleteVersion(version.Time)
+		// when
+		metrics := s.Metrics().Delete
+		// then
+		assert.Equal(t,
+			store.DeleteMetrics{
+				DeleteVersionCalls: 2,
+				Deleted:            1,
+				TotalTime:          metrics.TotalTime,
+				Latency:            metrics.Latency,
+			},
+			metrics)
+		assert.Equal(t, 2, metrics.Latency.Count)
+	})
+
+	t.Run("should count directory scans", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		_, err := s.Versions()
+		require.NoError(t, err)
+		// when
+		metrics := s.Metrics().Versions
+		// then
+		assert.Equal(t, 1, metrics.Scans)
+		assert.Equal(t, 1, metrics.Latency.Count)
+		assert.Equal(t, metrics.TotalTime, metrics.Latency.Sum)
+	})
+
+	t.Run("should be safe to get metrics while writing", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		async := tests.RunAsync(func() {
+			for i := 0; i < 10; i++ {
+				tests.WriteData(t, s, []byte("data"), store.NoSync)
+			}
+		})
+		for i := 0; i < 10; i++ {
+			_ = s.Metrics()
+		}
+		async.WaitOrFailAfter(t, 5*time.Second)
+		assert.Equal(t, 10, s.Metrics().Write.Successful)
+	})
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	t.Run("should put latency into bucket", func(t *testing.T) {
+		var h store.Histogram
+		// when
+		h.Observe(store.LatencyBuckets[0])
+		h.Observe(store.LatencyBuckets[0] + 1)
+		h.Observe(time.Hour)
+		// then
+		assert.Equal(t, 1, h.Buckets[0])
+		assert.Equal(t, 1, h.Buckets[1])
+		assert.Equal(t, 1, h.Buckets[len(h.Buckets)-1])
+		assert.Equal(t, 3, h.Count)
+		assert.Equal(t, 2*store.LatencyBuckets[0]+1+time.Hour, h.Sum)
+	})
+}
+
+func TestHistogram_Cumulative(t *testing.T) {
+	t.Run("should return cumulative counts", func(t *testing.T) {
+		var h store.Histogram
+		h.Observe(0)
+		h.Observe(store.LatencyBuckets[1])
+		h.Observe(time.Hour)
+		// when
+		cumulative := h.Cumulative()
+		// then
+		assert.Equal(t, 1, cumulative[0])
+		assert.Equal(t, 2, cumulative[1])
+		assert.Equal(t, 2, cumulative[len(cumulative)-1])
+	})
 }
@@ -0,0 +1,70 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+)
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// Namespace returns a store with its own version history, kept in a subdirectory of the store directory. The
+// directory is created if it does not exist. The returned store uses the same options as the parent store, so limits
+// such as MaxStoreSize apply to each namespace separately.
+//
+// Name may contain letters, digits, '_', '-' and '.', but must not start with '.'. Namespace returns the same
+// instance when called many times with the same name.
+func (s *Store) Namespace(name string) (*Store, error) {
+	if !nameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid namespace name %q", name)
+	}
+
+	s.namespacesMutex.Lock()
+	defer s.namespacesMutex.Unlock()
+
+	if ns, ok := s.namespaces[name]; ok {
+		return ns, nil
+	}
+
+	dir := path.Join(s.dir, name)
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, fmt.Errorf("mkdir failed for namespace directory %s: %w", dir, err)
+	}
+	ns := &Store{
+		maxVersionSize:    s.maxVersionSize,
+		maxStoreSize:      s.maxStoreSize,
+		minFreeSpace:      s.minFreeSpace,
+		reclaim:           s.reclaim,
+		readLimiter:       s.readLimiter,
+		writeLimiter:      s.writeLimiter,
+		areChecksumsEqual: s.areChecksumsEqual,
+		hooks:             s.hooks,
+		dir:               dir,
+	}
+	if s.namespaces == nil {
+		s.namespaces = map[string]*Store{}
+	}
+	s.namespaces[name] = ns
+	return ns, nil
+}
+
+// Namespaces returns names of all namespaces, sorted alphabetically. Directories which are not valid namespace names,
+// such as ones starting with '.', are skipped.
+func (s *Store) Namespaces() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading dir %s failed: %w", s.dir, err)
+	}
+	var names []string
+	for _, file := range files {
+		if file.IsDir() && nameRegexp.MatchString(file.Name()) {
+			names = append(names, file.Name())
+		}
+	}
+	return names, nil
+}
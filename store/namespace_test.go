@@ -0,0 +1,130 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Namespace(t *testing.T) {
+
+	t.Run("should return error for invalid name", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		names := []string{"", ".", "..", ".hidden", "a/b", "../a", "a b", `a\b`}
+		for _, name := range names {
+			t.Run(name, func(t *testing.T) {
+				_, err := s.Namespace(name)
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("should create namespace directory", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		// when
+		_, err = s.Namespace("users")
+		// then
+		require.NoError(t, err)
+		stat, err := os.Stat(path.Join(dir, "users"))
+		require.NoError(t, err)
+		assert.True(t, stat.IsDir())
+	})
+
+	t.Run("should return the same instance", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		first, err := s.Namespace("users")
+		require.NoError(t, err)
+		// when
+		second, err := s.Namespace("users")
+		// then
+		require.NoError(t, err)
+		assert.Same(t, first, second)
+	})
+
+	t.Run("should keep separate version history", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		users, err := s.Namespace("users")
+		require.NoError(t, err)
+		orders, err := s.Namespace("orders")
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("root"))
+		tests.WriteData(t, users, []byte("users"))
+		// when
+		rootVersions, err := s.Versions()
+		require.NoError(t, err)
+		usersVersions, err := users.Versions()
+		require.NoError(t, err)
+		ordersVersions, err := orders.Versions()
+		require.NoError(t, err)
+		// then
+		assert.Len(t, rootVersions, 1)
+		assert.Len(t, usersVersions, 1)
+		assert.Empty(t, ordersVersions)
+		assert.Equal(t, []byte("root"), tests.ReadData(t, s))
+		assert.Equal(t, []byte("users"), tests.ReadData(t, users))
+	})
+
+	t.Run("should use options of parent store", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxVersionSize(1))
+		ns, err := s.Namespace("ns")
+		require.NoError(t, err)
+		writer, err := ns.Writer()
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("12"))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+	})
+
+	t.Run("should support nested namespaces", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		ns, err := s.Namespace("a")
+		require.NoError(t, err)
+		// when
+		nested, err := ns.Namespace("b")
+		// then
+		require.NoError(t, err)
+		tests.WriteData(t, nested, []byte("data"))
+		_, err = os.Stat(path.Join(dir, "a", "b"))
+		assert.NoError(t, err)
+	})
+}
+
+func TestStore_Namespaces(t *testing.T) {
+
+	t.Run("should return no namespaces for new store", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		names, err := s.Namespaces()
+		require.NoError(t, err)
+		assert.Empty(t, names)
+	})
+
+	t.Run("should return sorted names of namespace directories", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		tests.WriteData(t, s, []byte("data"))
+		_, err = s.Namespace("users")
+		require.NoError(t, err)
+		_, err = s.Namespace("orders")
+		require.NoError(t, err)
+		require.NoError(t, os.Mkdir(path.Join(dir, ".staging"), 0775))
+		// when
+		names, err := s.Namespaces()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"orders", "users"}, names)
+	})
+}
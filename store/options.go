@@ -0,0 +1,64 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// ApplyReaderOptions returns options in a form which can be inspected by Store implementations outside this package,
+// such as remote.Client.
+func ApplyReaderOptions(options ...ReaderOption) (*ReaderOptions, error) {
+	opts := &ReaderOptions{}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// Time returns time set by Time option, or zero time when the latest version should be read
+func (o *ReaderOptions) Time() time.Time {
+	return o.time
+}
+
+// MaxSize returns limit set by MaxReadSize option, or zero when there is no limit
+func (o *ReaderOptions) MaxSize() int64 {
+	return o.maxSize
+}
+
+// ApplyWriterOptions returns options in a form which can be inspected by Store implementations outside this package,
+// such as remote.Client.
+func ApplyWriterOptions(options ...WriterOption) (*WriterOptions, error) {
+	opts := &WriterOptions{}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// Time returns time set by WriteTime option, or zero time when the store should choose the time
+func (o *WriterOptions) Time() time.Time {
+	return o.time
+}
+
+// Replace returns true when Replace option was used
+func (o *WriterOptions) Replace() bool {
+	return o.replace
+}
+
+// SegmentSize returns size set by SegmentSize option, or zero when version is not segmented
+func (o *WriterOptions) SegmentSize() int64 {
+	return o.segmentSize
+}
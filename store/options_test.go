@@ -0,0 +1,60 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyReaderOptions(t *testing.T) {
+
+	t.Run("should return zero values by default", func(t *testing.T) {
+		opts, err := store.ApplyReaderOptions()
+		require.NoError(t, err)
+		assert.True(t, opts.Time().IsZero())
+		assert.Zero(t, opts.MaxSize())
+	})
+
+	t.Run("should return applied options", func(t *testing.T) {
+		now := time.Now()
+		// when
+		opts, err := store.ApplyReaderOptions(store.Time(now), store.MaxReadSize(10), nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, now, opts.Time())
+		assert.Equal(t, int64(10), opts.MaxSize())
+	})
+
+	t.Run("should return error of option", func(t *testing.T) {
+		_, err := store.ApplyReaderOptions(store.MaxReadSize(-1))
+		assert.Error(t, err)
+	})
+}
+
+func TestApplyWriterOptions(t *testing.T) {
+
+	t.Run("should return zero values by default", func(t *testing.T) {
+		opts, err := store.ApplyWriterOptions()
+		require.NoError(t, err)
+		assert.True(t, opts.Time().IsZero())
+		assert.False(t, opts.Replace())
+		assert.Zero(t, opts.SegmentSize())
+	})
+
+	t.Run("should return applied options", func(t *testing.T) {
+		now := time.Now()
+		// when
+		opts, err := store.ApplyWriterOptions(store.WriteTime(now), store.Replace, store.SegmentSize(8), nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, now, opts.Time())
+		assert.True(t, opts.Replace())
+		assert.Equal(t, int64(8), opts.SegmentSize())
+	})
+}
@@ -0,0 +1,52 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const probeFilePattern = ".probe-*"
+
+// ProbeWrite checks if a new version could be written. It checks free space, when MinFreeSpace was used, and then
+// creates, syncs and removes a temporary file in the store directory. Versions are not changed and the reclamation
+// policy is not run.
+func (s *Store) ProbeWrite(ctx context.Context) error {
+	if s.minFreeSpace > 0 {
+		if err := s.checkFreeSpace(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if ctxErr := runContext(ctx, func() { err = s.probeWrite() }, nil); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (s *Store) probeWrite() error {
+	file, err := ioutil.TempFile(s.dir, probeFilePattern)
+	if err != nil {
+		return fmt.Errorf("error creating probe file in %s: %w", s.dir, err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write([]byte{0}); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error writing probe file %s: %w", file.Name(), err)
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error syncing probe file %s: %w", file.Name(), err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("error closing probe file %s: %w", file.Name(), err)
+	}
+	return nil
+}
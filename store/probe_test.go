@@ -0,0 +1,62 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ProbeWrite(t *testing.T) {
+
+	t.Run("should not leave any files", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		// when
+		err = s.ProbeWrite(context.Background())
+		// then
+		require.NoError(t, err)
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("should return error when directory was removed", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(dir))
+		// when
+		err = s.ProbeWrite(context.Background())
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when there is not enough free space", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MinFreeSpace(math.MaxUint64))
+		// when
+		err := s.ProbeWrite(context.Background())
+		// then
+		assert.True(t, store.IsInsufficientSpace(err))
+	})
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := s.ProbeWrite(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -4,19 +4,50 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"hash"
 	"io"
 	"io/ioutil"
 	"os"
 	"time"
+
+	"github.com/elgopher/deebee/throttle"
 )
 
-func (s *Store) openReader(options []ReaderOption, areChecksumsEqual func(expected, actual []byte) bool) (Reader, error) {
+func (s *Store) openReader(ctx context.Context, options []ReaderOption, areChecksumsEqual func(expected, actual []byte) bool) (Reader, error) {
+	opened := time.Now()
+	opts, version, err := s.chooseVersion(options)
+	if err != nil {
+		return nil, err
+	}
+	if opts.maxSize > 0 && version.Size > opts.maxSize {
+		return nil, VersionTooLargeError{Version: version, Limit: opts.maxSize}
+	}
+
+	dataFile := s.dataFilename(version.Time)
+	m, err := s.readManifest(ctx, version, segmentsFormat)
+	if err == nil {
+		return s.openSegmentedReader(ctx, dataFile, version, m, opts, opened), nil
+	}
+	if !IsVersionNotFound(err) {
+		return nil, err
+	}
+	r, err := s.newReader(ctx, dataFile, version, opts.maxSize, opened)
+	if err != nil {
+		return nil, err
+	}
+	r.areChecksumsEqual = areChecksumsEqual
+	return r, nil
+}
+
+// chooseVersion applies options and returns the version which should be read
+func (s *Store) chooseVersion(options []ReaderOption) (*ReaderOptions, Version, error) {
 	opts := &ReaderOptions{
 		chooseVersion: func(versions []Version) (Version, error) {
 			return versions[len(versions)-1], nil
 		},
+		maxSize: s.maxVersionSize,
 	}
 
 	for _, apply := range options {
@@ -24,91 +55,210 @@ func (s *Store) openReader(options []ReaderOption, areChecksumsEqual func(expect
 			continue
 		}
 		if err := apply(opts); err != nil {
-			return nil, fmt.Errorf("error applying option: %w", err)
+			return nil, Version{}, fmt.Errorf("error applying option: %w", err)
 		}
 	}
 
-	versions, err := s.Versions()
+	versions, err := s.versions()
 	if err != nil {
-		return nil, fmt.Errorf("error reading versions in directory %s: %w", s.dir, err)
+		return nil, Version{}, fmt.Errorf("error reading versions in directory %s: %w", s.dir, err)
 	}
 	if len(versions) == 0 {
-		return nil, versionNotFoundError{msg: "no version found"}
+		return nil, Version{}, versionNotFoundError{msg: "no version found"}
 	}
 
 	version, err := opts.chooseVersion(versions)
 	if err != nil {
-		return nil, err
+		return nil, Version{}, err
 	}
+	return opts, version, nil
+}
 
-	name := s.dataFilename(version.Time)
+func (s *Store) newReader(ctx context.Context, name string, version Version, maxSize int64, opened time.Time) (*reader, error) {
 	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, NewVersionNotFoundErrorWithCause(fmt.Sprintf("version %s not found", version.Time), err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error opening file %s for reading: %w", name, err)
 	}
 
 	r := &reader{
+		ctx:               ctx,
 		file:              file,
 		version:           version,
+		maxSize:           maxSize,
+		limiter:           s.readLimiter,
 		checksum:          newHash(),
-		areChecksumsEqual: areChecksumsEqual,
-		metrics:           &s.metrics.Read,
+		areChecksumsEqual: s.areChecksumsEqual,
+		store:             s,
+		opened:            opened,
 	}
 	return r, nil
 }
 
 type ReaderOptions struct {
-	chooseVersion func([]Version) (Version, error)
+	chooseVersion    func([]Version) (Version, error)
+	time             time.Time // zero when latest version is read
+	maxSize          int64
+	parallelSegments int
 }
 
 type reader struct {
+	ctx     context.Context
 	file    *os.File
+	buffer  []byte // used when reading with cancellable context
 	version Version
+	maxSize int64
+	size    int64
+	limiter throttle.Limiter
 
 	checksum          hash.Hash
+	expectedChecksum  []byte // used instead of checksum file when not nil
 	areChecksumsEqual func(expected, actual []byte) bool
+	integrityFailed   bool
 
-	metrics *ReadMetrics
+	store        *Store
+	opened       time.Time
+	part         bool          // reads part of a version, latency is not observed
+	bytesRead    int           // not yet added to store metrics
+	checksumTime time.Duration // not yet added to store metrics
 }
 
 func (r *reader) Read(p []byte) (int, error) {
-	defer r.addElapsedTime(time.Now())
+	n, err := r.readAndVerify(p)
+	if n > 0 && r.limiter != nil {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
 
-	n, err := r.file.Read(p)
+func (r *reader) readAndVerify(p []byte) (int, error) {
+	defer r.flushMetrics(time.Now())
+
+	n, err := r.read(p)
+	r.size += int64(n)
+	if r.maxSize > 0 && r.size > r.maxSize {
+		return 0, VersionTooLargeError{Version: r.version, Limit: r.maxSize}
+	}
 	if err == io.EOF {
 		if err2 := r.validateChecksum(); err2 != nil {
 			return n, err2
 		}
 	}
+	checksumStart := time.Now()
 	r.checksum.Write(p[:n])
+	r.checksumTime += time.Since(checksumStart)
 
-	r.metrics.TotalBytesRead += n
+	r.bytesRead += n
+	return n, err
+}
+
+func (r *reader) read(p []byte) (int, error) {
+	if r.ctx.Done() == nil {
+		return r.file.Read(p)
+	}
+
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	// p must not be touched by goroutine which is possibly still running after ctx is done
+	if len(r.buffer) < len(p) {
+		r.buffer = make([]byte, len(p))
+	}
+	buffer := r.buffer[:len(p)]
+	var (
+		n   int
+		err error
+	)
+	if ctxErr := runContext(r.ctx, func() { n, err = r.file.Read(buffer) }, nil); ctxErr != nil {
+		return 0, ctxErr
+	}
+	copy(p, buffer[:n])
 	return n, err
 }
 
 func (r *reader) validateChecksum() error {
+	start := time.Now()
+	defer func() {
+		r.checksumTime += time.Since(start)
+	}()
+
 	actual := r.checksum.Sum([]byte{})
 	expected, err := r.readChecksum()
+	if IsIncomplete(err) {
+		r.integrityFailure(err, func(m *ReadMetrics) { m.MissingChecksums++ })
+	}
 	if err != nil {
-		return fmt.Errorf("error reading checksum: %w", err)
+		return err
 	}
 	if !r.areChecksumsEqual(expected, actual) {
-		return fmt.Errorf("invalid checksum when reading file %s", r.file.Name())
+		err = ChecksumMismatchError{
+			Version:  r.version,
+			File:     r.file.Name(),
+			Expected: expected,
+			Actual:   actual,
+		}
+		r.integrityFailure(err, func(m *ReadMetrics) { m.ChecksumMismatches++ })
+		return err
 	}
 	return nil
 }
 
 func (r *reader) readChecksum() ([]byte, error) {
+	if r.expectedChecksum != nil {
+		return r.expectedChecksum, nil
+	}
 	checksumFile := checksumFileForDataFile(r.file.Name())
-	return ioutil.ReadFile(checksumFile)
+	var (
+		sum []byte
+		err error
+	)
+	if ctxErr := runContext(r.ctx, func() { sum, err = ioutil.ReadFile(checksumFile) }, nil); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if os.IsNotExist(err) {
+		return nil, IncompleteError{Version: r.version, File: checksumFile}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checksum: %w", err)
+	}
+	return sum, nil
+}
+
+// integrityFailure logs error and updates metrics only once per reader, even though checksum is validated again
+// in Close
+func (r *reader) integrityFailure(err error, update func(*ReadMetrics)) {
+	if r.integrityFailed {
+		return
+	}
+	r.integrityFailed = true
+	r.store.updateMetrics(func(m *Metrics) { update(&m.Read) })
+	log.With("dir", r.store.dir).With("version", r.version.Time).WithError(err).
+		Error(r.ctx, "version integrity check failed")
 }
 
 func (r *reader) Close() error {
-	defer r.addElapsedTime(time.Now())
+	hookCtx := r.store.hooks.Start(r.ctx, CloseReader)
+	err := r.close()
+	r.store.hooks.End(hookCtx, CloseReader, Result{Version: r.version, Bytes: r.size, Err: err})
+	return err
+}
+
+func (r *reader) close() error {
+	if !r.part {
+		defer r.observeLatency()
+	}
+	defer r.flushMetrics(time.Now())
 
 	if err := r.file.Close(); err != nil {
 		return fmt.Errorf("error closing file: %w", err)
 	}
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
 	return r.validateChecksum()
 }
 
@@ -116,6 +266,19 @@ func (r *reader) Version() Version {
 	return r.version
 }
 
-func (r *reader) addElapsedTime(start time.Time) {
-	r.metrics.TotalTime += time.Since(start)
+// flushMetrics adds time elapsed since start, and measurements collected so far, to store metrics
+func (r *reader) flushMetrics(start time.Time) {
+	elapsed := time.Since(start)
+	r.store.updateMetrics(func(m *Metrics) {
+		m.Read.TotalTime += elapsed
+		m.Read.TotalBytesRead += r.bytesRead
+		m.Read.ChecksumTime += r.checksumTime
+	})
+	r.bytesRead = 0
+	r.checksumTime = 0
+}
+
+func (r *reader) observeLatency() {
+	latency := time.Since(r.opened)
+	r.store.updateMetrics(func(m *Metrics) { m.Read.Latency.Observe(latency) })
 }
@@ -4,8 +4,12 @@
 package store_test
 
 import (
+	"context"
 	"errors"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,6 +77,120 @@ func TestStore_Reader(t *testing.T) {
 	})
 }
 
+func TestStore_ReaderContext(t *testing.T) {
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		r, err := s.ReaderContext(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, r)
+	})
+
+	t.Run("should read data using cancellable context", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := writeLargeData(t, s, 10, 1000)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reader, err := s.ReaderContext(ctx)
+		require.NoError(t, err)
+		// when
+		data, err := io.ReadAll(reader)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, version.Size, int64(len(data)))
+		assert.Equal(t, newBlockOfData(10, 0), data[:10])
+		assert.NoError(t, reader.Close())
+	})
+
+	t.Run("should abort reading once context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		reader, err := s.ReaderContext(ctx)
+		require.NoError(t, err)
+		// when
+		cancel()
+		// then
+		_, err = reader.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, context.Canceled)
+		err = reader.Close()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestMaxReadSize(t *testing.T) {
+
+	t.Run("should return error when option has invalid size", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		_, err := s.Reader(store.MaxReadSize(0))
+		assert.Error(t, err)
+	})
+
+	t.Run("should read version with size equal to limit", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		data := []byte("data")
+		tests.WriteData(t, s, data)
+		dataRead := tests.ReadData(t, s, store.MaxReadSize(4))
+		assert.Equal(t, data, dataRead)
+	})
+
+	t.Run("should not open version bigger than limit", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		// when
+		r, err := s.Reader(store.MaxReadSize(3))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+		assert.Nil(t, r)
+	})
+
+	t.Run("should use MaxVersionSize as a default limit", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		unlimited, _ := store.Open(dir)
+		tests.WriteData(t, unlimited, []byte("data"))
+		limited, _ := store.Open(dir, store.MaxVersionSize(3))
+		// when
+		_, err := limited.Reader()
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+		// and
+		dataRead := tests.ReadData(t, limited, store.MaxReadSize(4))
+		assert.Equal(t, []byte("data"), dataRead)
+	})
+
+	t.Run("should return error when file grew after opening", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, _ := store.Open(dir)
+		tests.WriteData(t, s, []byte("data"))
+		reader, err := s.Reader(store.MaxReadSize(4))
+		require.NoError(t, err)
+		defer closeSilently(reader)
+		appendToFilesWithExtension(t, dir, ".data", []byte("more"))
+		// when
+		_, err = io.ReadAll(reader)
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+	})
+}
+
+func appendToFilesWithExtension(t *testing.T, dir, extension string, data []byte) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+extension))
+	require.NoError(t, err)
+	for _, name := range files {
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0664)
+		require.NoError(t, err)
+		_, err = f.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+}
+
 func TestReader_Version(t *testing.T) {
 
 	t.Run("should return version", func(t *testing.T) {
@@ -122,6 +240,110 @@ func TestReader_Close(t *testing.T) {
 		// then
 		assert.Error(t, err)
 	})
+
+	versions := map[string]struct {
+		writerOptions []store.WriterOption
+		readerOptions []store.ReaderOption
+	}{
+		"plain":     {},
+		"segmented": {writerOptions: []store.WriterOption{store.SegmentSize(4)}},
+		"segmented, parallel": {
+			writerOptions: []store.WriterOption{store.SegmentSize(4)},
+			readerOptions: []store.ReaderOption{store.ParallelSegmentReads(2)},
+		},
+	}
+	for name, version := range versions {
+		version := version
+
+		t.Run("should return error when "+name+" version was read partially", func(t *testing.T) {
+			s := tests.OpenStore(t)
+			tests.WriteData(t, s, []byte("01234567"), version.writerOptions...)
+			reader, err := s.Reader(version.readerOptions...)
+			require.NoError(t, err)
+			_, err = io.ReadFull(reader, make([]byte, 4)) // exactly the first segment of segmented version
+			require.NoError(t, err)
+			// when
+			err = reader.Close()
+			// then
+			assert.Error(t, err)
+		})
+
+		t.Run("should not return error when "+name+" version was read completely without EOF", func(t *testing.T) {
+			s := tests.OpenStore(t)
+			tests.WriteData(t, s, []byte("01234567"), version.writerOptions...)
+			reader, err := s.Reader(version.readerOptions...)
+			require.NoError(t, err)
+			_, err = io.ReadFull(reader, make([]byte, 8))
+			require.NoError(t, err)
+			// when
+			err = reader.Close()
+			// then
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestReader_IntegrityErrors(t *testing.T) {
+
+	t.Run("should return ChecksumMismatchError for corrupted file", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, _ := store.Open(dir)
+		version := tests.WriteData(t, s, []byte("data to be corrupted"))
+		tests.CorruptDataFiles(t, dir)
+		reader, err := s.Reader()
+		require.NoError(t, err)
+		// when
+		_, err = io.ReadAll(reader)
+		// then
+		require.Error(t, err)
+		assert.True(t, store.IsChecksumMismatch(err))
+		assert.True(t, store.IsCorrupted(err))
+		assert.False(t, store.IsIncomplete(err))
+		var mismatch store.ChecksumMismatchError
+		require.True(t, errors.As(err, &mismatch))
+		assert.True(t, version.Time.Equal(mismatch.Version.Time))
+		assert.Equal(t, filepath.Join(dir, filepath.Base(mismatch.File)), mismatch.File)
+		assert.NotEmpty(t, mismatch.Expected)
+		assert.NotEmpty(t, mismatch.Actual)
+		assert.NotEqual(t, mismatch.Expected, mismatch.Actual)
+		// and
+		err = reader.Close()
+		assert.True(t, store.IsChecksumMismatch(err))
+	})
+
+	t.Run("should return IncompleteError when checksum file was removed", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, _ := store.Open(dir)
+		tests.WriteData(t, s, []byte("data"))
+		reader, err := s.Reader()
+		require.NoError(t, err)
+		defer closeSilently(reader)
+		removeFilesWithExtension(t, dir, ".sum")
+		// when
+		_, err = io.ReadAll(reader)
+		// then
+		require.Error(t, err)
+		assert.True(t, store.IsIncomplete(err))
+		assert.True(t, store.IsCorrupted(err))
+		assert.False(t, store.IsChecksumMismatch(err))
+		var incomplete store.IncompleteError
+		require.True(t, errors.As(err, &incomplete))
+		assert.True(t, strings.HasSuffix(incomplete.File, ".sum"))
+	})
+
+	t.Run("should not report corruption for not found version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		_, err := s.Reader()
+		assert.False(t, store.IsCorrupted(err))
+	})
+}
+
+func removeFilesWithExtension(t *testing.T, dir, extension string) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+extension))
+	require.NoError(t, err)
+	for _, file := range files {
+		require.NoError(t, os.Remove(file))
+	}
 }
 
 func closeSilently(c io.Closer) {
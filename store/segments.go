@@ -0,0 +1,371 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const segmentsFormat = "deebee/segments"
+
+// parallelSegmentSyncs is the maximum number of segments synced in background at once
+const parallelSegmentSyncs = 4
+
+// SegmentSize splits the version into segment files of a given size, so a huge version does not exceed limits of the
+// filesystem. Full segments are synced in background, while next segments are written. All segments become visible
+// at once when Writer is closed.
+//
+// Reader presents segments as one stream and Versions returns the total size of segments. Data file of the version
+// contains a manifest with sizes and checksums of all segments. Segmented version cannot be replaced.
+func SegmentSize(bytes int64) WriterOption {
+	return func(o *WriterOptions) error {
+		if bytes <= 0 {
+			return fmt.Errorf("segment size must be positive, got %d", bytes)
+		}
+		o.segmentSize = bytes
+		return nil
+	}
+}
+
+// ParallelSegmentReads reads up to n segments of a segmented version at once. Segments are read ahead into memory,
+// therefore up to n segments are allocated. By default, segments are read one after another without reading ahead.
+// The option has no effect on versions which are not segmented.
+func ParallelSegmentReads(n int) ReaderOption {
+	return func(o *ReaderOptions) error {
+		if n <= 0 {
+			return fmt.Errorf("number of parallel segment reads must be positive, got %d", n)
+		}
+		o.parallelSegments = n
+		return nil
+	}
+}
+
+// VersionSegmentSize returns segment size of a version written with SegmentSize option, or zero when version is not
+// segmented.
+func (s *Store) VersionSegmentSize(t time.Time) (int64, error) {
+	_, version, err := s.chooseVersion([]ReaderOption{Time(t)})
+	if err != nil {
+		return 0, err
+	}
+	m, err := s.readManifest(context.Background(), version, segmentsFormat)
+	if IsVersionNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return m.SegmentSize, nil
+}
+
+type segmentedWriter struct {
+	segments    *entriesWriter
+	segmentSize int64
+	current     io.Writer
+	currentSize int64
+	count       int // number of segments opened so far
+}
+
+func newSegmentedWriter(w *writer, segmentSize int64) *segmentedWriter {
+	w.segmented = true
+	w.manifest = true
+	return &segmentedWriter{
+		segments: &entriesWriter{
+			writer:   w,
+			manifest: manifest{Format: segmentsFormat, SegmentSize: segmentSize, Entries: []manifestEntry{}},
+			filename: segmentFilename,
+			syncs:    newBackgroundSyncs(parallelSegmentSyncs),
+		},
+		segmentSize: segmentSize,
+	}
+}
+
+func (s *segmentedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if s.current == nil || s.currentSize == s.segmentSize {
+			if err := s.nextSegment(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if available := s.segmentSize - s.currentSize; int64(len(chunk)) > available {
+			chunk = chunk[:available]
+		}
+		n, err := s.current.Write(chunk)
+		written += n
+		s.currentSize += int64(n)
+		p = p[n:]
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (s *segmentedWriter) nextSegment() error {
+	w, err := s.segments.Entry(segmentName(s.count))
+	if err != nil {
+		return err
+	}
+	s.count++
+	s.current = w
+	s.currentSize = 0
+	return nil
+}
+
+func (s *segmentedWriter) Close() error {
+	if s.count == 0 && !s.segments.writer.aborted {
+		// empty version still has one segment, so segments of any version start at 000000
+		if err := s.nextSegment(); err != nil {
+			return err
+		}
+	}
+	return s.segments.Close()
+}
+
+func (s *segmentedWriter) Version() Version {
+	return s.segments.Version()
+}
+
+func (s *segmentedWriter) AbortAndClose() {
+	s.segments.AbortAndClose()
+}
+
+// backgroundSyncs syncs and closes files in background, up to limit at once
+type backgroundSyncs struct {
+	semaphore chan struct{}
+	waitGroup sync.WaitGroup
+	mutex     sync.Mutex
+	err       error
+}
+
+func newBackgroundSyncs(limit int) *backgroundSyncs {
+	return &backgroundSyncs{semaphore: make(chan struct{}, limit)}
+}
+
+// start blocks when limit of files synced at once is reached
+func (b *backgroundSyncs) start(file *os.File, sync func(*os.File) error) {
+	b.semaphore <- struct{}{}
+	b.waitGroup.Add(1)
+	go func() {
+		defer func() {
+			<-b.semaphore
+			b.waitGroup.Done()
+		}()
+		err := sync(file)
+		if err != nil {
+			_ = file.Close()
+			err = fmt.Errorf("error syncing file: %w", err)
+		} else if err = file.Close(); err != nil {
+			err = fmt.Errorf("error closing file: %w", err)
+		}
+		if err != nil {
+			b.mutex.Lock()
+			if b.err == nil {
+				b.err = err
+			}
+			b.mutex.Unlock()
+		}
+	}()
+}
+
+// failed returns the first error of syncs finished so far
+func (b *backgroundSyncs) failed() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.err
+}
+
+// wait waits for all syncs and returns the first error
+func (b *backgroundSyncs) wait() error {
+	b.waitGroup.Wait()
+	return b.failed()
+}
+
+func (s *Store) openSegmentedReader(ctx context.Context, dataFile string, version Version, m manifest, opts *ReaderOptions, opened time.Time) Reader {
+	r := &segmentedReader{
+		ctx:      ctx,
+		cancel:   func() {},
+		store:    s,
+		dataFile: dataFile,
+		version:  version,
+		segments: m.Entries,
+		parallel: opts.parallelSegments,
+		opened:   opened,
+	}
+	if r.parallel > 1 {
+		r.ctx, r.cancel = context.WithCancel(ctx) // stops reading ahead once Reader is closed
+	}
+	return r
+}
+
+type segmentedReader struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	store    *Store
+	dataFile string
+	version  Version
+	segments []manifestEntry
+	parallel int
+	opened   time.Time
+	size     int64
+
+	next    int                // index of next segment to read
+	current io.Reader          // nil when next segment should be read
+	reader  *reader            // reader of current segment, nil when segment was read ahead
+	pending []chan segmentData // segments being read ahead
+}
+
+type segmentData struct {
+	data []byte
+	err  error
+}
+
+func (r *segmentedReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next == len(r.segments) && len(r.pending) == 0 {
+				return 0, io.EOF
+			}
+			if err := r.openNext(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.current.Read(p)
+		r.size += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+		if err = r.finishCurrent(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *segmentedReader) openNext() error {
+	if r.parallel <= 1 {
+		sub, err := r.openSegment(r.next)
+		if err != nil {
+			return err
+		}
+		r.next++
+		r.current, r.reader = sub, sub
+		return nil
+	}
+
+	for len(r.pending) < r.parallel && r.next < len(r.segments) {
+		r.pending = append(r.pending, r.readAhead(r.next))
+		r.next++
+	}
+	pending := r.pending[0]
+	r.pending = r.pending[1:]
+	select {
+	case segment := <-pending:
+		if segment.err != nil {
+			return segment.err
+		}
+		r.current = bytes.NewReader(segment.data)
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
+
+func (r *segmentedReader) openSegment(index int) (*reader, error) {
+	filename := segmentFilename(r.dataFile, segmentName(index))
+	sub, err := r.store.newReader(r.ctx, filename, r.version, 0, r.opened)
+	if IsVersionNotFound(err) {
+		return nil, IncompleteError{Version: r.version, File: filename}
+	}
+	if err != nil {
+		return nil, err
+	}
+	sub.expectedChecksum = r.segments[index].Checksum
+	sub.part = true
+	return sub, nil
+}
+
+func (r *segmentedReader) readAhead(index int) chan segmentData {
+	result := make(chan segmentData, 1)
+	go func() {
+		sub, err := r.openSegment(index)
+		if err != nil {
+			result <- segmentData{err: err}
+			return
+		}
+		data, err := ioutil.ReadAll(sub)
+		if closeErr := sub.close(); err == nil {
+			err = closeErr
+		}
+		result <- segmentData{data: data, err: err}
+	}()
+	return result
+}
+
+// finishCurrent closes the reader of current segment, which validates the checksum once again
+func (r *segmentedReader) finishCurrent() error {
+	r.current = nil
+	if r.reader == nil {
+		return nil
+	}
+	sub := r.reader
+	r.reader = nil
+	return sub.close()
+}
+
+func (r *segmentedReader) Close() error {
+	hookCtx := r.store.hooks.Start(r.ctx, CloseReader)
+	err := r.close()
+	r.store.hooks.End(hookCtx, CloseReader, Result{Version: r.version, Bytes: r.size, Err: err})
+	return err
+}
+
+func (r *segmentedReader) close() error {
+	defer r.observeLatency()
+	defer r.cancel()
+
+	var err error
+	if r.reader != nil {
+		sub := r.reader
+		r.reader = nil
+		err = sub.close()
+	}
+	// like reader, which cannot validate the checksum of partially read file
+	if err == nil && !r.readCompletely() {
+		err = fmt.Errorf("reader of version %s was closed before all segments were read", r.version.Time)
+	}
+	r.current = nil
+	return err
+}
+
+// readCompletely returns true when all segments were read, including data of segment read ahead
+func (r *segmentedReader) readCompletely() bool {
+	if r.next < len(r.segments) || len(r.pending) > 0 {
+		return false
+	}
+	if data, ok := r.current.(*bytes.Reader); ok && data.Len() > 0 {
+		return false
+	}
+	return true
+}
+
+func (r *segmentedReader) Version() Version {
+	return r.version
+}
+
+func (r *segmentedReader) observeLatency() {
+	latency := time.Since(r.opened)
+	r.store.updateMetrics(func(m *Metrics) { m.Read.Latency.Observe(latency) })
+}
@@ -0,0 +1,266 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentSize(t *testing.T) {
+
+	t.Run("should return error for invalid size", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		_, err := s.Writer(store.SegmentSize(0))
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when used together with Replace", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		_, err := s.Writer(store.SegmentSize(4), store.Replace)
+		assert.Error(t, err)
+	})
+
+	t.Run("should split version into segments", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		// when
+		version := tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+		// then
+		assert.Equal(t, []int64{4, 4, 2}, segmentSizes(t, dir))
+		assert.Equal(t, int64(10), version.Size)
+	})
+
+	t.Run("should list segmented version as one version with total size", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+		// when
+		versions, err := s.Versions()
+		// then
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, int64(10), versions[0].Size)
+	})
+
+	t.Run("should make version visible only after Close", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		writer, err := s.Writer(store.SegmentSize(4))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		// when
+		versions, err := s.Versions()
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		// and when
+		require.NoError(t, writer.Close())
+		// then
+		versions, err = s.Versions()
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should remove all files when aborted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		writer, err := s.Writer(store.SegmentSize(4))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("should apply MaxVersionSize to all segments together", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxVersionSize(8))
+		writer, err := s.Writer(store.SegmentSize(4))
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("0123456789"))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+	})
+
+	t.Run("should write empty version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		// when
+		tests.WriteData(t, s, []byte{}, store.SegmentSize(4))
+		// then
+		data := tests.ReadData(t, s)
+		assert.Empty(t, data)
+	})
+
+	t.Run("should delete all segments", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		version := tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+		// when
+		err = s.DeleteVersion(version.Time)
+		// then
+		require.NoError(t, err)
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+}
+
+func TestSegmentedReader(t *testing.T) {
+	readerOptions := map[string][]store.ReaderOption{
+		"sequential": nil,
+		"parallel":   {store.ParallelSegmentReads(2)},
+	}
+
+	for name, options := range readerOptions {
+		t.Run(name, func(t *testing.T) {
+
+			t.Run("should read segments as one stream", func(t *testing.T) {
+				s := tests.OpenStore(t)
+				data := []byte("0123456789")
+				tests.WriteData(t, s, data, store.SegmentSize(4))
+				// when
+				actual := tests.ReadData(t, s, options...)
+				// then
+				assert.Equal(t, data, actual)
+			})
+
+			t.Run("should return ChecksumMismatch error when segment is corrupted", func(t *testing.T) {
+				dir := tests.TempDir(t)
+				s, err := store.Open(dir)
+				require.NoError(t, err)
+				tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+				tests.CorruptFile(t, segmentFiles(t, dir)[1])
+				reader, err := s.Reader(options...)
+				require.NoError(t, err)
+				// when
+				_, err = ioutil.ReadAll(reader)
+				// then
+				assert.True(t, store.IsChecksumMismatch(err))
+				_ = reader.Close()
+			})
+
+			t.Run("should return Incomplete error when segment is missing", func(t *testing.T) {
+				dir := tests.TempDir(t)
+				s, err := store.Open(dir)
+				require.NoError(t, err)
+				tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+				require.NoError(t, os.Remove(segmentFiles(t, dir)[2]))
+				reader, err := s.Reader(options...)
+				require.NoError(t, err)
+				// when
+				_, err = ioutil.ReadAll(reader)
+				// then
+				assert.True(t, store.IsIncomplete(err))
+				_ = reader.Close()
+			})
+
+			t.Run("should return Incomplete error when first segment is missing", func(t *testing.T) {
+				dir := tests.TempDir(t)
+				s, err := store.Open(dir)
+				require.NoError(t, err)
+				tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+				require.NoError(t, os.Remove(segmentFiles(t, dir)[0]))
+				reader, err := s.Reader(options...)
+				require.NoError(t, err)
+				// when
+				_, err = ioutil.ReadAll(reader)
+				// then
+				assert.True(t, store.IsIncomplete(err))
+				_ = reader.Close()
+			})
+		})
+	}
+
+	t.Run("should return Incomplete error from Verify and Export when first segment is missing", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		version := tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+		require.NoError(t, os.Remove(segmentFiles(t, dir)[0]))
+		// when
+		err = s.Verify(version.Time)
+		// then
+		assert.True(t, store.IsIncomplete(err))
+		// and when
+		_, err = s.Export(ioutil.Discard, nil)
+		// then
+		assert.True(t, store.IsIncomplete(err))
+	})
+
+	t.Run("should return error for invalid number of parallel reads", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"), store.SegmentSize(4))
+		_, err := s.Reader(store.ParallelSegmentReads(0))
+		assert.Error(t, err)
+	})
+
+	t.Run("should ignore ParallelSegmentReads for version which is not segmented", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("data"))
+		data := tests.ReadData(t, s, store.ParallelSegmentReads(2))
+		assert.Equal(t, []byte("data"), data)
+	})
+}
+
+func TestStore_VersionSegmentSize(t *testing.T) {
+	t.Run("should return segment size", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"), store.SegmentSize(3))
+		// when
+		size, err := s.VersionSegmentSize(version.Time)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), size)
+	})
+
+	t.Run("should return zero when version is not segmented", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		// when
+		size, err := s.VersionSegmentSize(version.Time)
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, size)
+	})
+}
+
+// segmentFiles returns segment files sorted by index
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var segments []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".segment") {
+			segments = append(segments, filepath.Join(dir, file.Name()))
+		}
+	}
+	return segments
+}
+
+func segmentSizes(t *testing.T, dir string) []int64 {
+	t.Helper()
+	var sizes []int64
+	for _, file := range segmentFiles(t, dir) {
+		stat, err := os.Stat(file)
+		require.NoError(t, err)
+		sizes = append(sizes, stat.Size())
+	}
+	return sizes
+}
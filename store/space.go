@@ -0,0 +1,57 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import "fmt"
+
+// MinFreeSpace makes Store.Writer check free space on the file system before opening a writer. When there is less
+// free space than given bytes, reclamation policy is run (see ReclaimSpace) and the check is repeated. If it still
+// fails InsufficientSpaceError is returned.
+func MinFreeSpace(bytes uint64) Option {
+	return func(s *Store) error {
+		s.minFreeSpace = bytes
+		return nil
+	}
+}
+
+// ReclaimPolicy frees space when disk is full, for example by deleting old versions. compacter.Reclaim can be used
+// to delete versions the same way as compacter.RunOnce does.
+type ReclaimPolicy func(s *Store) error
+
+// ReclaimSpace sets the policy run when there is not enough free space on disk. The policy is run when MinFreeSpace
+// check failed, when MaxStoreSize was reached, or when writing data or checksum failed because
+// the disk is full (ENOSPC). After running the policy the failed operation is retried once. Failed fsync is never
+// retried, because data might be already lost.
+func ReclaimSpace(policy ReclaimPolicy) Option {
+	return func(s *Store) error {
+		s.reclaim = policy
+		return nil
+	}
+}
+
+// ensureFreeSpace checks free space before opening a writer
+func (s *Store) ensureFreeSpace() error {
+	if s.minFreeSpace == 0 {
+		return nil
+	}
+	err := s.checkFreeSpace()
+	if IsInsufficientSpace(err) && s.reclaim != nil {
+		if reclaimErr := s.reclaim(s); reclaimErr != nil {
+			return fmt.Errorf("%s, reclaiming space failed: %w", err, reclaimErr)
+		}
+		err = s.checkFreeSpace()
+	}
+	return err
+}
+
+func (s *Store) checkFreeSpace() error {
+	free, err := freeSpace(s.dir)
+	if err != nil {
+		return fmt.Errorf("error checking free space in %s: %w", s.dir, err)
+	}
+	if free < s.minFreeSpace {
+		return InsufficientSpaceError{Dir: s.dir, Free: free, Required: s.minFreeSpace}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+//go:build !linux && !darwin && !freebsd && !windows
+
+package store
+
+import "errors"
+
+func freeSpace(string) (uint64, error) {
+	return 0, errors.New("checking free space is not supported on this platform")
+}
+
+func isNoSpace(error) bool {
+	return false
+}
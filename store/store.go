@@ -5,11 +5,16 @@ package store
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"sync"
 	"time"
+
+	"github.com/elgopher/deebee/throttle"
 )
 
 func Open(dir string, options ...Option) (*Store, error) {
@@ -18,7 +23,8 @@ func Open(dir string, options ...Option) (*Store, error) {
 	}
 
 	s := &Store{
-		dir: dir,
+		dir:   dir,
+		hooks: noHooks{},
 		areChecksumsEqual: func(expected, actual []byte) bool {
 			return bytes.Equal(expected, actual) ||
 				string(expected) == "ALTERED" || string(expected) == "ALTERED\n" || string(expected) == "ALTERED\r\n"
@@ -66,24 +72,107 @@ var NoIntegrityCheck Option = func(s *Store) error {
 	return nil
 }
 
+// MaxVersionSize limits the size of a single version. Writer.Write aborts the version and returns VersionTooLargeError
+// once the limit is exceeded. The limit is also used by Reader, unless MaxReadSize option is given.
+func MaxVersionSize(bytes int64) Option {
+	return func(s *Store) error {
+		if bytes <= 0 {
+			return fmt.Errorf("max version size must be positive, got %d", bytes)
+		}
+		s.maxVersionSize = bytes
+		return nil
+	}
+}
+
+// MaxStoreSize limits total size of all files in the store directory. Store.Writer returns StoreFullError when
+// the limit is already reached, and Writer.Write aborts the version and returns StoreFullError once the limit is
+// exceeded. Usage is calculated when Writer is opened, therefore writers running concurrently can exceed the limit.
+func MaxStoreSize(bytes int64) Option {
+	return func(s *Store) error {
+		if bytes <= 0 {
+			return fmt.Errorf("max store size must be positive, got %d", bytes)
+		}
+		s.maxStoreSize = bytes
+		return nil
+	}
+}
+
+// ThrottleReads limits the number of bytes per second read by all readers of the store. The same limiter can be
+// shared with other stores, compacter and replicator using the same disk.
+func ThrottleReads(limiter throttle.Limiter) Option {
+	return func(s *Store) error {
+		s.readLimiter = limiter
+		return nil
+	}
+}
+
+// ThrottleWrites limits the number of bytes per second written by all writers of the store. When waiting for the
+// limiter fails, because context passed to WriterContext is done, the version is aborted.
+func ThrottleWrites(limiter throttle.Limiter) Option {
+	return func(s *Store) error {
+		s.writeLimiter = limiter
+		return nil
+	}
+}
+
 type Store struct {
 	failWhenMissingDir bool
+	maxVersionSize     int64
+	maxStoreSize       int64
+	minFreeSpace       uint64
+	reclaim            ReclaimPolicy
+	readLimiter        throttle.Limiter
+	writeLimiter       throttle.Limiter
 	areChecksumsEqual  func(expected, actual []byte) bool
+	hooks              Hooks
 	dir                string
 	lastVersionTime    time.Time
-	metrics            Metrics
+
+	metricsMutex sync.Mutex
+	metrics      Metrics
+
+	watchersMutex sync.Mutex
+	watchers      map[chan struct{}]struct{}
+
+	namespacesMutex sync.Mutex
+	namespaces      map[string]*Store
 }
 
 func (s *Store) Reader(options ...ReaderOption) (Reader, error) {
-	s.metrics.Read.ReaderCalls++
+	return s.ReaderContext(context.Background(), options...)
+}
+
+// ReaderContext opens Reader which aborts reading once ctx is done. All Reader methods return ctx.Err() after that.
+func (s *Store) ReaderContext(ctx context.Context, options ...ReaderOption) (Reader, error) {
+	s.updateMetrics(func(m *Metrics) { m.Read.ReaderCalls++ })
+	hookCtx := s.hooks.Start(ctx, OpenReader)
 
-	return s.openReader(options, s.areChecksumsEqual)
+	var (
+		r   Reader
+		err error
+	)
+	closeReader := func() {
+		if r != nil {
+			_ = r.Close()
+		}
+	}
+	if ctxErr := runContext(ctx, func() { r, err = s.openReader(ctx, options, s.areChecksumsEqual) }, closeReader); ctxErr != nil {
+		s.hooks.End(hookCtx, OpenReader, Result{Err: ctxErr})
+		return nil, ctxErr
+	}
+	result := Result{Err: err}
+	if r != nil {
+		result.Version = r.Version()
+	}
+	s.hooks.End(hookCtx, OpenReader, result)
+	return r, err
 }
 
 type ReaderOption func(*ReaderOptions) error
 
 func Time(t time.Time) ReaderOption {
 	return func(o *ReaderOptions) error {
+		o.time = t
 		o.chooseVersion = func(versions []Version) (Version, error) {
 			for _, version := range versions {
 				if version.Time.Equal(t) {
@@ -96,22 +185,49 @@ func Time(t time.Time) ReaderOption {
 	}
 }
 
+// MaxReadSize limits the number of bytes which can be read. Reader returns VersionTooLargeError when the version is
+// bigger, so a corrupted huge file can't exhaust memory in a decoder.
+func MaxReadSize(bytes int64) ReaderOption {
+	return func(o *ReaderOptions) error {
+		if bytes <= 0 {
+			return fmt.Errorf("max read size must be positive, got %d", bytes)
+		}
+		o.maxSize = bytes
+		return nil
+	}
+}
+
 type Reader interface {
 	io.ReadCloser
 	Version() Version
 }
 
 func (s *Store) Writer(options ...WriterOption) (Writer, error) {
-	s.metrics.Write.WriterCalls++
+	return s.WriterContext(context.Background(), options...)
+}
+
+// WriterContext opens Writer which aborts writing once ctx is done. Version is aborted the same way as when
+// Writer.AbortAndClose was called, and all Writer methods return ctx.Err().
+func (s *Store) WriterContext(ctx context.Context, options ...WriterOption) (Writer, error) {
+	s.updateMetrics(func(m *Metrics) { m.Write.WriterCalls++ })
+	hookCtx := s.hooks.Start(ctx, OpenWriter)
 
-	return s.openWriter(options)
+	w, err := s.openWriter(ctx, options)
+	result := Result{Err: err}
+	if w != nil {
+		result.Version = w.Version()
+	}
+	s.hooks.End(hookCtx, OpenWriter, result)
+	return w, err
 }
 
 type WriterOption func(*WriterOptions) error
 
 type WriterOptions struct {
-	time time.Time
-	sync func(*os.File) error
+	time        time.Time
+	sync        func(*os.File) error
+	replace     bool
+	segmentSize int64
 }
 
 // WriteTime is not named Time to avoid name conflict with ReaderOption
@@ -129,6 +245,17 @@ var NoSync WriterOption = func(o *WriterOptions) error {
 	return nil
 }
 
+// Replace allows writing a version which already exists. New data is written to a temporary file and replaces
+// the old one once Writer is closed. If version does not exist it is created.
+//
+// Replacing is not atomic. Data file and checksum file are renamed one after another, so when the process crashes or
+// renaming of the checksum file fails in between, new data is left with the checksum of old data and reading the
+// version returns ChecksumMismatch error.
+var Replace WriterOption = func(o *WriterOptions) error {
+	o.replace = true
+	return nil
+}
+
 type Writer interface {
 	io.Writer
 	// Close must be called to make version readable
@@ -140,7 +267,21 @@ type Writer interface {
 
 // Versions return slice sorted by time, oldest first
 func (s *Store) Versions() ([]Version, error) {
-	return s.versions()
+	return s.VersionsContext(context.Background())
+}
+
+// VersionsContext is like Versions but returns ctx.Err() when ctx is already done. ctx is passed to Hooks.
+func (s *Store) VersionsContext(ctx context.Context) ([]Version, error) {
+	hookCtx := s.hooks.Start(ctx, List)
+	var (
+		versions []Version
+		err      = ctx.Err()
+	)
+	if err == nil {
+		versions, err = s.versions()
+	}
+	s.hooks.End(hookCtx, List, Result{Err: err})
+	return versions, err
 }
 
 type Version struct {
@@ -150,8 +291,39 @@ type Version struct {
 }
 
 func (s *Store) DeleteVersion(t time.Time) error {
+	return s.DeleteVersionContext(context.Background(), t)
+}
+
+// DeleteVersionContext is like DeleteVersion but returns ctx.Err() when ctx is already done. ctx is passed to Hooks.
+func (s *Store) DeleteVersionContext(ctx context.Context, t time.Time) error {
+	hookCtx := s.hooks.Start(ctx, Delete)
+	err := ctx.Err()
+	if err == nil {
+		err = s.deleteVersionMeasured(t)
+	}
+	s.hooks.End(hookCtx, Delete, Result{Version: Version{Time: t}, Err: err})
+	return err
+}
+
+func (s *Store) deleteVersionMeasured(t time.Time) error {
+	start := time.Now()
+	err := s.deleteVersion(t)
+	elapsed := time.Since(start)
+	s.updateMetrics(func(m *Metrics) {
+		m.Delete.DeleteVersionCalls++
+		if err == nil {
+			m.Delete.Deleted++
+		}
+		m.Delete.TotalTime += elapsed
+		m.Delete.Latency.Observe(elapsed)
+	})
+	return err
+}
+
+func (s *Store) deleteVersion(t time.Time) error {
 	dataFile := s.dataFilename(t)
 	checksumFile := checksumFileForDataFile(dataFile)
+	parts, decoded := partFiles(dataFile) // manifest must be read before data file is removed
 
 	for _, file := range []string{dataFile, checksumFile} {
 		err := os.Remove(file)
@@ -162,9 +334,37 @@ func (s *Store) DeleteVersion(t time.Time) error {
 			return fmt.Errorf("error removing file %s: %w", file, err)
 		}
 	}
+	if !decoded {
+		if err := s.removePartFiles(dataFile); err != nil {
+			return err
+		}
+	}
+	for _, file := range parts {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing file %s: %w", file, err)
+		}
+	}
+	s.notifyWatchers()
 	return nil
 }
 
+// Checksum returns checksum stored for a given version. Checksum is not validated against the data.
+func (s *Store) Checksum(t time.Time) ([]byte, error) {
+	checksumFile := checksumFileForDataFile(s.dataFilename(t))
+	sum, err := ioutil.ReadFile(checksumFile)
+	if os.IsNotExist(err) {
+		return nil, NewVersionNotFoundError(fmt.Sprintf("version %s does not exist", t))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checksum file %s: %w", checksumFile, err)
+	}
+	return sum, nil
+}
+
+// Metrics returns a copy of metrics. It is safe to call it concurrently with other Store methods.
 func (s *Store) Metrics() Metrics {
+	s.metricsMutex.Lock()
+	defer s.metricsMutex.Unlock()
+
 	return s.metrics
 }
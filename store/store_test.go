@@ -60,6 +60,20 @@ func TestOpen(t *testing.T) {
 		assert.NotNil(t, s)
 	})
 
+	t.Run("should return error for non-positive size limits", func(t *testing.T) {
+		options := map[string]store.Option{
+			"MaxVersionSize": store.MaxVersionSize(0),
+			"MaxStoreSize":   store.MaxStoreSize(-1),
+		}
+		for name, option := range options {
+			t.Run(name, func(t *testing.T) {
+				s, err := store.Open(tests.TempDir(t), option)
+				assert.Error(t, err)
+				assert.Nil(t, s)
+			})
+		}
+	})
+
 	t.Run("should return error when option returned error", func(t *testing.T) {
 		option := func(*store.Store) error {
 			return errors.New("error")
@@ -189,6 +203,45 @@ func TestReadAfterWrite(t *testing.T) {
 	})
 }
 
+func TestThrottle(t *testing.T) {
+
+	t.Run("should throttle writes", func(t *testing.T) {
+		limiter := &tests.LimiterMock{}
+		s := tests.OpenStore(t, store.ThrottleWrites(limiter))
+		data := []byte("data")
+		// when
+		tests.WriteData(t, s, data)
+		// then
+		assert.Equal(t, len(data), limiter.Bytes())
+	})
+
+	t.Run("should abort version when write limiter returned error", func(t *testing.T) {
+		limiter := &tests.LimiterMock{ReturnError: errors.New("failed")}
+		s := tests.OpenStore(t, store.ThrottleWrites(limiter))
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("data"))
+		// then
+		assert.Error(t, err)
+		versions, err := s.Versions()
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should throttle reads", func(t *testing.T) {
+		limiter := &tests.LimiterMock{}
+		s := tests.OpenStore(t, store.ThrottleReads(limiter))
+		data := []byte("data")
+		tests.WriteData(t, s, data)
+		// when
+		dataRead := tests.ReadData(t, s)
+		// then
+		assert.Equal(t, data, dataRead)
+		assert.Equal(t, len(data), limiter.Bytes())
+	})
+}
+
 func assertNotCorrupted(t *testing.T, reader store.Reader) {
 	err1 := readAllDiscarding(reader, 8)
 	err2 := reader.Close()
@@ -0,0 +1,50 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Verify reads the whole version, including all entries and segments, and returns error when the version is
+// corrupted or cannot be read. IsCorrupted can be used to distinguish corrupted data from disk failures.
+func (s *Store) Verify(t time.Time) error {
+	return s.VerifyContext(context.Background(), t)
+}
+
+// VerifyContext is like Verify but aborts reading once ctx is done.
+func (s *Store) VerifyContext(ctx context.Context, t time.Time) error {
+	entries, err := s.Entries(t)
+	if err != nil && !IsVersionNotFound(err) {
+		return err
+	}
+	if err == nil {
+		for _, entry := range entries {
+			r, err := s.openEntryReader(ctx, entry.Name, []ReaderOption{Time(t)})
+			if err != nil {
+				return err
+			}
+			if err = readAllAndClose(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	r, err := s.openReader(ctx, []ReaderOption{Time(t)}, s.areChecksumsEqual)
+	if err != nil {
+		return err
+	}
+	return readAllAndClose(r)
+}
+
+func readAllAndClose(r Reader) error {
+	_, err := io.Copy(ioutil.Discard, r)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Verify(t *testing.T) {
+
+	t.Run("should return nil for intact versions", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		regular := tests.WriteData(t, s, []byte("data"))
+		segmented := tests.WriteData(t, s, []byte("0123456789"), store.SegmentSize(4))
+		withEntries := writeEntries(t, s, map[string]string{"users": "alice"})
+		// expect
+		assert.NoError(t, s.Verify(regular.Time))
+		assert.NoError(t, s.Verify(segmented.Time))
+		assert.NoError(t, s.Verify(withEntries.Time))
+	})
+
+	t.Run("should return VersionNotFound error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		require.NoError(t, s.DeleteVersion(version.Time))
+		// when
+		err := s.Verify(version.Time)
+		// then
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return ChecksumMismatch error when data is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		version := tests.WriteData(t, s, []byte("data"))
+		tests.CorruptDataFiles(t, dir)
+		// when
+		err = s.Verify(version.Time)
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+	})
+
+	t.Run("should return ChecksumMismatch error when entry is corrupted", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		version := writeEntries(t, s, map[string]string{"users": "alice", "orders": "1,2,3"})
+		tests.CorruptFile(t, entryFile(t, dir, "orders"))
+		// when
+		err = s.Verify(version.Time)
+		// then
+		assert.True(t, store.IsChecksumMismatch(err))
+	})
+}
@@ -4,39 +4,88 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"strings"
+	"time"
 )
 
 func (s *Store) versions() ([]Version, error) {
+	scan, err := s.scanDir()
+	return scan.versions, err
+}
+
+type dirScan struct {
+	versions        []Version
+	totalBytes      int64 // size of all regular files
+	incompleteFiles int   // data files without checksum, checksum files without data and temporary files
+}
+
+// scanDir lists versions and collects statistics of files using a single directory read
+func (s *Store) scanDir() (dirScan, error) {
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		s.updateMetrics(func(m *Metrics) {
+			m.Versions.Scans++
+			m.Versions.TotalTime += elapsed
+			m.Versions.Latency.Observe(elapsed)
+		})
+	}()
+
+	var scan dirScan
+
 	files, err := ioutil.ReadDir(s.dir)
 	if err != nil {
-		return nil, fmt.Errorf("reading dir %s failed: %w", s.dir, err)
+		return scan, fmt.Errorf("reading dir %s failed: %w", s.dir, err)
 	}
 
 	checksums := checksumSet(files)
+	partSizes := partSizes(files)
 
-	var versions []Version
 	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		scan.totalBytes += file.Size()
 		filename := file.Name()
+		if strings.HasSuffix(filename, replacementSuffix) {
+			scan.incompleteFiles++
+			continue
+		}
 		if isDataFile(filename) {
-			_, hasChecksum := checksums[checksumFileForDataFile(filename)]
+			checksumFile := checksumFileForDataFile(filename)
+			_, hasChecksum := checksums[checksumFile]
+			delete(checksums, checksumFile)
 			if !hasChecksum {
+				// version is being written or writing was interrupted
+				scan.incompleteFiles++
+				log.With("dir", s.dir).With("file", filename).
+					Debug(context.Background(), "skipping data file without checksum file")
 				continue
 			}
 			t, err := timeFromDataFile(filename)
 			if err != nil {
-				return nil, fmt.Errorf("parsing filename %s failed: %w", file, err)
+				return dirScan{}, fmt.Errorf("parsing filename %s failed: %w", file, err)
 			}
 			v := Version{
 				Time: t,
 				Size: file.Size(),
 			}
-			versions = append(versions, v)
+			if size, ok := partSizes[filename]; ok {
+				v.Size = size
+			}
+			scan.versions = append(scan.versions, v)
 		}
 	}
-	return versions, nil
+	for checksumFile := range checksums {
+		scan.incompleteFiles++
+		log.With("dir", s.dir).With("file", checksumFile).
+			Warn(context.Background(), "found checksum file without data file")
+	}
+	return scan, nil
 }
 
 func checksumSet(files []fs.FileInfo) map[string]struct{} {
@@ -48,3 +97,14 @@ func checksumSet(files []fs.FileInfo) map[string]struct{} {
 	}
 	return checksums
 }
+
+// partSizes returns total size of entries or segments for each data file of multi-entry or segmented version
+func partSizes(files []fs.FileInfo) map[string]int64 {
+	sizes := map[string]int64{}
+	for _, file := range files {
+		if dataFile, ok := dataFileForPartFile(file.Name()); ok && file.Mode().IsRegular() {
+			sizes[dataFile] += file.Size()
+		}
+	}
+	return sizes
+}
@@ -80,3 +80,41 @@ func TestStore_DeleteVersion(t *testing.T) {
 		assert.True(t, store.IsVersionNotFound(err))
 	})
 }
+
+func TestStore_Checksum(t *testing.T) {
+
+	t.Run("should return error when version does not exist", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		_, err := s.Checksum(time.Now())
+		require.Error(t, err)
+		assert.True(t, store.IsVersionNotFound(err))
+	})
+
+	t.Run("should return the same checksum for the same data", func(t *testing.T) {
+		s1, s2 := tests.OpenStore(t), tests.OpenStore(t)
+		data := []byte("data")
+		v1 := tests.WriteData(t, s1, data)
+		v2 := tests.WriteData(t, s2, data)
+		// when
+		checksum1, err := s1.Checksum(v1.Time)
+		require.NoError(t, err)
+		checksum2, err := s2.Checksum(v2.Time)
+		require.NoError(t, err)
+		// then
+		assert.NotEmpty(t, checksum1)
+		assert.Equal(t, checksum1, checksum2)
+	})
+
+	t.Run("should return different checksum for different data", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		v1 := tests.WriteData(t, s, []byte("1"))
+		v2 := tests.WriteData(t, s, []byte("2"))
+		// when
+		checksum1, err := s.Checksum(v1.Time)
+		require.NoError(t, err)
+		checksum2, err := s.Checksum(v2.Time)
+		require.NoError(t, err)
+		// then
+		assert.NotEqual(t, checksum1, checksum2)
+	})
+}
@@ -0,0 +1,167 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Watch returns a channel of events about versions created or deleted after Watch was called. Versions written or
+// deleted by this Store instance are reported immediately. Changes made by other processes are detected using
+// inotify on Linux, and additionally by polling the directory (see PollInterval), which also works on network
+// file systems. The channel is closed once ctx is done.
+//
+// Events must be received promptly, because watching is blocked until the event is received.
+func (s *Store) Watch(ctx context.Context, options ...WatchOption) (<-chan Event, error) {
+	opts := &WatchOptions{
+		pollInterval: time.Second,
+	}
+	for _, apply := range options {
+		if apply == nil {
+			continue
+		}
+		if err := apply(opts); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+
+	known, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	trigger := make(chan struct{}, 1)
+	s.addWatcher(trigger)
+	if err = watchDir(ctx, s.dir, trigger); err != nil {
+		log.With("dir", s.dir).WithError(err).Debug(ctx, "watching directory not supported, polling is used")
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer s.removeWatcher(trigger)
+
+		ticker := time.NewTicker(opts.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-trigger:
+			case <-ticker.C:
+			}
+			current, err := s.versions()
+			if err != nil {
+				log.With("dir", s.dir).WithError(err).Warn(ctx, "scanning directory for changes failed")
+				continue
+			}
+			for _, event := range diffVersions(known, current) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			known = current
+		}
+	}()
+
+	return events, nil
+}
+
+type WatchOption func(*WatchOptions) error
+
+type WatchOptions struct {
+	pollInterval time.Duration
+}
+
+// PollInterval sets how often the directory is scanned for changes made by other processes. Default is one second.
+func PollInterval(d time.Duration) WatchOption {
+	return func(o *WatchOptions) error {
+		if d <= 0 {
+			return fmt.Errorf("poll interval must be positive, got %s", d)
+		}
+		o.pollInterval = d
+		return nil
+	}
+}
+
+type Event struct {
+	Type    EventType
+	Version Version
+}
+
+type EventType int
+
+const (
+	VersionCreated EventType = iota + 1
+	VersionDeleted
+)
+
+func (t EventType) String() string {
+	switch t {
+	case VersionCreated:
+		return "VersionCreated"
+	case VersionDeleted:
+		return "VersionDeleted"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// diffVersions returns events for deleted versions first, then for created ones (oldest first)
+func diffVersions(previous, current []Version) []Event {
+	currentSet := map[int64]struct{}{}
+	for _, v := range current {
+		currentSet[v.Time.UnixNano()] = struct{}{}
+	}
+	previousSet := map[int64]struct{}{}
+	var events []Event
+	for _, v := range previous {
+		previousSet[v.Time.UnixNano()] = struct{}{}
+		if _, ok := currentSet[v.Time.UnixNano()]; !ok {
+			events = append(events, Event{Type: VersionDeleted, Version: v})
+		}
+	}
+	for _, v := range current {
+		if _, ok := previousSet[v.Time.UnixNano()]; !ok {
+			events = append(events, Event{Type: VersionCreated, Version: v})
+		}
+	}
+	return events
+}
+
+func (s *Store) addWatcher(trigger chan struct{}) {
+	s.watchersMutex.Lock()
+	defer s.watchersMutex.Unlock()
+	if s.watchers == nil {
+		s.watchers = map[chan struct{}]struct{}{}
+	}
+	s.watchers[trigger] = struct{}{}
+}
+
+func (s *Store) removeWatcher(trigger chan struct{}) {
+	s.watchersMutex.Lock()
+	defer s.watchersMutex.Unlock()
+	delete(s.watchers, trigger)
+}
+
+// notifyWatchers makes all watchers scan the directory without waiting for the next poll
+func (s *Store) notifyWatchers() {
+	s.watchersMutex.Lock()
+	defer s.watchersMutex.Unlock()
+	for trigger := range s.watchers {
+		notify(trigger)
+	}
+}
+
+func notify(trigger chan<- struct{}) {
+	select {
+	case trigger <- struct{}{}:
+	default: // scan is already pending
+	}
+}
@@ -0,0 +1,36 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Watch_Inotify(t *testing.T) {
+
+	t.Run("should report version written by another store without polling", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		watched, err := store.Open(dir)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := watched.Watch(ctx, store.PollInterval(time.Hour))
+		require.NoError(t, err)
+		other, err := store.Open(dir)
+		require.NoError(t, err)
+		// when
+		version := tests.WriteData(t, other, []byte("data"))
+		// then
+		event := receiveEvent(t, events)
+		assert.Equal(t, store.VersionCreated, event.Type)
+		assert.True(t, version.Time.Equal(event.Version.Time))
+	})
+}
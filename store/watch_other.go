@@ -0,0 +1,15 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+//go:build !linux
+
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+func watchDir(context.Context, string, chan<- struct{}) error {
+	return errors.New("watching directory is not supported on this platform")
+}
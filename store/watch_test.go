@@ -0,0 +1,132 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Watch(t *testing.T) {
+
+	t.Run("should return error when option returned error", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		events, err := s.Watch(context.Background(), store.PollInterval(0))
+		assert.Error(t, err)
+		assert.Nil(t, events)
+	})
+
+	t.Run("should close channel once context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := s.Watch(ctx)
+		require.NoError(t, err)
+		// when
+		cancel()
+		// then
+		select {
+		case _, ok := <-events:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout waiting for channel to be closed")
+		}
+	})
+
+	t.Run("should immediately report version written by the same store", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := s.Watch(ctx, store.PollInterval(time.Hour))
+		require.NoError(t, err)
+		// when
+		version := tests.WriteData(t, s, []byte("data"))
+		// then
+		event := receiveEvent(t, events)
+		assert.Equal(t, store.VersionCreated, event.Type)
+		assert.True(t, version.Time.Equal(event.Version.Time))
+	})
+
+	t.Run("should immediately report version deleted by the same store", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := s.Watch(ctx, store.PollInterval(time.Hour))
+		require.NoError(t, err)
+		// when
+		require.NoError(t, s.DeleteVersion(version.Time))
+		// then
+		event := receiveEvent(t, events)
+		assert.Equal(t, store.VersionDeleted, event.Type)
+		assert.True(t, version.Time.Equal(event.Version.Time))
+	})
+
+	t.Run("should report version written by another store", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		watched, err := store.Open(dir)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := watched.Watch(ctx, store.PollInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		other, err := store.Open(dir)
+		require.NoError(t, err)
+		// when
+		version := tests.WriteData(t, other, []byte("data"))
+		// then
+		event := receiveEvent(t, events)
+		assert.Equal(t, store.VersionCreated, event.Type)
+		assert.True(t, version.Time.Equal(event.Version.Time))
+	})
+
+	t.Run("should not report versions existing before Watch was called", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		tests.WriteData(t, s, []byte("old"))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := s.Watch(ctx, store.PollInterval(time.Millisecond))
+		require.NoError(t, err)
+		// when
+		version := tests.WriteData(t, s, []byte("new"))
+		// then
+		event := receiveEvent(t, events)
+		assert.True(t, version.Time.Equal(event.Version.Time))
+	})
+
+	t.Run("should not report aborted version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := s.Watch(ctx, store.PollInterval(time.Millisecond))
+		require.NoError(t, err)
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		select {
+		case event := <-events:
+			assert.Failf(t, "unexpected event", "%+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func receiveEvent(t *testing.T, events <-chan store.Event) store.Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		require.FailNow(t, "timeout waiting for event")
+		return store.Event{}
+	}
+}
@@ -4,14 +4,20 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"hash"
 	"io/ioutil"
 	"os"
 	"time"
+
+	"github.com/elgopher/deebee/throttle"
+	"github.com/elgopher/yala/logger"
 )
 
-func (s *Store) openWriter(options []WriterOption) (Writer, error) {
+func (s *Store) openWriter(ctx context.Context, options []WriterOption) (Writer, error) {
+	opened := time.Now()
 	opts := &WriterOptions{
 		time: s.nextVersionTime(),
 		sync: (*os.File).Sync,
@@ -25,8 +31,41 @@ func (s *Store) openWriter(options []WriterOption) (Writer, error) {
 		}
 	}
 
-	name := s.dataFilename(opts.time)
-	file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0664)
+	if opts.replace && opts.segmentSize > 0 {
+		return nil, errors.New("segmented version cannot be replaced")
+	}
+
+	if err := s.ensureFreeSpace(); err != nil {
+		return nil, err
+	}
+	limits, err := s.writeLimits()
+	if IsStoreFull(err) && s.reclaim != nil {
+		if reclaimErr := s.reclaim(s); reclaimErr != nil {
+			return nil, fmt.Errorf("%s, reclaiming space failed: %w", err, reclaimErr)
+		}
+		limits, err = s.writeLimits()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dataFile := s.dataFilename(opts.time)
+	name := dataFile
+	flag := os.O_CREATE | os.O_EXCL | os.O_WRONLY
+	if opts.replace {
+		name = replacementFileForDataFile(dataFile)
+		flag = os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	}
+	var file *os.File
+	removeFile := func() {
+		if file != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}
+	if ctxErr := runContext(ctx, func() { file, err = os.OpenFile(name, flag, 0664) }, removeFile); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if os.IsExist(err) {
 		return nil, versionAlreadyExistsError{msg: fmt.Sprintf("version %s already exists: %s", opts.time, err)}
 	}
@@ -34,15 +73,61 @@ func (s *Store) openWriter(options []WriterOption) (Writer, error) {
 		return nil, fmt.Errorf("error opening the file %s for writing: %w", name, err)
 	}
 	w := &writer{
+		ctx:      ctx,
 		file:     file,
+		dataFile: dataFile,
+		replace:  opts.replace,
 		time:     opts.time,
 		sync:     opts.sync,
+		limits:   limits,
+		limiter:  s.writeLimiter,
+		store:    s,
 		checksum: newHash(),
-		metrics:  &s.metrics.Write,
+		opened:   opened,
+	}
+	if opts.segmentSize > 0 {
+		return newSegmentedWriter(w, opts.segmentSize), nil
 	}
 	return w, nil
 }
 
+// writeLimits returns limits for a new version. Zero means no limit.
+func (s *Store) writeLimits() (writeLimits, error) {
+	limits := writeLimits{version: s.maxVersionSize, store: s.maxStoreSize}
+	if s.maxStoreSize == 0 {
+		return limits, nil
+	}
+	used, err := s.usedSpace()
+	if err != nil {
+		return limits, err
+	}
+	if used >= s.maxStoreSize {
+		return limits, StoreFullError{Dir: s.dir, Limit: s.maxStoreSize}
+	}
+	limits.storeAvailable = s.maxStoreSize - used
+	return limits, nil
+}
+
+type writeLimits struct {
+	version        int64 // max size of version
+	store          int64 // max size of store
+	storeAvailable int64 // max number of bytes which can be added to store
+}
+
+func (s *Store) usedSpace() (int64, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("reading dir %s failed: %w", s.dir, err)
+	}
+	var used int64
+	for _, file := range files {
+		if file.Mode().IsRegular() {
+			used += file.Size()
+		}
+	}
+	return used, nil
+}
+
 func (s *Store) nextVersionTime() time.Time {
 	t := time.Now()
 	if s.lastVersionTime == t {
@@ -53,67 +138,311 @@ func (s *Store) nextVersionTime() time.Time {
 }
 
 type writer struct {
+	ctx      context.Context
 	file     *os.File
+	buffer   []byte // used when writing with cancellable context
+	aborted  bool
+	dataFile string
+	replace  bool
 	time     time.Time
 	sync     func(*os.File) error
+	limits   writeLimits
+	limiter  throttle.Limiter
+	store    *Store
 	size     int64
 	checksum hash.Hash
 
-	metrics *WriteMetrics
+	partFiles []string // files of entries or segments, written before data file is closed
+	partsSize int64    // number of bytes written to part files
+	segmented bool     // data is written to segments and data file contains manifest
+	manifest  bool     // data file contains manifest of entries or segments, which is marked by a separate file
+
+	opened       time.Time
+	bytesWritten int           // not yet added to store metrics
+	checksumTime time.Duration // not yet added to store metrics
 }
 
 func (w *writer) Write(p []byte) (int, error) {
-	defer w.addElapsedTime(time.Now())
+	if w.limiter != nil {
+		if err := w.limiter.WaitN(w.ctx, len(p)); err != nil {
+			w.abort()
+			return 0, err
+		}
+	}
+	return w.writeAndChecksum(p)
+}
+
+func (w *writer) writeAndChecksum(p []byte) (int, error) {
+	defer w.flushMetrics(time.Now())
 
-	n, err := w.file.Write(p)
+	if err := w.checkLimits(len(p)); err != nil {
+		w.abort()
+		return 0, err
+	}
+
+	var n int
+	err := w.retryOnNoSpace(func() error {
+		written, e := w.write(w.file, p[n:])
+		n += written
+		return e
+	})
+	if err != nil && w.ctx.Err() != nil {
+		w.abort()
+	}
 	w.size += int64(n)
+	checksumStart := time.Now()
 	w.checksum.Write(p[:n])
+	w.checksumTime += time.Since(checksumStart)
+
+	w.bytesWritten += n
+	return n, err
+}
+
+func (w *writer) checkLimits(bytesToWrite int) error {
+	err := w.limitsError(bytesToWrite)
+	if !IsStoreFull(err) || w.store.reclaim == nil {
+		return err
+	}
+	if reclaimErr := w.store.reclaim(w.store); reclaimErr != nil {
+		return fmt.Errorf("%s, reclaiming space failed: %w", err, reclaimErr)
+	}
+	used, usedErr := w.store.usedSpace()
+	if usedErr != nil {
+		return usedErr
+	}
+	w.limits.storeAvailable = w.limits.store - used + w.size + w.partsSize // used already includes data written by w
+	return w.limitsError(bytesToWrite)
+}
+
+func (w *writer) limitsError(bytesToWrite int) error {
+	newSize := w.size + w.partsSize + int64(bytesToWrite)
+	if w.limits.version > 0 && newSize > w.limits.version {
+		return VersionTooLargeError{Version: w.Version(), Limit: w.limits.version}
+	}
+	if w.limits.store > 0 && newSize > w.limits.storeAvailable {
+		return StoreFullError{Dir: w.store.dir, Limit: w.limits.store}
+	}
+	return nil
+}
+
+func (w *writer) write(file *os.File, p []byte) (int, error) {
+	if w.ctx.Done() == nil {
+		return file.Write(p)
+	}
 
-	w.metrics.TotalBytesWritten += n
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	// p must not be touched by goroutine which is possibly still running after ctx is done
+	w.buffer = append(w.buffer[:0], p...)
+	var (
+		n   int
+		err error
+	)
+	if ctxErr := runContext(w.ctx, func() { n, err = file.Write(w.buffer) }, nil); ctxErr != nil {
+		return 0, ctxErr
+	}
 	return n, err
 }
 
 func (w *writer) Close() error {
-	defer w.addElapsedTime(time.Now())
+	hookCtx := w.store.hooks.Start(w.ctx, CloseWriter)
+	err := w.commit()
+	w.store.hooks.End(hookCtx, CloseWriter, Result{Version: w.Version(), Bytes: w.Version().Size, Err: err})
+	return err
+}
+
+func (w *writer) commit() error {
+	defer w.flushMetrics(time.Now())
+
+	if w.aborted {
+		return errors.New("version was aborted")
+	}
+	if err := w.ctx.Err(); err != nil {
+		w.abort()
+		return err
+	}
+
+	var err error
+	if ctxErr := runContext(w.ctx, func() { err = w.close() }, w.removeFiles); ctxErr != nil {
+		w.aborted = true
+		w.finish(func(m *WriteMetrics) { m.Aborted++ })
+		w.logger().WithError(ctxErr).Info(w.ctx, "version aborted while closing writer")
+		return ctxErr
+	}
+	if err != nil {
+		w.finish(func(m *WriteMetrics) { m.Failed++ })
+		w.logger().WithError(err).Error(w.ctx, "closing writer failed")
+		return err
+	}
+
+	w.finish(func(m *WriteMetrics) { m.Successful++ })
+	w.store.notifyWatchers()
+	return nil
+}
 
-	if err := w.writeChecksum(); err != nil {
+// retryOnNoSpace runs f once again after reclaiming space when f failed because the disk is full
+func (w *writer) retryOnNoSpace(f func() error) error {
+	err := f()
+	if err == nil || w.store.reclaim == nil || !isNoSpace(err) {
+		return err
+	}
+	if reclaimErr := w.store.reclaim(w.store); reclaimErr != nil {
+		return fmt.Errorf("%s, reclaiming space failed: %w", err, reclaimErr)
+	}
+	return f()
+}
+
+// close may still run after Close returned, so it updates store metrics directly instead of writer fields. Version
+// becomes visible only after data file was synced and closed. Files of the version are removed when closing failed.
+func (w *writer) close() error {
+	checksumStart := time.Now()
+	err := w.retryOnNoSpace(w.writeChecksum)
+	checksumTime := time.Since(checksumStart)
+	w.store.updateMetrics(func(m *Metrics) { m.Write.ChecksumTime += checksumTime })
+	if err != nil {
 		_ = w.file.Close()
+		w.removeFiles()
 		return fmt.Errorf("error writing checksum: %w", err)
 	}
-	if err := w.sync(w.file); err != nil {
+
+	syncStart := time.Now()
+	err = w.sync(w.file)
+	syncTime := time.Since(syncStart)
+	w.store.updateMetrics(func(m *Metrics) {
+		m.Write.SyncTime += syncTime
+		m.Write.SyncLatency.Observe(syncTime)
+	})
+	if err != nil {
 		_ = w.file.Close()
+		w.removeFiles()
 		return fmt.Errorf("error syncing file: %w", err)
 	}
 	if err := w.file.Close(); err != nil {
+		w.removeFiles()
 		return fmt.Errorf("error closing file: %w", err)
 	}
-
-	w.metrics.Successful++
+	if w.replace {
+		if err := w.replaceVersion(); err != nil {
+			w.removeFiles()
+			return err
+		}
+		return nil
+	}
+	if w.manifest {
+		if err := ioutil.WriteFile(manifestFilename(w.dataFile), nil, 0664); err != nil {
+			w.removeFiles()
+			return fmt.Errorf("error writing manifest marker: %w", err)
+		}
+	}
+	checksumFile := checksumFileForDataFile(w.dataFile)
+	if err := os.Rename(replacementFileForChecksumFile(checksumFile), checksumFile); err != nil {
+		w.removeFiles()
+		return fmt.Errorf("error renaming checksum file %s: %w", checksumFile, err)
+	}
 	return nil
 }
 
+// writeChecksum writes checksum to a temporary file, so version never becomes visible with partially written
+// checksum. The temporary file is renamed by close, or by replaceVersion when replacing.
 func (w *writer) writeChecksum() error {
-	checksumFile := checksumFileForDataFile(w.file.Name())
+	tmpFile := replacementFileForChecksumFile(checksumFileForDataFile(w.dataFile))
 	sum := w.checksum.Sum([]byte{})
-	return ioutil.WriteFile(checksumFile, sum, 0664)
+	if err := ioutil.WriteFile(tmpFile, sum, 0664); err != nil {
+		_ = os.Remove(tmpFile)
+		return err
+	}
+	return nil
+}
+
+// replaceVersion renames replacement files to their final names. Data file is renamed first, therefore when checksum
+// of new data is different, readers may observe checksum mismatch until checksum file is renamed too. When renaming
+// of checksum file fails, new data stays paired with the old checksum (see Replace).
+func (w *writer) replaceVersion() error {
+	if err := os.Rename(w.file.Name(), w.dataFile); err != nil {
+		return fmt.Errorf("error replacing data file %s: %w", w.dataFile, err)
+	}
+	checksumFile := checksumFileForDataFile(w.dataFile)
+	if err := os.Rename(replacementFileForChecksumFile(checksumFile), checksumFile); err != nil {
+		return fmt.Errorf("error replacing checksum file %s: %w", checksumFile, err)
+	}
+	return nil
 }
 
 func (w *writer) Version() Version {
+	size := w.size
+	if w.manifest {
+		size = w.partsSize
+	}
 	return Version{
 		Time: w.time,
-		Size: w.size,
+		Size: size,
 	}
 }
 
 func (w *writer) AbortAndClose() {
-	defer w.addElapsedTime(time.Now())
+	hookCtx := w.store.hooks.Start(w.ctx, AbortWriter)
+	defer w.store.hooks.End(hookCtx, AbortWriter, Result{Version: w.Version(), Bytes: w.Version().Size})
+	defer w.flushMetrics(time.Now())
+
+	w.abort()
+}
+
+func (w *writer) abort() {
+	if w.aborted {
+		return
+	}
+	w.aborted = true
 
 	_ = w.file.Close()
 	_ = os.Remove(w.file.Name())
+	_ = os.Remove(replacementFileForChecksumFile(checksumFileForDataFile(w.dataFile)))
+	w.removePartFiles()
+
+	w.finish(func(m *WriteMetrics) { m.Aborted++ })
+	w.logger().Info(w.ctx, "version aborted")
+}
+
+func (w *writer) logger() logger.Logger {
+	return log.With("dir", w.store.dir).With("version", w.time).With("bytesWritten", w.size)
+}
+
+// removeFiles removes files of a version which was aborted or failed while closing
+func (w *writer) removeFiles() {
+	_ = os.Remove(w.file.Name())
+	_ = os.Remove(replacementFileForChecksumFile(checksumFileForDataFile(w.dataFile)))
+	if !w.replace {
+		_ = os.Remove(checksumFileForDataFile(w.dataFile))
+	}
+	w.removePartFiles()
+}
+
+func (w *writer) removePartFiles() {
+	for _, file := range w.partFiles {
+		_ = os.Remove(file)
+	}
+	if w.manifest {
+		_ = os.Remove(manifestFilename(w.dataFile))
+	}
+}
 
-	w.metrics.Aborted++
+// flushMetrics adds time elapsed since start, and measurements collected so far, to store metrics
+func (w *writer) flushMetrics(start time.Time) {
+	elapsed := time.Since(start)
+	w.store.updateMetrics(func(m *Metrics) {
+		m.Write.TotalTime += elapsed
+		m.Write.TotalBytesWritten += w.bytesWritten
+		m.Write.ChecksumTime += w.checksumTime
+	})
+	w.bytesWritten = 0
+	w.checksumTime = 0
 }
 
-func (w *writer) addElapsedTime(start time.Time) {
-	w.metrics.TotalTime += time.Since(start)
+// finish updates metrics of a writer which was closed or aborted
+func (w *writer) finish(update func(*WriteMetrics)) {
+	latency := time.Since(w.opened)
+	w.store.updateMetrics(func(m *Metrics) {
+		update(&m.Write)
+		m.Write.Latency.Observe(latency)
+	})
 }
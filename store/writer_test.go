@@ -4,7 +4,14 @@
 package store_test
 
 import (
+	"context"
 	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -66,6 +73,68 @@ func TestStore_Writer(t *testing.T) {
 
 }
 
+func TestStore_WriterContext(t *testing.T) {
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		w, err := s.WriterContext(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, w)
+		assert.Empty(t, readVersions(t, s))
+	})
+
+	t.Run("should write data using cancellable context", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		writer, err := s.WriterContext(ctx)
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("data1"))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data2"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// then
+		assert.Equal(t, []byte("data1data2"), tests.ReadData(t, s))
+	})
+
+	t.Run("should abort version when context is cancelled before Write", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		writer, err := s.WriterContext(ctx)
+		require.NoError(t, err)
+		// when
+		cancel()
+		_, err = writer.Write([]byte("data"))
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		writer.AbortAndClose() // should be ignored
+		assert.Empty(t, readVersions(t, s))
+		assert.Equal(t, 1, s.Metrics().Write.Aborted)
+	})
+
+	t.Run("should abort version when context is cancelled before Close", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		writer, err := s.WriterContext(ctx)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		cancel()
+		err = writer.Close()
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, readVersions(t, s))
+		assert.Equal(t, 0, s.Metrics().Write.Successful)
+	})
+}
+
 func TestWriter_Write(t *testing.T) {
 
 	t.Run("should return length of data and nil error", func(t *testing.T) {
@@ -181,6 +250,309 @@ func TestWriter_Close(t *testing.T) {
 		// then
 		require.NoError(t, err)
 	})
+
+	t.Run("should make version visible only after data file was synced", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		var versionsDuringSync []store.Version
+		sync := func(file *os.File) error {
+			versionsDuringSync = readVersions(t, s)
+			return file.Sync()
+		}
+		writer, err := s.Writer(store.SyncWith(sync))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, versionsDuringSync)
+		assert.Len(t, readVersions(t, s), 1)
+	})
+
+	t.Run("should remove all files when sync failed", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		syncErr := errors.New("sync failed")
+		sync := func(*os.File) error {
+			return syncErr
+		}
+		writer, err := s.Writer(store.SyncWith(sync))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.ErrorIs(t, err, syncErr)
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("should not make version visible when writing checksum failed", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir)
+		require.NoError(t, err)
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		dataFiles, err := filepath.Glob(path.Join(dir, "*.data"))
+		require.NoError(t, err)
+		require.Len(t, dataFiles, 1)
+		// temporary checksum file cannot be written, because there is a non-empty directory with the same name
+		tmpChecksumFile := dataFiles[0] + ".sum.replace"
+		require.NoError(t, os.Mkdir(tmpChecksumFile, 0775))
+		tests.TouchFile(t, path.Join(tmpChecksumFile, "file"))
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+		assert.Empty(t, readVersions(t, s))
+		files, err := filepath.Glob(path.Join(dir, "*.data*"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{tmpChecksumFile}, files) // only the directory created by the test is left
+	})
+}
+
+func TestReplace(t *testing.T) {
+
+	t.Run("should replace existing version", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		version := tests.WriteData(t, s, []byte("old"))
+		newData := []byte("new data")
+		// when
+		tests.WriteData(t, s, newData, store.WriteTime(version.Time), store.Replace)
+		// then
+		dataRead := tests.ReadData(t, s, store.Time(version.Time))
+		assert.Equal(t, newData, dataRead)
+		versions := readVersions(t, s)
+		require.Len(t, versions, 1)
+		assert.Equal(t, int64(len(newData)), versions[0].Size)
+	})
+
+	t.Run("should create version when it does not exist", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		data := []byte("data")
+		// when
+		version := tests.WriteData(t, s, data, store.Replace)
+		// then
+		dataRead := tests.ReadData(t, s, store.Time(version.Time))
+		assert.Equal(t, data, dataRead)
+	})
+
+	t.Run("should keep old data until writer is closed", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		oldData := []byte("old")
+		version := tests.WriteData(t, s, oldData)
+		writer, err := s.Writer(store.WriteTime(version.Time), store.Replace)
+		require.NoError(t, err)
+		defer closeSilently(writer)
+		_, err = writer.Write([]byte("new"))
+		require.NoError(t, err)
+		// when
+		dataRead := tests.ReadData(t, s, store.Time(version.Time))
+		// then
+		assert.Equal(t, oldData, dataRead)
+	})
+
+	t.Run("aborted replacement should keep old data", func(t *testing.T) {
+		s := tests.OpenStore(t)
+		oldData := []byte("old")
+		version := tests.WriteData(t, s, oldData)
+		writer, err := s.Writer(store.WriteTime(version.Time), store.Replace)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("new"))
+		require.NoError(t, err)
+		// when
+		writer.AbortAndClose()
+		// then
+		dataRead := tests.ReadData(t, s, store.Time(version.Time))
+		assert.Equal(t, oldData, dataRead)
+		assert.Len(t, readVersions(t, s), 1)
+	})
+
+	for _, file := range []string{"data file", "checksum file"} {
+		file := file
+
+		t.Run("should remove replacement files when renaming "+file+" failed", func(t *testing.T) {
+			dir := tests.TempDir(t)
+			s, err := store.Open(dir)
+			require.NoError(t, err)
+			version := tests.WriteData(t, s, []byte("old"))
+			// turns the file into a non-empty directory, which cannot be replaced by renaming
+			blockRename := func(f *os.File) error {
+				name := strings.TrimSuffix(f.Name(), ".replace")
+				if file == "checksum file" {
+					name += ".sum"
+				}
+				require.NoError(t, os.Remove(name))
+				require.NoError(t, os.Mkdir(name, 0775))
+				tests.TouchFile(t, path.Join(name, "file"))
+				return nil
+			}
+			writer, err := s.Writer(store.WriteTime(version.Time), store.Replace, store.SyncWith(blockRename))
+			require.NoError(t, err)
+			_, err = writer.Write([]byte("new"))
+			require.NoError(t, err)
+			// when
+			err = writer.Close()
+			// then
+			assert.Error(t, err)
+			replacements, err := filepath.Glob(path.Join(dir, "*.replace"))
+			require.NoError(t, err)
+			assert.Empty(t, replacements)
+		})
+	}
+}
+
+func TestMaxVersionSize(t *testing.T) {
+
+	t.Run("should write version with size equal to limit", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxVersionSize(4))
+		tests.WriteData(t, s, []byte("data"))
+		assert.Len(t, readVersions(t, s), 1)
+	})
+
+	t.Run("should abort version exceeding the limit", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxVersionSize(4))
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("dat"))
+		require.NoError(t, err)
+		// when
+		n, err := writer.Write([]byte("aa"))
+		// then
+		assert.True(t, store.IsVersionTooLarge(err))
+		assert.Equal(t, 0, n)
+		assert.Empty(t, readVersions(t, s))
+		assert.Equal(t, 1, s.Metrics().Write.Aborted)
+		// and
+		assert.Error(t, writer.Close())
+	})
+
+	t.Run("should not write any files when closing aborted version", func(t *testing.T) {
+		dir := tests.TempDir(t)
+		s, err := store.Open(dir, store.MaxVersionSize(1))
+		require.NoError(t, err)
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.True(t, store.IsVersionTooLarge(err))
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+		assert.Equal(t, 0, s.Metrics().Write.Failed)
+		assert.Equal(t, 1, s.Metrics().Write.Aborted)
+	})
+}
+
+func TestMaxStoreSize(t *testing.T) {
+
+	t.Run("should abort version exceeding the store limit", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxStoreSize(100))
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write(make([]byte, 101))
+		// then
+		assert.True(t, store.IsStoreFull(err))
+		assert.Empty(t, readVersions(t, s))
+	})
+
+	t.Run("should take existing versions into account", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxStoreSize(100))
+		tests.WriteData(t, s, make([]byte, 80))
+		writer, err := s.Writer()
+		require.NoError(t, err)
+		defer writer.AbortAndClose()
+		// when
+		_, err = writer.Write(make([]byte, 20))
+		// then
+		assert.True(t, store.IsStoreFull(err))
+	})
+
+	t.Run("should not open writer when store is full", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MaxStoreSize(10))
+		tests.WriteData(t, s, make([]byte, 10))
+		// when
+		writer, err := s.Writer()
+		// then
+		assert.True(t, store.IsStoreFull(err))
+		var storeFull store.StoreFullError
+		require.True(t, errors.As(err, &storeFull))
+		assert.Equal(t, int64(10), storeFull.Limit)
+		assert.Nil(t, writer)
+	})
+}
+
+func TestMinFreeSpace(t *testing.T) {
+
+	t.Run("should open writer when there is enough free space", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MinFreeSpace(1))
+		tests.WriteData(t, s, []byte("data"))
+		assert.Len(t, readVersions(t, s), 1)
+	})
+
+	t.Run("should return error when there is not enough free space", func(t *testing.T) {
+		s := tests.OpenStore(t, store.MinFreeSpace(math.MaxUint64))
+		// when
+		writer, err := s.Writer()
+		// then
+		assert.True(t, store.IsInsufficientSpace(err))
+		assert.Nil(t, writer)
+	})
+
+	t.Run("should run reclamation policy when there is not enough free space", func(t *testing.T) {
+		reclaimed := 0
+		policy := func(s *store.Store) error {
+			reclaimed++
+			return nil
+		}
+		s := tests.OpenStore(t, store.MinFreeSpace(math.MaxUint64), store.ReclaimSpace(policy))
+		// when
+		_, err := s.Writer()
+		// then
+		assert.True(t, store.IsInsufficientSpace(err))
+		assert.Equal(t, 1, reclaimed)
+	})
+
+	t.Run("should return error when reclamation policy failed", func(t *testing.T) {
+		policyErr := errors.New("failed")
+		policy := func(s *store.Store) error {
+			return policyErr
+		}
+		s := tests.OpenStore(t, store.MinFreeSpace(math.MaxUint64), store.ReclaimSpace(policy))
+		// when
+		_, err := s.Writer()
+		// then
+		assert.ErrorIs(t, err, policyErr)
+	})
+}
+
+func TestReclaimSpace(t *testing.T) {
+
+	t.Run("should run reclamation policy and open writer when store is full", func(t *testing.T) {
+		var s *store.Store
+		policy := func(reclaimedStore *store.Store) error {
+			assert.Same(t, s, reclaimedStore)
+			versions, err := reclaimedStore.Versions()
+			require.NoError(t, err)
+			return reclaimedStore.DeleteVersion(versions[0].Time)
+		}
+		s = tests.OpenStore(t, store.MaxStoreSize(10), store.ReclaimSpace(policy))
+		tests.WriteData(t, s, make([]byte, 10))
+		// when
+		data := []byte("new")
+		tests.WriteData(t, s, data)
+		// then
+		assert.Len(t, readVersions(t, s), 1)
+		assert.Equal(t, data, tests.ReadData(t, s))
+	})
 }
 
 func readVersions(t *testing.T, s *store.Store) []store.Version {
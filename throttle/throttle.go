@@ -0,0 +1,128 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+// Package throttle limits the number of bytes per second read or written by store, compacter and replicator.
+// The same TokenBucket can be shared by all components running against the same disk.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter blocks until n bytes can be transferred. golang.org/x/time/rate.Limiter implements this interface too,
+// but it returns error when n is greater than its burst.
+type Limiter interface {
+	WaitN(ctx context.Context, n int) error
+}
+
+// NewTokenBucket creates a limiter allowing bytesPerSecond on average. Burst is equal to bytesPerSecond.
+func NewTokenBucket(bytesPerSecond int) (*TokenBucket, error) {
+	if bytesPerSecond <= 0 {
+		return nil, fmt.Errorf("bytes per second must be positive, got %d", bytesPerSecond)
+	}
+	return &TokenBucket{
+		bytesPerSecond: float64(bytesPerSecond),
+		burst:          bytesPerSecond,
+		tokens:         float64(bytesPerSecond),
+		last:           time.Now(),
+	}, nil
+}
+
+// TokenBucket is a Limiter safe for concurrent use.
+type TokenBucket struct {
+	mutex          sync.Mutex
+	bytesPerSecond float64
+	burst          int
+	tokens         float64
+	last           time.Time
+}
+
+// WaitN blocks until n bytes can be transferred or ctx is done. n can be greater than burst.
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		chunk := n
+		if chunk > b.burst {
+			chunk = b.burst
+		}
+		if err := sleep(ctx, b.reserve(chunk)); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// reserve takes n tokens, possibly going into debt, and returns the time caller has to wait
+func (b *TokenBucket) reserve(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.bytesPerSecond
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.bytesPerSecond * float64(time.Second))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader returns reader waiting for the limiter after each read.
+func Reader(ctx context.Context, r io.Reader, limiter Limiter) io.Reader {
+	return &reader{ctx: ctx, reader: r, limiter: limiter}
+}
+
+type reader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Writer returns writer waiting for the limiter before each write.
+func Writer(ctx context.Context, w io.Writer, limiter Limiter) io.Writer {
+	return &writer{ctx: ctx, writer: w, limiter: limiter}
+}
+
+type writer struct {
+	ctx     context.Context
+	writer  io.Writer
+	limiter Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if err := w.limiter.WaitN(w.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(p)
+}
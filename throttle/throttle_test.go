@@ -0,0 +1,144 @@
+// (c) 2021 Jacek Olszak
+// This code is licensed under MIT license (see LICENSE for details)
+
+package throttle_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/elgopher/deebee/internal/tests"
+	"github.com/elgopher/deebee/throttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenBucket(t *testing.T) {
+
+	t.Run("should return error for non-positive rate", func(t *testing.T) {
+		for _, rate := range []int{0, -1} {
+			bucket, err := throttle.NewTokenBucket(rate)
+			assert.Error(t, err)
+			assert.Nil(t, bucket)
+		}
+	})
+}
+
+func TestTokenBucket_WaitN(t *testing.T) {
+
+	t.Run("should not wait when bucket is full", func(t *testing.T) {
+		bucket, err := throttle.NewTokenBucket(1000)
+		require.NoError(t, err)
+		start := time.Now()
+		// when
+		err = bucket.WaitN(context.Background(), 1000)
+		// then
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("should wait when bucket is empty", func(t *testing.T) {
+		bucket, err := throttle.NewTokenBucket(1000)
+		require.NoError(t, err)
+		require.NoError(t, bucket.WaitN(context.Background(), 1000))
+		start := time.Now()
+		// when
+		err = bucket.WaitN(context.Background(), 100)
+		// then
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	})
+
+	t.Run("should accept n greater than burst", func(t *testing.T) {
+		bucket, err := throttle.NewTokenBucket(1000)
+		require.NoError(t, err)
+		start := time.Now()
+		// when
+		err = bucket.WaitN(context.Background(), 1100)
+		// then
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	})
+
+	t.Run("should share tokens between callers", func(t *testing.T) {
+		bucket, err := throttle.NewTokenBucket(1000)
+		require.NoError(t, err)
+		require.NoError(t, bucket.WaitN(context.Background(), 600))
+		start := time.Now()
+		// when
+		err = bucket.WaitN(context.Background(), 500)
+		// then
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	})
+
+	t.Run("should return error when context is cancelled", func(t *testing.T) {
+		bucket, err := throttle.NewTokenBucket(1)
+		require.NoError(t, err)
+		require.NoError(t, bucket.WaitN(context.Background(), 1))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err = bucket.WaitN(ctx, 1)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestReader(t *testing.T) {
+
+	t.Run("should wait for each byte read", func(t *testing.T) {
+		limiter := &tests.LimiterMock{}
+		data := []byte("data")
+		reader := throttle.Reader(context.Background(), bytes.NewReader(data), limiter)
+		// when
+		dataRead, err := io.ReadAll(reader)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, data, dataRead)
+		assert.Equal(t, len(data), limiter.Bytes())
+	})
+
+	t.Run("should return limiter error", func(t *testing.T) {
+		limiterErr := errors.New("failed")
+		limiter := &tests.LimiterMock{ReturnError: limiterErr}
+		reader := throttle.Reader(context.Background(), bytes.NewReader([]byte("data")), limiter)
+		// when
+		_, err := io.ReadAll(reader)
+		// then
+		assert.ErrorIs(t, err, limiterErr)
+	})
+}
+
+func TestWriter(t *testing.T) {
+
+	t.Run("should wait for each byte written", func(t *testing.T) {
+		limiter := &tests.LimiterMock{}
+		buffer := &bytes.Buffer{}
+		writer := throttle.Writer(context.Background(), buffer, limiter)
+		data := []byte("data")
+		// when
+		n, err := writer.Write(data)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		assert.Equal(t, data, buffer.Bytes())
+		assert.Equal(t, len(data), limiter.Bytes())
+	})
+
+	t.Run("should not write when limiter returned error", func(t *testing.T) {
+		limiter := &tests.LimiterMock{ReturnError: errors.New("failed")}
+		buffer := &bytes.Buffer{}
+		writer := throttle.Writer(context.Background(), buffer, limiter)
+		// when
+		n, err := writer.Write([]byte("data"))
+		// then
+		assert.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.Empty(t, buffer.Bytes())
+	})
+}